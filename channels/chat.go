@@ -0,0 +1,181 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"notification/models/channel"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultRetryAfter is the delay of a rate limit without a Retry-After
+	defaultRetryAfter = time.Minute
+	// minRetryAfter keeps a Retry-After of 0 or in the past from rescheduling a
+	// delivery straight away, which would claim it again in a tight loop
+	minRetryAfter = 5 * time.Second
+	// Slack rejects header blocks over 150 characters and section text over 3000
+	maxChatHeaderLength  = 150
+	maxChatSectionLength = 3000
+)
+
+// ValidChatMeta represents the required metadata for chat notifications
+type ValidChatMeta struct {
+	WebhookURL string `json:"webhook_url" example:"https://hooks.slack.com/services/T000/B000/XXXX"`
+	Context    string `json:"context,omitempty" example:"api-prod | us-east-1"`
+}
+
+type chatText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type chatBlock struct {
+	Type     string     `json:"type"`
+	Text     *chatText  `json:"text,omitempty"`
+	Elements []chatText `json:"elements,omitempty"`
+}
+
+// chatPayload is a Slack incoming webhook message. Text is the fallback shown
+// in notifications and by servers without block support, like Mattermost
+type chatPayload struct {
+	Text   string      `json:"text"`
+	Blocks []chatBlock `json:"blocks"`
+}
+
+// ChatChannel posts to Slack-compatible incoming webhooks
+type ChatChannel struct {
+	// Client sends the requests. When nil they only go to public addresses and
+	// redirects aren't followed
+	Client *http.Client
+	// Timeout bounds each request, defaults to 10s when zero
+	Timeout time.Duration
+	// Templates resolves meta.template to a stored chat title (subject) and body
+	Templates channel.TemplateSource
+}
+
+func (c *ChatChannel) Name() string {
+	return "chat"
+}
+
+func (c *ChatChannel) Validate(meta map[string]string) error {
+	u, err := url.Parse(meta["webhook_url"])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("webhook_url field with a valid http(s) URL is required")
+	}
+	return nil
+}
+
+func (c *ChatChannel) Prepare(ctx context.Context, msg *channel.Message) error {
+	if err := msg.RenderVariables(false); err != nil {
+		return err
+	}
+	return applyStoredTemplate(ctx, c.Templates, c.Name(), msg)
+}
+
+// Send posts the message to meta.webhook_url. A 429 response is returned as a
+// channel.RetryAfterError honoring the Retry-After header
+func (c *ChatChannel) Send(ctx context.Context, msg channel.Message) error {
+	body, err := json.Marshal(buildChatPayload(msg))
+	if err != nil {
+		return err
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.Meta["webhook_url"], bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := c.Client
+	if client == nil {
+		client = outboundClient
+	}
+	resp, err := client.Do(req)
+	if errors.Is(err, errPrivateAddress) {
+		return channel.Permanent(fmt.Errorf("chat webhook request failed: %w", err))
+	}
+	if err != nil {
+		return fmt.Errorf("chat webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return &channel.RetryAfterError{
+			After: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+			Err:   fmt.Errorf("chat webhook rate limited"),
+		}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("chat webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *ChatChannel) Preview(ctx context.Context, msg channel.Message) (channel.Preview, error) {
+	body, err := json.Marshal(buildChatPayload(msg))
+	if err != nil {
+		return channel.Preview{}, err
+	}
+	return channel.Preview{Subject: msg.Title, Text: msg.Content, Payload: body}, nil
+}
+
+// buildChatPayload lays the message out as a header with the title, a section
+// with the content and an optional context line from meta.context
+func buildChatPayload(msg channel.Message) chatPayload {
+	var blocks []chatBlock
+	if msg.Title != "" {
+		blocks = append(blocks, chatBlock{Type: "header", Text: &chatText{Type: "plain_text", Text: truncateRunes(msg.Title, maxChatHeaderLength)}})
+	}
+	if msg.Content != "" {
+		blocks = append(blocks, chatBlock{Type: "section", Text: &chatText{Type: "mrkdwn", Text: truncateRunes(msg.Content, maxChatSectionLength)}})
+	}
+	if s := msg.Meta["context"]; s != "" {
+		blocks = append(blocks, chatBlock{Type: "context", Elements: []chatText{{Type: "mrkdwn", Text: s}}})
+	}
+
+	text := msg.Content
+	if msg.Title != "" {
+		text = "*" + msg.Title + "*\n" + msg.Content
+	}
+	return chatPayload{Text: text, Blocks: blocks}
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// parseRetryAfter reads a Retry-After value given in seconds or as an HTTP
+// date, waiting at least minRetryAfter
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return retryDelay(time.Duration(secs) * time.Second)
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return retryDelay(t.Sub(now))
+	}
+	return defaultRetryAfter
+}
+
+// retryDelay raises a provider's retry delay to minRetryAfter
+func retryDelay(d time.Duration) time.Duration {
+	return max(d, minRetryAfter)
+}
@@ -0,0 +1,166 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"notification/models/channel"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestChatValidate(t *testing.T) {
+	c := &ChatChannel{}
+	if err := c.Validate(map[string]string{"webhook_url": "https://hooks.slack.com/services/T000/B000/XXXX"}); err != nil {
+		t.Fatalf("expected valid meta, got %v", err)
+	}
+	for _, u := range []string{"", "hooks.slack.com/services/x", "mailto:ops@example.com"} {
+		if err := c.Validate(map[string]string{"webhook_url": u}); err == nil {
+			t.Errorf("expected error for %q", u)
+		}
+	}
+}
+
+func TestChatSend_Blocks(t *testing.T) {
+	var got chatPayload
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Errorf("invalid body: %v", err)
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	c := &ChatChannel{Client: srv.Client()}
+	msg := channel.Message{
+		Title:   "High error rate",
+		Content: "5xx above *2%* for 5 minutes",
+		Meta:    map[string]string{"webhook_url": srv.URL, "context": "api-prod"},
+	}
+	if err := c.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if len(got.Blocks) != 3 {
+		t.Fatalf("expected 3 blocks, got %+v", got.Blocks)
+	}
+	if got.Blocks[0].Type != "header" || got.Blocks[0].Text.Type != "plain_text" || got.Blocks[0].Text.Text != "High error rate" {
+		t.Errorf("unexpected header block: %+v", got.Blocks[0])
+	}
+	if got.Blocks[1].Type != "section" || got.Blocks[1].Text.Type != "mrkdwn" || got.Blocks[1].Text.Text != msg.Content {
+		t.Errorf("unexpected section block: %+v", got.Blocks[1])
+	}
+	if got.Blocks[2].Type != "context" || len(got.Blocks[2].Elements) != 1 || got.Blocks[2].Elements[0].Text != "api-prod" {
+		t.Errorf("unexpected context block: %+v", got.Blocks[2])
+	}
+	if !strings.Contains(got.Text, "High error rate") {
+		t.Errorf("fallback text should contain the title, got %q", got.Text)
+	}
+}
+
+func TestChatSend_LongTitleTruncated(t *testing.T) {
+	payload := buildChatPayload(channel.Message{Title: strings.Repeat("é", 200)})
+	if n := len([]rune(payload.Blocks[0].Text.Text)); n != maxChatHeaderLength {
+		t.Fatalf("expected header of %d characters, got %d", maxChatHeaderLength, n)
+	}
+}
+
+func TestChatSend_RateLimited(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	c := &ChatChannel{Client: srv.Client()}
+	err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"webhook_url": srv.URL}})
+	var retryAfter *channel.RetryAfterError
+	if !errors.As(err, &retryAfter) {
+		t.Fatalf("expected RetryAfterError, got %v", err)
+	}
+	if retryAfter.After != 30*time.Second {
+		t.Fatalf("expected 30s, got %s", retryAfter.After)
+	}
+}
+
+func TestChatSend_ServerError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	c := &ChatChannel{Client: srv.Client()}
+	err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"webhook_url": srv.URL}})
+	var retryAfter *channel.RetryAfterError
+	if err == nil || errors.As(err, &retryAfter) {
+		t.Fatalf("expected a plain error, got %v", err)
+	}
+}
+
+func TestChatSend_RefusesPrivateAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	c := &ChatChannel{}
+	for _, u := range []string{srv.URL, "http://192.168.0.10/hooks/x", "http://169.254.169.254/latest/meta-data/"} {
+		err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"webhook_url": u}})
+		var permanent *channel.PermanentError
+		if !errors.Is(err, errPrivateAddress) || !errors.As(err, &permanent) {
+			t.Fatalf("%s: expected a permanent private address error, got %v", u, err)
+		}
+	}
+	if called {
+		t.Fatal("the loopback server should not be reached")
+	}
+}
+
+func TestChatSend_DoesNotFollowRedirects(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusFound)
+	}))
+	defer srv.Close()
+
+	// the default client, allowed to reach the loopback test servers
+	client := *outboundClient
+	client.Transport = srv.Client().Transport
+	c := &ChatChannel{Client: &client}
+	if err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"webhook_url": srv.URL}}); err == nil {
+		t.Fatal("expected an error for the redirect response")
+	}
+	if redirected {
+		t.Fatal("the redirect should not be followed")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 10, 27, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Duration
+	}{
+		{"120", 2 * time.Minute},
+		{"Mon, 27 Oct 2025 10:00:45 GMT", 45 * time.Second},
+		{"Mon, 27 Oct 2025 09:00:00 GMT", minRetryAfter},
+		{"0", minRetryAfter},
+		{"", defaultRetryAfter},
+		{"soon", defaultRetryAfter},
+	}
+	for _, tt := range tests {
+		if got := parseRetryAfter(tt.value, now); got != tt.want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", tt.value, got, tt.want)
+		}
+	}
+}
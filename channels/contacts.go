@@ -0,0 +1,40 @@
+package channels
+
+import (
+	"context"
+	"fmt"
+	"notification/models/channel"
+)
+
+// canResolve reports whether an address missing from meta can be looked up in
+// the recipient's contact profile during Prepare
+func canResolve(src channel.ContactSource, meta map[string]string) (bool, error) {
+	id, err := channel.RecipientUserID(meta)
+	if err != nil {
+		return false, err
+	}
+	return id != 0 && src != nil, nil
+}
+
+// resolveAddress sets meta[key] from the recipient's contact profile when it is
+// empty. A profile without the address fails permanently
+func resolveAddress(ctx context.Context, src channel.ContactSource, msg *channel.Message, key string, address func(channel.Contact) string) error {
+	if msg.Meta[key] != "" {
+		return nil
+	}
+	id, err := channel.RecipientUserID(msg.Meta)
+	if err != nil || id == 0 || src == nil {
+		return err
+	}
+	contact, err := src.Contact(ctx, id)
+	if err != nil {
+		return err
+	}
+	value := address(contact)
+	if value == "" {
+		return channel.Permanent(fmt.Errorf("user %d has no %s in their contact profile", id, key))
+	}
+	msg.Meta[key] = value
+	msg.Resolved = append(msg.Resolved, value)
+	return nil
+}
@@ -0,0 +1,121 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"notification/models/channel"
+	"testing"
+)
+
+type staticContacts map[uint]channel.Contact
+
+func (s staticContacts) Contact(ctx context.Context, userID uint) (channel.Contact, error) {
+	contact, ok := s[userID]
+	if !ok {
+		return channel.Contact{}, channel.Permanent(errors.New("user not found"))
+	}
+	return contact, nil
+}
+
+func TestEmailPrepare_ResolvesRecipient(t *testing.T) {
+	c := &EmailChannel{Contacts: staticContacts{7: {Email: "ana@example.com"}}}
+	meta := map[string]string{channel.MetaRecipientUserID: "7"}
+	if err := c.Validate(meta); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	msg := &channel.Message{Title: "t", Meta: meta}
+	if err := c.Prepare(context.Background(), msg); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if msg.Meta["to"] != "ana@example.com" {
+		t.Fatalf("expected the profile email, got %q", msg.Meta["to"])
+	}
+
+	// an explicit address wins over the profile
+	msg = &channel.Message{Meta: map[string]string{"to": "ops@example.com", channel.MetaRecipientUserID: "7"}}
+	if err := c.Prepare(context.Background(), msg); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if msg.Meta["to"] != "ops@example.com" {
+		t.Fatalf("expected meta.to to be kept, got %q", msg.Meta["to"])
+	}
+}
+
+func TestEmailValidate_RecipientWithoutContacts(t *testing.T) {
+	c := &EmailChannel{}
+	if err := c.Validate(map[string]string{channel.MetaRecipientUserID: "7"}); err == nil {
+		t.Fatal("expected error when contacts can't be looked up")
+	}
+}
+
+func TestSMSPrepare_ResolvesRecipient(t *testing.T) {
+	c := &SMSChannel{Contacts: staticContacts{7: {Phone: "+14155552671"}, 8: {Email: "ana@example.com"}}}
+	meta := map[string]string{channel.MetaRecipientUserID: "7"}
+	if err := c.Validate(meta); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	msg := &channel.Message{Content: "hi", Meta: meta}
+	if err := c.Prepare(context.Background(), msg); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if msg.Meta["phone"] != "+14155552671" {
+		t.Fatalf("expected the profile phone, got %q", msg.Meta["phone"])
+	}
+
+	// no phone in the profile
+	msg = &channel.Message{Content: "hi", Meta: map[string]string{channel.MetaRecipientUserID: "8"}}
+	err := c.Prepare(context.Background(), msg)
+	var permanent *channel.PermanentError
+	if !errors.As(err, &permanent) {
+		t.Fatalf("expected a permanent error, got %v", err)
+	}
+}
+
+func TestSMSPrepare_ResolvedPhoneRegionDenied(t *testing.T) {
+	c := &SMSChannel{Contacts: staticContacts{7: {Phone: "+447911123456"}}, AllowedRegions: []string{"US"}}
+	msg := &channel.Message{Content: "hi", Meta: map[string]string{channel.MetaRecipientUserID: "7"}}
+	var permanent *channel.PermanentError
+	if err := c.Prepare(context.Background(), msg); !errors.As(err, &permanent) {
+		t.Fatalf("expected a permanent error for a profile phone outside the allowed regions, got %v", err)
+	}
+
+	c = &SMSChannel{Contacts: staticContacts{7: {Phone: "+447911123456"}}, DeniedRegions: []string{"GB"}}
+	msg = &channel.Message{Content: "hi", Meta: map[string]string{channel.MetaRecipientUserID: "7"}}
+	if err := c.Prepare(context.Background(), msg); !errors.As(err, &permanent) {
+		t.Fatalf("expected a permanent error for a profile phone in a denied region, got %v", err)
+	}
+}
+
+func TestInAppSend_RecipientUserID(t *testing.T) {
+	inbox := &recordingInbox{}
+	c := &InAppChannel{Inbox: inbox}
+	msg := channel.Message{UserID: 3, Meta: map[string]string{channel.MetaRecipientUserID: "5"}}
+	if err := c.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if inbox.userID != 5 {
+		t.Fatalf("expected delivery to the recipient, got %d", inbox.userID)
+	}
+}
+
+func TestPushPrepare_RecipientUsesDevices(t *testing.T) {
+	fcm := &platformPushProvider{name: "fcm"}
+	c := &PushChannel{
+		Providers: map[string]PushProvider{PushPlatformAndroid: fcm},
+		Devices:   staticPushDevices{{Token: "android_token", Platform: "android"}},
+	}
+	meta := map[string]string{channel.MetaRecipientUserID: "7"}
+	if err := c.Validate(meta); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	msg := &channel.Message{Title: "t", Content: "c", Meta: meta}
+	if err := c.Prepare(context.Background(), msg); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if err := c.Send(context.Background(), *msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(fcm.sent) != 1 || fcm.sent[0] != "android_token" {
+		t.Fatalf("expected delivery to the recipient's device, got %v", fcm.sent)
+	}
+}
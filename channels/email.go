@@ -4,34 +4,71 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"html/template"
+	htmltemplate "html/template"
 	"net/mail"
 	"notification/models/channel"
 	"os"
 	"sync"
+	texttemplate "text/template"
 )
 
+const defaultEmailFrom = "noreply@localhost"
+
 // ValidEmailMeta represents the required metadata for email notifications
 type ValidEmailMeta struct {
 	To       string `json:"to" example:"user@example.com"`
 	Subject  string `json:"subject,omitempty" example:"Welcome to our platform"`
 	Template string `json:"template,omitempty" example:"titled"`
+	// Attachments is sent as a JSON array in meta.attachments
+	Attachments []EmailAttachment `json:"attachments,omitempty"`
+}
+
+// emailTemplate pairs the plain-text and HTML renderings of the same email
+type emailTemplate struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+func (t emailTemplate) render(msg channel.Message) (text, html string, err error) {
+	var textBody, htmlBody bytes.Buffer
+	if err := t.text.Execute(&textBody, msg); err != nil {
+		return "", "", err
+	}
+	if err := t.html.Execute(&htmlBody, msg); err != nil {
+		return "", "", err
+	}
+	return textBody.String(), htmlBody.String(), nil
 }
 
 type EmailChannel struct {
-	templates map[string]*template.Template
-	once      sync.Once
+	// Transport delivers the rendered message; messages are printed to stdout when nil
+	Transport MailTransport
+	// Templates resolves meta.template to a stored template, falling back to the built-in ones
+	Templates channel.TemplateSource
+	// Blobs resolves attachments given by reference; only inline base64 content is accepted when nil
+	Blobs BlobStore
+	// Contacts resolves the address of notifications sent to a recipient_user_id
+	Contacts channel.ContactSource
+	// Size limits for attachments, defaults are used when zero
+	MaxAttachmentBytes      int64
+	MaxTotalAttachmentBytes int64
+	templates               map[string]emailTemplate
+	once                    sync.Once
 }
 
 func (c *EmailChannel) initTemplates() {
 	c.once.Do(func() {
-		c.templates = make(map[string]*template.Template)
-		c.templates["titled"] = template.Must(template.ParseFiles("email/titled.html.tmpl"))
-		c.templates["plain"] = template.Must(template.ParseFiles("email/plain.txt.tmpl"))
+		c.templates = make(map[string]emailTemplate)
+		for _, name := range []string{"titled", "plain"} {
+			c.templates[name] = emailTemplate{
+				text: texttemplate.Must(texttemplate.ParseFS(builtinEmailTemplates, "email/"+name+".txt.tmpl")),
+				html: htmltemplate.Must(htmltemplate.ParseFS(builtinEmailTemplates, "email/"+name+".html.tmpl")),
+			}
+		}
 	})
 }
 
-func (c *EmailChannel) getTemplate(templateName string) *template.Template {
+func (c *EmailChannel) getTemplate(templateName string) emailTemplate {
 	c.initTemplates()
 	name := templateName
 	if name == "" {
@@ -44,6 +81,32 @@ func (c *EmailChannel) getTemplate(templateName string) *template.Template {
 	return tmpl
 }
 
+// resolveTemplate returns the stored template named in meta.template, if any,
+// along with its rendered subject; otherwise it returns a built-in template
+func (c *EmailChannel) resolveTemplate(ctx context.Context, msg channel.Message) (emailTemplate, string, error) {
+	stored, ok, err := lookupTemplate(ctx, c.Templates, c.Name(), msg)
+	if err != nil {
+		return emailTemplate{}, "", err
+	}
+	if !ok {
+		return c.getTemplate(msg.Meta["template"]), "", nil
+	}
+
+	text, err := texttemplate.New(stored.Name).Parse(stored.Text)
+	if err != nil {
+		return emailTemplate{}, "", err
+	}
+	html, err := htmltemplate.New(stored.Name).Parse(stored.HTML)
+	if err != nil {
+		return emailTemplate{}, "", err
+	}
+	subject, err := renderText(stored.Subject, msg)
+	if err != nil {
+		return emailTemplate{}, "", err
+	}
+	return emailTemplate{text: text, html: html}, subject, nil
+}
+
 func (c *EmailChannel) Name() string {
 	return "email"
 }
@@ -51,34 +114,88 @@ func (c *EmailChannel) Name() string {
 func (c *EmailChannel) Validate(meta map[string]string) error {
 	to, ok := meta["to"]
 	if !ok || to == "" {
-		return fmt.Errorf("to field with valid email is required")
+		if resolvable, err := canResolve(c.Contacts, meta); err != nil || !resolvable {
+			return fmt.Errorf("to field with valid email is required")
+		}
+		_, err := c.checkAttachments(meta)
+		return err
 	}
 	if _, err := mail.ParseAddress(to); err != nil {
 		return fmt.Errorf("invalid email address")
 	}
-	return nil
+	_, err := c.checkAttachments(meta)
+	return err
 }
 
 func (c *EmailChannel) Send(ctx context.Context, msg channel.Message) error {
-	tmpl := c.getTemplate(msg.Meta["template"])
-	var body bytes.Buffer
-	if err := tmpl.Execute(&body, msg); err != nil {
+	email, err := c.compose(ctx, msg)
+	if err != nil {
+		return err
+	}
+	email.Attachments, err = c.loadAttachments(msg.UserID, msg.Meta)
+	if err != nil {
 		return err
 	}
 
+	raw, err := email.Bytes()
+	if err != nil {
+		return err
+	}
+
+	fromAddr, _ := mail.ParseAddress(email.From)
+	toAddr, _ := mail.ParseAddress(email.To)
+	return c.sender(ctx, fromAddr.Address, toAddr.Address, raw)
+}
+
+// Preview renders the subject and both bodies without loading attachments
+func (c *EmailChannel) Preview(ctx context.Context, msg channel.Message) (channel.Preview, error) {
+	email, err := c.compose(ctx, msg)
+	if err != nil {
+		return channel.Preview{}, err
+	}
+	return channel.Preview{Subject: email.Subject, Text: email.Text, HTML: email.HTML}, nil
+}
+
+// compose renders the message into an email without its attachments
+func (c *EmailChannel) compose(ctx context.Context, msg channel.Message) (emailMessage, error) {
+	tmpl, subject, err := c.resolveTemplate(ctx, msg)
+	if err != nil {
+		return emailMessage{}, err
+	}
+	text, html, err := tmpl.render(msg)
+	if err != nil {
+		return emailMessage{}, err
+	}
+
 	from := os.Getenv("EMAIL_FROM")
-	to := msg.Meta["to"]
-	subject := msg.Meta["subject"]
+	if from == "" {
+		from = defaultEmailFrom
+	}
+	if s := msg.Meta["subject"]; s != "" {
+		subject = s
+	}
+	if subject == "" {
+		subject = msg.Title
+	}
 
-	return c.sender(ctx, from, to, subject, body.String())
+	return emailMessage{From: from, To: msg.Meta["to"], Subject: subject, Text: text, HTML: html}, nil
 }
 
 func (c *EmailChannel) Prepare(ctx context.Context, msg *channel.Message) error {
-	return nil
+	if err := resolveAddress(ctx, c.Contacts, msg, "to", func(contact channel.Contact) string { return contact.Email }); err != nil {
+		return err
+	}
+	return msg.RenderVariables(false)
+}
+
+func (c *EmailChannel) transport() MailTransport {
+	if c.Transport == nil {
+		return ConsoleTransport{}
+	}
+	return c.Transport
 }
 
 // separated from Send for testing purposes
-func (c *EmailChannel) sender(ctx context.Context, from, to, subject, body string) error {
-	fmt.Println(from, to, subject, body) // Implement actual email sending with external library or with net/smtp
-	return nil
+func (c *EmailChannel) sender(ctx context.Context, from, to string, msg []byte) error {
+	return c.transport().Send(ctx, from, []string{to}, msg)
 }
@@ -0,0 +1,244 @@
+package channels
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultMaxAttachmentBytes      int64 = 10 << 20
+	defaultMaxTotalAttachmentBytes int64 = 20 << 20
+	maxAttachments                       = 10
+)
+
+// EmailAttachment is a file attached to an email. Exactly one of Content (base64)
+// or Blob (a reference into the configured blob store) must be set. Attachments
+// with a ContentID are embedded inline and can be referenced from HTML as cid:<content_id>
+type EmailAttachment struct {
+	Filename    string `json:"filename" example:"invoice.pdf"`
+	ContentType string `json:"content_type,omitempty" example:"application/pdf"`
+	Content     string `json:"content,omitempty" example:"JVBERi0xLjQK"`
+	Blob        string `json:"blob,omitempty" example:"invoices/2025/10/123.pdf"`
+	ContentID   string `json:"content_id,omitempty" example:"logo"`
+}
+
+func (a EmailAttachment) contentType() string {
+	if a.ContentType != "" {
+		return a.ContentType
+	}
+	if t := mime.TypeByExtension(filepath.Ext(a.Filename)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// BlobStore resolves attachment references to their content. References
+// belong to a user, the creator of the notification, and can't reach the
+// blobs of another user
+type BlobStore interface {
+	Open(userID uint, ref string) (io.ReadCloser, error)
+	Size(userID uint, ref string) (int64, error)
+}
+
+// DirBlobStore serves the blobs of each user from a subdirectory named after
+// their ID; references cannot escape it
+type DirBlobStore struct {
+	root *os.Root
+}
+
+func NewDirBlobStore(dir string) (*DirBlobStore, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &DirBlobStore{root: root}, nil
+}
+
+// userRoot opens the directory of a user's blobs
+func (s *DirBlobStore) userRoot(userID uint) (*os.Root, error) {
+	return s.root.OpenRoot(strconv.FormatUint(uint64(userID), 10))
+}
+
+func (s *DirBlobStore) Open(userID uint, ref string) (io.ReadCloser, error) {
+	root, err := s.userRoot(userID)
+	if err != nil {
+		return nil, err
+	}
+	defer root.Close()
+	return root.Open(ref)
+}
+
+func (s *DirBlobStore) Size(userID uint, ref string) (int64, error) {
+	root, err := s.userRoot(userID)
+	if err != nil {
+		return 0, err
+	}
+	defer root.Close()
+	info, err := root.Stat(ref)
+	if err != nil {
+		return 0, err
+	}
+	if info.IsDir() {
+		return 0, fmt.Errorf("%s is a directory", ref)
+	}
+	return info.Size(), nil
+}
+
+// parseEmailAttachments reads the "attachments" meta field, a JSON array of EmailAttachment
+func parseEmailAttachments(meta map[string]string) ([]EmailAttachment, error) {
+	raw := meta["attachments"]
+	if raw == "" {
+		return nil, nil
+	}
+	var attachments []EmailAttachment
+	if err := json.Unmarshal([]byte(raw), &attachments); err != nil {
+		return nil, fmt.Errorf("attachments must be a JSON array: %w", err)
+	}
+	return attachments, nil
+}
+
+func (c *EmailChannel) maxAttachmentBytes() int64 {
+	if c.MaxAttachmentBytes > 0 {
+		return c.MaxAttachmentBytes
+	}
+	return defaultMaxAttachmentBytes
+}
+
+func (c *EmailChannel) maxTotalAttachmentBytes() int64 {
+	if c.MaxTotalAttachmentBytes > 0 {
+		return c.MaxTotalAttachmentBytes
+	}
+	return defaultMaxTotalAttachmentBytes
+}
+
+// checkAttachments checks the shape of the attachments in meta
+func (c *EmailChannel) checkAttachments(meta map[string]string) ([]EmailAttachment, error) {
+	attachments, err := parseEmailAttachments(meta)
+	if err != nil {
+		return nil, err
+	}
+	if len(attachments) > maxAttachments {
+		return nil, fmt.Errorf("at most %d attachments are allowed", maxAttachments)
+	}
+
+	contentIDs := map[string]bool{}
+	for _, a := range attachments {
+		if a.Filename == "" {
+			return nil, errors.New("attachment filename is required")
+		}
+		if (a.Content == "") == (a.Blob == "") {
+			return nil, fmt.Errorf("attachment %s must have exactly one of content or blob", a.Filename)
+		}
+		if a.Blob != "" && c.Blobs == nil {
+			return nil, errors.New("blob attachments are not configured")
+		}
+		if a.ContentID != "" {
+			if !strings.HasPrefix(a.contentType(), "image/") {
+				return nil, fmt.Errorf("inline attachment %s must be an image", a.Filename)
+			}
+			if contentIDs[a.ContentID] {
+				return nil, fmt.Errorf("duplicate content_id %s", a.ContentID)
+			}
+			contentIDs[a.ContentID] = true
+		}
+	}
+	return attachments, nil
+}
+
+// ValidateUser implements channel.UserValidator. Blob attachments must be in
+// the blob store of the user, and attachments must fit the per-file and total
+// size limits
+func (c *EmailChannel) ValidateUser(userID uint, meta map[string]string) error {
+	attachments, err := c.checkAttachments(meta)
+	if err != nil {
+		return err
+	}
+
+	var total int64
+	for _, a := range attachments {
+		size, err := c.attachmentSize(userID, a)
+		if err != nil {
+			return err
+		}
+		if size > c.maxAttachmentBytes() {
+			return fmt.Errorf("attachment %s exceeds %d bytes", a.Filename, c.maxAttachmentBytes())
+		}
+		total += size
+	}
+	if total > c.maxTotalAttachmentBytes() {
+		return fmt.Errorf("attachments exceed %d bytes in total", c.maxTotalAttachmentBytes())
+	}
+	return nil
+}
+
+func (c *EmailChannel) attachmentSize(userID uint, a EmailAttachment) (int64, error) {
+	if a.Blob != "" {
+		size, err := c.Blobs.Size(userID, a.Blob)
+		if err != nil {
+			return 0, fmt.Errorf("attachment %s: blob not found", a.Filename)
+		}
+		return size, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(a.Content)
+	if err != nil {
+		return 0, fmt.Errorf("attachment %s: content is not valid base64", a.Filename)
+	}
+	return int64(len(data)), nil
+}
+
+// loadAttachments decodes inline content and reads the blobs of userID,
+// re-checking the size limit since blobs may have changed between validation
+// and dispatch
+func (c *EmailChannel) loadAttachments(userID uint, meta map[string]string) ([]emailAttachment, error) {
+	attachments, err := parseEmailAttachments(meta)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make([]emailAttachment, 0, len(attachments))
+	for _, a := range attachments {
+		data, err := c.attachmentData(userID, a)
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, emailAttachment{
+			Filename:    a.Filename,
+			ContentType: a.contentType(),
+			ContentID:   a.ContentID,
+			Data:        data,
+		})
+	}
+	return loaded, nil
+}
+
+func (c *EmailChannel) attachmentData(userID uint, a EmailAttachment) ([]byte, error) {
+	if a.Blob == "" {
+		return base64.StdEncoding.DecodeString(a.Content)
+	}
+	if c.Blobs == nil {
+		return nil, errors.New("blob attachments are not configured")
+	}
+	f, err := c.Blobs.Open(userID, a.Blob)
+	if err != nil {
+		return nil, fmt.Errorf("attachment %s: %w", a.Filename, err)
+	}
+	defer f.Close()
+
+	limit := c.maxAttachmentBytes()
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("attachment %s: %w", a.Filename, err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("attachment %s exceeds %d bytes", a.Filename, limit)
+	}
+	return data, nil
+}
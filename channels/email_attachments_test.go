@@ -0,0 +1,233 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"notification/models/channel"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func attachmentsMeta(t *testing.T, attachments ...EmailAttachment) map[string]string {
+	t.Helper()
+	b, err := json.Marshal(attachments)
+	if err != nil {
+		t.Fatalf("marshal attachments: %v", err)
+	}
+	return map[string]string{"to": "user@example.com", "attachments": string(b)}
+}
+
+func b64(s string) string { return base64.StdEncoding.EncodeToString([]byte(s)) }
+
+// newTestBlobStore writes files, keyed by "<user_id>/<ref>", to a blob store
+func newTestBlobStore(t *testing.T, files map[string]string) *DirBlobStore {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write blob: %v", err)
+		}
+	}
+	store, err := NewDirBlobStore(dir)
+	if err != nil {
+		t.Fatalf("NewDirBlobStore: %v", err)
+	}
+	return store
+}
+
+// validate checks meta the way the notifier does for the creator, user 1
+func validate(c *EmailChannel, meta map[string]string) error {
+	if err := c.Validate(meta); err != nil {
+		return err
+	}
+	return c.ValidateUser(1, meta)
+}
+
+// mimePart is a decoded leaf part of a message together with the multipart types enclosing it
+type mimePart struct {
+	parents []string
+	header  map[string]string
+	body    string
+}
+
+func walkMIME(t *testing.T, contentType string, body io.Reader, parents []string) []mimePart {
+	t.Helper()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatalf("parse media type %q: %v", contentType, err)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") {
+		t.Fatalf("expected multipart, got %s", mediaType)
+	}
+	parents = append(parents, mediaType)
+
+	var parts []mimePart
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			return parts
+		}
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		partType := p.Header.Get("Content-Type")
+		if strings.HasPrefix(partType, "multipart/") {
+			parts = append(parts, walkMIME(t, partType, p, parents)...)
+			continue
+		}
+		data, _ := io.ReadAll(p)
+		if p.Header.Get("Content-Transfer-Encoding") == "base64" {
+			data, err = base64.StdEncoding.DecodeString(strings.ReplaceAll(string(data), "\r\n", ""))
+			if err != nil {
+				t.Fatalf("decode base64: %v", err)
+			}
+		}
+		header := map[string]string{}
+		for k := range p.Header {
+			header[k] = p.Header.Get(k)
+		}
+		parts = append(parts, mimePart{parents: append([]string(nil), parents...), header: header, body: string(data)})
+	}
+}
+
+func sentParts(t *testing.T, raw []byte) []mimePart {
+	t.Helper()
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	return walkMIME(t, m.Header.Get("Content-Type"), m.Body, nil)
+}
+
+func TestEmailValidate_Attachments(t *testing.T) {
+	c := &EmailChannel{}
+	meta := attachmentsMeta(t,
+		EmailAttachment{Filename: "invoice.pdf", Content: b64("%PDF-1.4")},
+		EmailAttachment{Filename: "logo.png", Content: b64("png"), ContentID: "logo"},
+	)
+	if err := validate(c, meta); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+}
+
+func TestEmailValidate_InvalidAttachments(t *testing.T) {
+	c := &EmailChannel{MaxAttachmentBytes: 8, MaxTotalAttachmentBytes: 12}
+	tests := []struct {
+		name        string
+		attachments []EmailAttachment
+	}{
+		{"missing filename", []EmailAttachment{{Content: b64("x")}}},
+		{"no content", []EmailAttachment{{Filename: "a.txt"}}},
+		{"content and blob", []EmailAttachment{{Filename: "a.txt", Content: b64("x"), Blob: "a.txt"}}},
+		{"invalid base64", []EmailAttachment{{Filename: "a.txt", Content: "not base64!"}}},
+		{"blob without store", []EmailAttachment{{Filename: "a.txt", Blob: "a.txt"}}},
+		{"inline non-image", []EmailAttachment{{Filename: "a.txt", Content: b64("x"), ContentID: "a"}}},
+		{"duplicate content id", []EmailAttachment{
+			{Filename: "a.png", Content: b64("x"), ContentID: "a"},
+			{Filename: "b.png", Content: b64("y"), ContentID: "a"},
+		}},
+		{"too large", []EmailAttachment{{Filename: "a.txt", Content: b64("123456789")}}},
+		{"total too large", []EmailAttachment{
+			{Filename: "a.txt", Content: b64("1234567")},
+			{Filename: "b.txt", Content: b64("1234567")},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validate(c, attachmentsMeta(t, tt.attachments...)); err == nil {
+				t.Fatalf("expected error")
+			}
+		})
+	}
+}
+
+func TestEmailValidate_BlobAttachments(t *testing.T) {
+	c := &EmailChannel{Blobs: newTestBlobStore(t, map[string]string{
+		"1/reports/q3.csv": "a,b\n1,2\n",
+		"2/payroll.csv":    "secret",
+	}), MaxAttachmentBytes: 16}
+	if err := validate(c, attachmentsMeta(t, EmailAttachment{Filename: "q3.csv", Blob: "reports/q3.csv"})); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if err := validate(c, attachmentsMeta(t, EmailAttachment{Filename: "q4.csv", Blob: "reports/q4.csv"})); err == nil {
+		t.Fatal("expected error for missing blob")
+	}
+	if err := validate(c, attachmentsMeta(t, EmailAttachment{Filename: "passwd", Blob: "../../etc/passwd"})); err == nil {
+		t.Fatal("expected error for blob outside the store")
+	}
+	for _, ref := range []string{"../2/payroll.csv", "/2/payroll.csv", "payroll.csv"} {
+		if err := validate(c, attachmentsMeta(t, EmailAttachment{Filename: "payroll.csv", Blob: ref})); err == nil {
+			t.Fatalf("expected error for another user's blob %s", ref)
+		}
+	}
+
+	c.MaxAttachmentBytes = 4
+	if err := validate(c, attachmentsMeta(t, EmailAttachment{Filename: "q3.csv", Blob: "reports/q3.csv"})); err == nil {
+		t.Fatal("expected error for oversized blob")
+	}
+}
+
+func TestEmailSend_MixedWithAttachment(t *testing.T) {
+	tr := &recordingTransport{}
+	c := &EmailChannel{Transport: tr, Blobs: newTestBlobStore(t, map[string]string{"1/q3.csv": "a,b\n1,2\n"})}
+	meta := attachmentsMeta(t,
+		EmailAttachment{Filename: "invoice.pdf", ContentType: "application/pdf", Content: b64("%PDF-1.4")},
+		EmailAttachment{Filename: "q3.csv", Blob: "q3.csv"},
+	)
+	if err := c.Send(context.Background(), channel.Message{Title: "t", Content: "c", Meta: meta, UserID: 2}); err == nil {
+		t.Fatal("expected another user's send not to read the blob")
+	}
+	if err := c.Send(context.Background(), channel.Message{Title: "t", Content: "c", Meta: meta, UserID: 1}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	parts := sentParts(t, tr.msg)
+	if len(parts) != 4 {
+		t.Fatalf("expected 4 parts, got %d", len(parts))
+	}
+	if parts[0].parents[0] != "multipart/mixed" || parts[0].parents[1] != "multipart/alternative" {
+		t.Fatalf("unexpected structure: %v", parts[0].parents)
+	}
+	pdf, csv := parts[2], parts[3]
+	if pdf.body != "%PDF-1.4" || !strings.HasPrefix(pdf.header["Content-Disposition"], "attachment") || !strings.Contains(pdf.header["Content-Disposition"], "invoice.pdf") {
+		t.Fatalf("unexpected pdf part: %+v", pdf)
+	}
+	if csv.body != "a,b\n1,2\n" || !strings.HasPrefix(csv.header["Content-Type"], "text/csv") {
+		t.Fatalf("unexpected csv part: %+v", csv)
+	}
+}
+
+func TestEmailSend_RelatedWithInlineImage(t *testing.T) {
+	tr := &recordingTransport{}
+	c := &EmailChannel{Transport: tr}
+	meta := attachmentsMeta(t, EmailAttachment{Filename: "logo.png", Content: b64("\x89PNG"), ContentID: "logo"})
+	if err := c.Send(context.Background(), channel.Message{Title: "t", Content: "c", Meta: meta}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	parts := sentParts(t, tr.msg)
+	if len(parts) != 3 {
+		t.Fatalf("expected 3 parts, got %d", len(parts))
+	}
+	if parts[0].parents[0] != "multipart/related" || parts[0].parents[1] != "multipart/alternative" {
+		t.Fatalf("unexpected structure: %v", parts[0].parents)
+	}
+	logo := parts[2]
+	if logo.header["Content-Id"] != "<logo>" || !strings.HasPrefix(logo.header["Content-Disposition"], "inline") || logo.body != "\x89PNG" {
+		t.Fatalf("unexpected inline part: %+v", logo)
+	}
+}
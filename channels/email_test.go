@@ -1,8 +1,12 @@
 package channels
 
 import (
+	"bytes"
 	"context"
 	"io"
+	"mime"
+	"mime/multipart"
+	"net/mail"
 	"notification/models/channel"
 	"os"
 	"strings"
@@ -49,8 +53,7 @@ func TestEmailSender_OK(t *testing.T) {
 	c := &EmailChannel{}
 	from := "from@example.com"
 	to := "to@example.com"
-	subject := "Subject"
-	body := "Body content"
+	body := "Subject: Subject\r\n\r\nBody content"
 
 	old := os.Stdout
 	r, w, err := os.Pipe()
@@ -60,13 +63,102 @@ func TestEmailSender_OK(t *testing.T) {
 	os.Stdout = w
 	defer func() { os.Stdout = old }()
 
-	if err := c.sender(context.Background(), from, to, subject, body); err != nil {
+	if err := c.sender(context.Background(), from, to, []byte(body)); err != nil {
 		t.Fatalf("sender: %v", err)
 	}
 	_ = w.Close()
 	out, _ := io.ReadAll(r)
 	got := string(out)
-	if !strings.Contains(got, subject) || !strings.Contains(got, to) || !strings.Contains(got, body) {
+	if !strings.Contains(got, "Subject") || !strings.Contains(got, to) || !strings.Contains(got, "Body content") {
 		t.Fatalf("unexpected sender output: %q", got)
 	}
 }
+
+// recordingTransport keeps the last message handed to it
+type recordingTransport struct {
+	from string
+	to   []string
+	msg  []byte
+}
+
+func (r *recordingTransport) Send(ctx context.Context, from string, to []string, msg []byte) error {
+	r.from, r.to, r.msg = from, to, msg
+	return nil
+}
+
+// readAlternatives parses a sent message and returns its headers and decoded parts by content type
+func readAlternatives(t *testing.T, raw []byte) (mail.Header, map[string]string) {
+	t.Helper()
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatalf("read message: %v", err)
+	}
+	mediaType, params, err := mime.ParseMediaType(m.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("unexpected content type %q: %v", m.Header.Get("Content-Type"), err)
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(m.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("next part: %v", err)
+		}
+		// multipart.Reader transparently decodes quoted-printable parts
+		b, _ := io.ReadAll(p)
+		partType, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		parts[partType] = string(b)
+	}
+	return m.Header, parts
+}
+
+func TestEmailSend_MultipartAlternative(t *testing.T) {
+	tr := &recordingTransport{}
+	t.Setenv("EMAIL_FROM", "Notifier <noreply@example.com>")
+	c := &EmailChannel{Transport: tr}
+	msg := channel.Message{Title: "Hola", Content: "Mundo <b>", Meta: map[string]string{"template": "titled", "to": "user@example.com", "subject": "s"}}
+	if err := c.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if tr.from != "noreply@example.com" || len(tr.to) != 1 || tr.to[0] != "user@example.com" {
+		t.Fatalf("unexpected envelope: %q %v", tr.from, tr.to)
+	}
+	header, parts := readAlternatives(t, tr.msg)
+	for _, h := range []string{"Date", "Message-ID", "MIME-Version"} {
+		if header.Get(h) == "" {
+			t.Fatalf("missing %s header", h)
+		}
+	}
+	if !strings.HasSuffix(header.Get("Message-ID"), "@example.com>") {
+		t.Fatalf("unexpected Message-ID: %q", header.Get("Message-ID"))
+	}
+	if text := parts["text/plain"]; !strings.Contains(text, "Hola") || !strings.Contains(text, "Mundo <b>") {
+		t.Fatalf("unexpected text part: %q", text)
+	}
+	if html := parts["text/html"]; !strings.Contains(html, "<h1>Notification: Hola</h1>") || !strings.Contains(html, "Mundo &lt;b&gt;") {
+		t.Fatalf("unexpected html part: %q", html)
+	}
+}
+
+func TestEmailSend_EncodesNonASCIISubject(t *testing.T) {
+	tr := &recordingTransport{}
+	c := &EmailChannel{Transport: tr}
+	msg := channel.Message{Title: "t", Content: "c", Meta: map[string]string{"to": "user@example.com", "subject": "Café ☕"}}
+	if err := c.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	header, _ := readAlternatives(t, tr.msg)
+	raw := header.Get("Subject")
+	if !strings.HasPrefix(raw, "=?utf-8?q?") {
+		t.Fatalf("expected RFC 2047 encoded subject, got %q", raw)
+	}
+	decoded, err := new(mime.WordDecoder).DecodeHeader(raw)
+	if err != nil || decoded != "Café ☕" {
+		t.Fatalf("decoded subject %q: %v", decoded, err)
+	}
+}
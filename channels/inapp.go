@@ -0,0 +1,80 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification/models/channel"
+	"strconv"
+)
+
+// Inbox stores in-app messages for a user
+type Inbox interface {
+	Deliver(ctx context.Context, userID uint, msg channel.Message) error
+}
+
+// ValidInAppMeta represents the metadata for in-app notifications. The
+// notification is delivered to user_id, or else to the recipient_user_id of the
+// notification, or else to its creator. Only admins may set a user_id other
+// than their own, which the notifier checks when the notification is created
+type ValidInAppMeta struct {
+	UserID string `json:"user_id,omitempty" example:"123"`
+	Data   string `json:"data,omitempty" example:"{\"post_id\":\"7\"}"`
+}
+
+// InAppChannel delivers notifications to the in-app inbox
+type InAppChannel struct {
+	Inbox Inbox
+	// Templates resolves meta.template to a stored in-app title (subject) and body
+	Templates channel.TemplateSource
+}
+
+func (c *InAppChannel) Name() string {
+	return "inapp"
+}
+
+// DeliversToCreator reports that notifications without a user_id go to the inbox of their creator
+func (c *InAppChannel) DeliversToCreator() bool {
+	return true
+}
+
+func (c *InAppChannel) Validate(meta map[string]string) error {
+	if s, ok := meta["user_id"]; ok {
+		if id, err := strconv.ParseUint(s, 10, 64); err != nil || id == 0 {
+			return fmt.Errorf("user_id must be a positive integer")
+		}
+	}
+	return nil
+}
+
+func (c *InAppChannel) Prepare(ctx context.Context, msg *channel.Message) error {
+	if err := msg.RenderVariables(false); err != nil {
+		return err
+	}
+	return applyStoredTemplate(ctx, c.Templates, c.Name(), msg)
+}
+
+func (c *InAppChannel) Send(ctx context.Context, msg channel.Message) error {
+	if c.Inbox == nil {
+		return errors.New("inbox is not configured")
+	}
+	userID := msg.UserID
+	recipient, err := channel.RecipientUserID(msg.Meta)
+	if err != nil {
+		return err
+	}
+	if recipient != 0 {
+		userID = recipient
+	}
+	if s := msg.Meta["user_id"]; s != "" {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid user_id: %w", err)
+		}
+		userID = uint(id)
+	}
+	if userID == 0 {
+		return errors.New("in-app notification has no recipient")
+	}
+	return c.Inbox.Deliver(ctx, userID, msg)
+}
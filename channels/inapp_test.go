@@ -0,0 +1,55 @@
+package channels
+
+import (
+	"context"
+	"notification/models/channel"
+	"testing"
+)
+
+type recordingInbox struct {
+	userID uint
+	msg    channel.Message
+}
+
+func (r *recordingInbox) Deliver(ctx context.Context, userID uint, msg channel.Message) error {
+	r.userID, r.msg = userID, msg
+	return nil
+}
+
+func TestInAppValidate(t *testing.T) {
+	c := &InAppChannel{}
+	if err := c.Validate(map[string]string{}); err != nil {
+		t.Fatalf("empty meta should be valid, got %v", err)
+	}
+	if err := c.Validate(map[string]string{"user_id": "12"}); err != nil {
+		t.Fatalf("expected valid user_id, got %v", err)
+	}
+	for _, id := range []string{"", "0", "-1", "abc"} {
+		if err := c.Validate(map[string]string{"user_id": id}); err == nil {
+			t.Errorf("expected error for user_id %q", id)
+		}
+	}
+}
+
+func TestInAppSend_Recipient(t *testing.T) {
+	inbox := &recordingInbox{}
+	c := &InAppChannel{Inbox: inbox}
+
+	if err := c.Send(context.Background(), channel.Message{Title: "t", UserID: 3, NotificationID: 9}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if inbox.userID != 3 || inbox.msg.NotificationID != 9 {
+		t.Fatalf("expected delivery to the creator, got user %d, %+v", inbox.userID, inbox.msg)
+	}
+
+	if err := c.Send(context.Background(), channel.Message{UserID: 3, Meta: map[string]string{"user_id": "5"}}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if inbox.userID != 5 {
+		t.Fatalf("expected delivery to meta.user_id, got %d", inbox.userID)
+	}
+
+	if err := c.Send(context.Background(), channel.Message{}); err == nil {
+		t.Fatal("expected error without recipient")
+	}
+}
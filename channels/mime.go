@@ -0,0 +1,200 @@
+package channels
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// emailMessage is an RFC 5322 message with text and HTML alternatives and optional attachments
+type emailMessage struct {
+	From        string
+	To          string
+	Subject     string
+	Text        string
+	HTML        string
+	Attachments []emailAttachment
+	Date        time.Time
+}
+
+// emailAttachment is a decoded attachment; ContentID marks it as an inline image
+type emailAttachment struct {
+	Filename    string
+	ContentType string
+	ContentID   string
+	Data        []byte
+}
+
+// mimeEntity is an encoded MIME body part with its headers
+type mimeEntity struct {
+	header textproto.MIMEHeader
+	body   []byte
+}
+
+// Bytes encodes the message. The body is multipart/alternative, wrapped in
+// multipart/related when there are inline images and in multipart/mixed when
+// there are regular attachments:
+//
+//	mixed
+//	├── related
+//	│   ├── alternative (text, html)
+//	│   └── inline images
+//	└── attachments
+func (m emailMessage) Bytes() ([]byte, error) {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid from address: %w", err)
+	}
+	to, err := mail.ParseAddress(m.To)
+	if err != nil {
+		return nil, fmt.Errorf("invalid to address: %w", err)
+	}
+	date := m.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	messageID, err := newMessageID(from.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	entity, err := m.body()
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	header := func(key, value string) { fmt.Fprintf(&buf, "%s: %s\r\n", key, value) }
+	header("From", from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", date.Format(time.RFC1123Z))
+	header("Message-ID", messageID)
+	header("MIME-Version", "1.0")
+	header("Content-Type", entity.header.Get("Content-Type"))
+	buf.WriteString("\r\n")
+	buf.Write(entity.body)
+	return buf.Bytes(), nil
+}
+
+func (m emailMessage) body() (mimeEntity, error) {
+	// Least preferred alternative goes first (RFC 2046 section 5.1.4)
+	entity, err := multipartEntity("alternative", nil,
+		quotedPrintableEntity("text/plain", m.Text),
+		quotedPrintableEntity("text/html", m.HTML),
+	)
+	if err != nil {
+		return mimeEntity{}, err
+	}
+
+	var inline, attached []mimeEntity
+	for _, a := range m.Attachments {
+		if a.ContentID != "" {
+			inline = append(inline, base64Entity(a))
+		} else {
+			attached = append(attached, base64Entity(a))
+		}
+	}
+
+	if len(inline) > 0 {
+		params := map[string]string{"type": "multipart/alternative"}
+		if entity, err = multipartEntity("related", params, append([]mimeEntity{entity}, inline...)...); err != nil {
+			return mimeEntity{}, err
+		}
+	}
+	if len(attached) > 0 {
+		if entity, err = multipartEntity("mixed", nil, append([]mimeEntity{entity}, attached...)...); err != nil {
+			return mimeEntity{}, err
+		}
+	}
+	return entity, nil
+}
+
+func multipartEntity(subtype string, params map[string]string, parts ...mimeEntity) (mimeEntity, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	for _, p := range parts {
+		pw, err := w.CreatePart(p.header)
+		if err != nil {
+			return mimeEntity{}, err
+		}
+		if _, err := pw.Write(p.body); err != nil {
+			return mimeEntity{}, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return mimeEntity{}, err
+	}
+
+	typeParams := map[string]string{"boundary": w.Boundary()}
+	for k, v := range params {
+		typeParams[k] = v
+	}
+	header := textproto.MIMEHeader{"Content-Type": {mime.FormatMediaType("multipart/"+subtype, typeParams)}}
+	return mimeEntity{header: header, body: buf.Bytes()}, nil
+}
+
+func quotedPrintableEntity(contentType, content string) mimeEntity {
+	var buf bytes.Buffer
+	qp := quotedprintable.NewWriter(&buf)
+	io.WriteString(qp, content)
+	qp.Close()
+	return mimeEntity{
+		header: textproto.MIMEHeader{
+			"Content-Type":              {contentType + "; charset=UTF-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		},
+		body: buf.Bytes(),
+	}
+}
+
+func base64Entity(a emailAttachment) mimeEntity {
+	mediaType, params, err := mime.ParseMediaType(a.ContentType)
+	if err != nil {
+		mediaType, params = "application/octet-stream", map[string]string{}
+	}
+	params["name"] = a.Filename
+
+	disposition := "attachment"
+	header := textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(mediaType, params)},
+		"Content-Transfer-Encoding": {"base64"},
+	}
+	if a.ContentID != "" {
+		disposition = "inline"
+		header.Set("Content-ID", "<"+a.ContentID+">")
+	}
+	header.Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": a.Filename}))
+
+	// RFC 2045 limits encoded lines to 76 characters
+	encoded := base64.StdEncoding.EncodeToString(a.Data)
+	var buf bytes.Buffer
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\r\n")
+	return mimeEntity{header: header, body: buf.Bytes()}
+}
+
+func newMessageID(fromAddress string) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	domain := "localhost"
+	if i := strings.LastIndex(fromAddress, "@"); i >= 0 && i < len(fromAddress)-1 {
+		domain = fromAddress[i+1:]
+	}
+	return fmt.Sprintf("<%s@%s>", hex.EncodeToString(b[:]), domain), nil
+}
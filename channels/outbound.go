@@ -0,0 +1,54 @@
+package channels
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+var errPrivateAddress = errors.New("refusing to connect to a non-public address")
+
+// outboundClient sends requests to the URLs users give, such as webhooks. It
+// only connects to public addresses, so a user can't reach loopback, the
+// internal network or a cloud metadata endpoint through the server, and it
+// doesn't follow redirects, which could lead there
+var outboundClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+			Control:   publicAddressOnly,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// publicAddressOnly is a net.Dialer Control that rejects loopback, private,
+// link-local and unspecified addresses. It runs on the resolved address, so a
+// public host name resolving to a private address is rejected too
+func publicAddressOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	ip = ip.Unmap()
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w %s", errPrivateAddress, ip)
+	}
+	return nil
+}
@@ -3,17 +3,52 @@ package channels
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"notification/models/channel"
+	"slices"
+	"strconv"
+	"strings"
 )
 
 type PushChannel struct {
+	// Providers deliver to devices by platform (android, ios, web); notifications
+	// are logged when empty
+	Providers map[string]PushProvider
+	// Tokens, when set, skips tokens reported invalid and records newly reported ones
+	Tokens PushTokens
+	// Devices resolves meta.user_id to the user's registered devices
+	Devices PushDevices
+	// Templates resolves meta.template to a stored push title (subject) and body
+	Templates channel.TemplateSource
 }
 
-// ValidPushMeta represents the required metadata for push notifications
+// PushTokens tracks the device tokens providers reported as no longer valid
+type PushTokens interface {
+	Invalid(ctx context.Context, token string) (bool, error)
+	Invalidate(ctx context.Context, token, platform, reason string) error
+}
+
+// PushDevice is a device token registered by a user
+type PushDevice struct {
+	Token    string
+	Platform string
+}
+
+// PushDevices lists the devices a notification for a user fans out to
+type PushDevices interface {
+	ActiveDevices(ctx context.Context, userID uint) ([]PushDevice, error)
+}
+
+// ValidPushMeta represents the required metadata for push notifications. Either
+// token or user_id is required; user_id sends to all of the user's registered
+// devices. Only admins may set a user_id other than their own, which the
+// notifier checks when the notification is created
 type ValidPushMeta struct {
 	Token    string            `json:"token" example:"device_token_xyz123"`
-	Platform string            `json:"platform" example:"android"`
+	UserID   string            `json:"user_id,omitempty" example:"123"`
+	Platform string            `json:"platform" example:"android" enums:"android,ios,web"`
 	Data     map[string]string `json:"data,omitempty" swaggertype:"object,string"`
 	Options  map[string]string `json:"options,omitempty" swaggertype:"object,string"`
 }
@@ -30,32 +65,282 @@ func (c *PushChannel) Name() string {
 }
 
 func (c *PushChannel) Send(ctx context.Context, msg channel.Message) error {
+	payload, err := buildPushPayload(msg)
+	if err != nil {
+		return err
+	}
+	opts, err := pushOptions(msg)
+	if err != nil {
+		return err
+	}
+	if payload.Token != "" {
+		provider, id, err := c.sendTo(ctx, payload, strings.ToLower(msg.Meta["platform"]), opts)
+		if err != nil {
+			return err
+		}
+		receipt := channel.ReceiptFrom(ctx)
+		receipt.Provider = provider
+		receipt.ProviderMessageID = id
+		return nil
+	}
+	return c.sendToUser(ctx, payload, msg.Meta["user_id"], opts)
+}
+
+// sendToUser delivers to every active device of a user. The delivery succeeds
+// when any device got the notification; otherwise it is retried unless every
+// device failed permanently
+func (c *PushChannel) sendToUser(ctx context.Context, payload pushPayload, userID string, opts PushOptions) error {
+	if c.Devices == nil {
+		return fmt.Errorf("push to user_id needs a device registry")
+	}
+	id, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil {
+		return channel.Permanent(fmt.Errorf("invalid user_id: %w", err))
+	}
+	devices, err := c.Devices.ActiveDevices(ctx, uint(id))
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		return channel.Permanent(fmt.Errorf("user %d has no active push devices", id))
+	}
+
+	var providers, ids []string
+	var errs []error
+	permanent := true
+	for _, device := range devices {
+		payload.Token = device.Token
+		provider, messageID, err := c.sendTo(ctx, payload, device.Platform, opts)
+		if err != nil {
+			// unwrapped so a transient failure on another device keeps the delivery retryable
+			var permanentErr *channel.PermanentError
+			if errors.As(err, &permanentErr) {
+				err = permanentErr.Err
+			} else {
+				permanent = false
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if provider != "" && !slices.Contains(providers, provider) {
+			providers = append(providers, provider)
+		}
+		if messageID != "" {
+			ids = append(ids, messageID)
+		}
+	}
+	if len(errs) == len(devices) {
+		if permanent {
+			return channel.Permanent(errors.Join(errs...))
+		}
+		return errors.Join(errs...)
+	}
+
+	receipt := channel.ReceiptFrom(ctx)
+	receipt.Provider = strings.Join(providers, ",")
+	receipt.ProviderMessageID = strings.Join(ids, ",")
+	return nil
+}
+
+// sendTo delivers to a single token and returns the provider used and the message ID
+func (c *PushChannel) sendTo(ctx context.Context, payload pushPayload, platform string, opts PushOptions) (string, string, error) {
+	if c.Tokens != nil {
+		invalid, err := c.Tokens.Invalid(ctx, payload.Token)
+		if err != nil {
+			return "", "", err
+		}
+		if invalid {
+			return "", "", channel.Permanent(fmt.Errorf("push token was invalidated"))
+		}
+	}
+	if len(c.Providers) == 0 {
+		b, _ := json.Marshal(payload)
+		log.Printf("Push to %s: %s", platform, b) // no provider configured
+		return "", "", nil
+	}
+
+	// the platform is fixed for the notification, so retrying can't route it
+	provider, ok := c.Providers[platform]
+	if !ok {
+		return "", "", channel.Permanent(fmt.Errorf("no push provider for platform %q", platform))
+	}
+	id, err := provider.SendPush(ctx, newPushMessage(payload, platform, opts))
+	var providerErr *PushProviderError
+	if errors.As(err, &providerErr) && providerErr.Permanent() {
+		if providerErr.TokenInvalid() && c.Tokens != nil {
+			if err := c.Tokens.Invalidate(ctx, payload.Token, platform, providerErr.Reason); err != nil {
+				return "", "", err
+			}
+		}
+		return "", "", channel.Permanent(err)
+	}
+	if err != nil {
+		return "", "", err
+	}
+	return provider.Name(), id, nil
+}
+
+// pushOptions reads meta.options, defaulting the priority from the message's
+func pushOptions(msg channel.Message) (PushOptions, error) {
+	opts, err := parsePushOptions(msg.Meta["options"])
+	if err != nil {
+		return opts, err
+	}
+	if opts.Priority == "" {
+		// low priority notifications shouldn't wake the device
+		switch msg.Priority {
+		case "high":
+			opts.Priority = PushPriorityHigh
+		case "low":
+			opts.Priority = PushPriorityNormal
+		}
+	}
+	return opts, nil
+}
+
+// Preview shows the body the provider of the platform would be sent: an FCM v1
+// message or an APNs payload. A notification for a user is rendered once per
+// platform of their devices, keyed by platform. Without a provider it shows what
+// is logged instead
+func (c *PushChannel) Preview(ctx context.Context, msg channel.Message) (channel.Preview, error) {
+	payload, err := buildPushPayload(msg)
+	if err != nil {
+		return channel.Preview{}, err
+	}
+	opts, err := pushOptions(msg)
+	if err != nil {
+		return channel.Preview{}, err
+	}
+	preview := channel.Preview{Subject: msg.Title, Text: msg.Content}
+
+	devices := []PushDevice{{Token: payload.Token, Platform: strings.ToLower(msg.Meta["platform"])}}
+	if payload.Token == "" && msg.Meta["user_id"] != "" && c.Devices != nil {
+		id, err := strconv.ParseUint(msg.Meta["user_id"], 10, 64)
+		if err != nil {
+			return channel.Preview{}, fmt.Errorf("invalid user_id: %w", err)
+		}
+		if devices, err = c.Devices.ActiveDevices(ctx, uint(id)); err != nil {
+			return channel.Preview{}, err
+		}
+		if len(devices) == 0 {
+			return channel.Preview{}, fmt.Errorf("user %d has no active push devices", id)
+		}
+	}
+
+	bodies := map[string]json.RawMessage{}
+	for _, device := range devices {
+		if _, ok := bodies[device.Platform]; ok {
+			continue
+		}
+		payload.Token = device.Token
+		body, err := c.render(payload, device.Platform, opts)
+		if err != nil {
+			return channel.Preview{}, err
+		}
+		bodies[device.Platform] = body
+	}
+	if msg.Meta["user_id"] == "" && len(bodies) == 1 {
+		for _, body := range bodies {
+			preview.Payload = body
+		}
+		return preview, nil
+	}
+	if preview.Payload, err = json.Marshal(bodies); err != nil {
+		return channel.Preview{}, err
+	}
+	return preview, nil
+}
+
+// render returns the body sendTo would send for a token on a platform
+func (c *PushChannel) render(payload pushPayload, platform string, opts PushOptions) ([]byte, error) {
+	if len(c.Providers) == 0 {
+		return json.Marshal(payload)
+	}
+	provider, ok := c.Providers[platform]
+	if !ok {
+		return nil, fmt.Errorf("no push provider for platform %q", platform)
+	}
+	renderer, ok := provider.(pushRenderer)
+	if !ok {
+		return json.Marshal(payload)
+	}
+	return renderer.Render(newPushMessage(payload, platform, opts))
+}
+
+func newPushMessage(payload pushPayload, platform string, opts PushOptions) PushMessage {
+	return PushMessage{
+		Token:    payload.Token,
+		Platform: platform,
+		Title:    payload.Title,
+		Body:     payload.Body,
+		Data:     payload.Data,
+		Options:  opts,
+	}
+}
+
+func buildPushPayload(msg channel.Message) (pushPayload, error) {
 	data := map[string]string{}
 	if s := msg.Meta["data"]; s != "" {
 		if err := json.Unmarshal([]byte(s), &data); err != nil {
-			return fmt.Errorf("invalid data json: %w", err)
+			return pushPayload{}, fmt.Errorf("invalid data json: %w", err)
 		}
 	}
-	payload := pushPayload{
+	return pushPayload{
 		Title: msg.Title,
 		Body:  msg.Content,
 		Data:  data,
 		Token: msg.Meta["token"],
-	}
-
-	b, _ := json.Marshal(payload)
-	fmt.Println(string(b)) // Replace with actual push notification sending logic
-	return nil
+	}, nil
 }
 
 func (c *PushChannel) Validate(meta map[string]string) error {
-	token := meta["token"]
-	if token == "" || len(token) < 10 || len(token) > 4096 {
+	token, userID := meta["token"], meta["user_id"]
+	switch {
+	case token == "" && userID == "" && meta[channel.MetaRecipientUserID] != "":
+		if _, err := channel.RecipientUserID(meta); err != nil {
+			return err
+		}
+		if c.Devices == nil {
+			return fmt.Errorf("push to recipient_user_id needs a device registry")
+		}
+	case token == "" && userID != "":
+		if id, err := strconv.ParseUint(userID, 10, 64); err != nil || id == 0 {
+			return fmt.Errorf("user_id must be a positive integer")
+		}
+		if c.Devices == nil {
+			return fmt.Errorf("push to user_id needs a device registry")
+		}
+	case token == "" || len(token) < 10 || len(token) > 4096:
 		return fmt.Errorf("invalid token")
 	}
+	platform := strings.ToLower(meta["platform"])
+	switch platform {
+	case "", PushPlatformAndroid, PushPlatformIOS, PushPlatformWeb:
+	default:
+		return fmt.Errorf("platform must be android, ios or web")
+	}
+	// devices of a user carry their own platform
+	if token != "" && len(c.Providers) > 0 {
+		if platform == "" {
+			return fmt.Errorf("platform is required with token")
+		}
+		if _, ok := c.Providers[platform]; !ok {
+			return fmt.Errorf("no push provider for platform %q", platform)
+		}
+	}
+	if _, err := parsePushOptions(meta["options"]); err != nil {
+		return err
+	}
 	return nil
 }
 
 func (c *PushChannel) Prepare(ctx context.Context, msg *channel.Message) error {
-	return nil
+	if err := msg.RenderVariables(false); err != nil {
+		return err
+	}
+	// a recipient is reached on all of their registered devices
+	if msg.Meta["token"] == "" && msg.Meta["user_id"] == "" && msg.Meta[channel.MetaRecipientUserID] != "" {
+		msg.Meta["user_id"] = msg.Meta[channel.MetaRecipientUserID]
+	}
+	return applyStoredTemplate(ctx, c.Templates, c.Name(), msg)
 }
@@ -0,0 +1,175 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/net/http2"
+)
+
+const (
+	APNsProductionURL = "https://api.push.apple.com"
+	APNsSandboxURL    = "https://api.sandbox.push.apple.com"
+
+	// APNs rejects tokens older than an hour and throttles refreshes more often than every 20 minutes
+	apnsTokenLifetime = 50 * time.Minute
+)
+
+// APNsProvider sends through the Apple Push Notification service HTTP/2 API,
+// authenticating with ES256 provider tokens signed by a .p8 key
+type APNsProvider struct {
+	KeyID  string
+	TeamID string
+	// Topic is the app's bundle ID
+	Topic string
+	Key   *ecdsa.PrivateKey
+	// BaseURL defaults to the production endpoint, https://api.push.apple.com
+	BaseURL string
+	// Client sends the requests, an HTTP/2 client with a 10s timeout is used when nil
+	Client *http.Client
+
+	mu       sync.Mutex
+	client   *http.Client
+	jwt      string
+	issuedAt time.Time
+}
+
+// NewAPNsProvider loads a .p8 signing key downloaded from the Apple developer account
+func NewAPNsProvider(keyFile, keyID, teamID, topic string) (*APNsProvider, error) {
+	b, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("apns: reading key: %w", err)
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(b)
+	if err != nil {
+		return nil, fmt.Errorf("apns: invalid key: %w", err)
+	}
+	if keyID == "" || teamID == "" || topic == "" {
+		return nil, fmt.Errorf("apns: key ID, team ID and topic are required")
+	}
+	return &APNsProvider{KeyID: keyID, TeamID: teamID, Topic: topic, Key: key}, nil
+}
+
+func (p *APNsProvider) Name() string {
+	return "apns"
+}
+
+// buildAPNsPayload puts the alert, badge and sound under aps and the data keys at the top level
+func buildAPNsPayload(push PushMessage) map[string]any {
+	aps := map[string]any{
+		"alert": map[string]string{"title": push.Title, "body": push.Body},
+	}
+	if push.Options.Badge != nil {
+		aps["badge"] = *push.Options.Badge
+	}
+	if push.Options.Sound != "" {
+		aps["sound"] = push.Options.Sound
+	}
+	payload := map[string]any{}
+	for k, v := range push.Data {
+		payload[k] = v
+	}
+	payload["aps"] = aps
+	return payload
+}
+
+// Render returns the APNs payload for push
+func (p *APNsProvider) Render(push PushMessage) ([]byte, error) {
+	return json.Marshal(buildAPNsPayload(push))
+}
+
+func (p *APNsProvider) SendPush(ctx context.Context, push PushMessage) (string, error) {
+	body, err := p.Render(push)
+	if err != nil {
+		return "", err
+	}
+	endpoint := strings.TrimRight(withDefault(p.BaseURL, APNsProductionURL), "/") + "/3/device/" + url.PathEscape(push.Token)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	token, err := p.token()
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apns-topic", p.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	switch push.Options.Priority {
+	case PushPriorityHigh:
+		req.Header.Set("apns-priority", "10")
+	case PushPriorityNormal:
+		req.Header.Set("apns-priority", "5")
+	}
+	if push.Options.TTL > 0 {
+		req.Header.Set("apns-expiration", strconv.FormatInt(time.Now().Add(push.Options.TTL).Unix(), 10))
+	}
+	if push.Options.CollapseKey != "" {
+		req.Header.Set("apns-collapse-id", push.Options.CollapseKey)
+	}
+
+	resp, err := p.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("apns: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var result struct {
+			Reason string `json:"reason"`
+		}
+		_ = json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result)
+		reason := result.Reason
+		if reason == "" {
+			reason = http.StatusText(resp.StatusCode)
+		}
+		return "", &PushProviderError{Provider: "apns", StatusCode: resp.StatusCode, Reason: reason}
+	}
+	return resp.Header.Get("apns-id"), nil
+}
+
+// token returns the provider token, signing a new one when it gets old
+func (p *APNsProvider) token() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.jwt != "" && time.Since(p.issuedAt) < apnsTokenLifetime {
+		return p.jwt, nil
+	}
+
+	now := time.Now()
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{"iss": p.TeamID, "iat": now.Unix()})
+	t.Header["kid"] = p.KeyID
+	signed, err := t.SignedString(p.Key)
+	if err != nil {
+		return "", fmt.Errorf("apns: signing token: %w", err)
+	}
+	p.jwt, p.issuedAt = signed, now
+	return signed, nil
+}
+
+// httpClient returns Client, or a shared HTTP/2 client so requests reuse one connection
+func (p *APNsProvider) httpClient() *http.Client {
+	if p.Client != nil {
+		return p.Client
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.client == nil {
+		p.client = &http.Client{Timeout: defaultPushTimeout, Transport: &http2.Transport{}}
+	}
+	return p.client
+}
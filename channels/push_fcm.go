@@ -0,0 +1,278 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	defaultFCMBaseURL  = "https://fcm.googleapis.com"
+	defaultFCMTokenURL = "https://oauth2.googleapis.com/token"
+	fcmScope           = "https://www.googleapis.com/auth/firebase.messaging"
+)
+
+// FCMProvider sends through the Firebase Cloud Messaging HTTP v1 API,
+// authenticating with OAuth access tokens obtained for a service account
+type FCMProvider struct {
+	ProjectID   string
+	ClientEmail string
+	PrivateKey  *rsa.PrivateKey
+	// TokenURL is the OAuth token endpoint, defaults to https://oauth2.googleapis.com/token
+	TokenURL string
+	// BaseURL defaults to https://fcm.googleapis.com
+	BaseURL string
+	// Client sends the requests, a client with a 10s timeout is used when nil
+	Client *http.Client
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+// NewFCMProvider loads a service account key file as downloaded from the Firebase console
+func NewFCMProvider(credentialsFile string) (*FCMProvider, error) {
+	b, err := os.ReadFile(credentialsFile)
+	if err != nil {
+		return nil, fmt.Errorf("fcm: reading credentials: %w", err)
+	}
+	var creds struct {
+		ProjectID   string `json:"project_id"`
+		ClientEmail string `json:"client_email"`
+		PrivateKey  string `json:"private_key"`
+		TokenURI    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(b, &creds); err != nil {
+		return nil, fmt.Errorf("fcm: invalid credentials: %w", err)
+	}
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(creds.PrivateKey))
+	if err != nil {
+		return nil, fmt.Errorf("fcm: invalid private key: %w", err)
+	}
+	if creds.ProjectID == "" || creds.ClientEmail == "" {
+		return nil, fmt.Errorf("fcm: credentials need project_id and client_email")
+	}
+	return &FCMProvider{
+		ProjectID:   creds.ProjectID,
+		ClientEmail: creds.ClientEmail,
+		PrivateKey:  key,
+		TokenURL:    creds.TokenURI,
+	}, nil
+}
+
+func (p *FCMProvider) Name() string {
+	return "fcm"
+}
+
+type fcmMessage struct {
+	Token        string            `json:"token"`
+	Notification fcmNotification   `json:"notification"`
+	Data         map[string]string `json:"data,omitempty"`
+	Android      *fcmAndroid       `json:"android,omitempty"`
+	Webpush      *fcmWebpush       `json:"webpush,omitempty"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+type fcmAndroid struct {
+	Priority     string                  `json:"priority,omitempty"`
+	TTL          string                  `json:"ttl,omitempty"`
+	CollapseKey  string                  `json:"collapse_key,omitempty"`
+	Notification *fcmAndroidNotification `json:"notification,omitempty"`
+}
+
+type fcmAndroidNotification struct {
+	Sound string `json:"sound,omitempty"`
+}
+
+type fcmWebpush struct {
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// buildFCMMessage maps the options to the android or webpush section of the message
+func buildFCMMessage(push PushMessage) fcmMessage {
+	msg := fcmMessage{
+		Token:        push.Token,
+		Notification: fcmNotification{Title: push.Title, Body: push.Body},
+		Data:         push.Data,
+	}
+	opts := push.Options
+
+	if push.Platform == PushPlatformWeb {
+		headers := map[string]string{}
+		if opts.TTL > 0 {
+			headers["TTL"] = strconv.Itoa(int(opts.TTL.Seconds()))
+		}
+		if opts.Priority != "" {
+			headers["Urgency"] = opts.Priority
+		}
+		if opts.CollapseKey != "" {
+			headers["Topic"] = opts.CollapseKey
+		}
+		if len(headers) > 0 {
+			msg.Webpush = &fcmWebpush{Headers: headers}
+		}
+		return msg
+	}
+
+	android := fcmAndroid{Priority: strings.ToUpper(opts.Priority), CollapseKey: opts.CollapseKey}
+	if opts.TTL > 0 {
+		android.TTL = strconv.Itoa(int(opts.TTL.Seconds())) + "s"
+	}
+	if opts.Sound != "" {
+		android.Notification = &fcmAndroidNotification{Sound: opts.Sound}
+	}
+	if android != (fcmAndroid{}) {
+		msg.Android = &android
+	}
+	return msg
+}
+
+// Render returns the HTTP v1 request body for push
+func (p *FCMProvider) Render(push PushMessage) ([]byte, error) {
+	return json.Marshal(map[string]fcmMessage{"message": buildFCMMessage(push)})
+}
+
+func (p *FCMProvider) SendPush(ctx context.Context, push PushMessage) (string, error) {
+	token, err := p.token(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	body, err := p.Render(push)
+	if err != nil {
+		return "", err
+	}
+	endpoint := strings.TrimRight(withDefault(p.BaseURL, defaultFCMBaseURL), "/") + "/v1/projects/" + url.PathEscape(p.ProjectID) + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := pushClient(p.Client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fcm: %w", err)
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return "", fmt.Errorf("fcm: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fcmError(resp.StatusCode, respBody)
+	}
+	var result struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", fmt.Errorf("fcm: invalid response: %w", err)
+	}
+	return result.Name, nil
+}
+
+// fcmError reads the FCM error code (e.g. UNREGISTERED) from the error details,
+// falling back to the generic status
+func fcmError(statusCode int, body []byte) *PushProviderError {
+	var result struct {
+		Error struct {
+			Message string `json:"message"`
+			Status  string `json:"status"`
+			Details []struct {
+				ErrorCode string `json:"errorCode"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	_ = json.Unmarshal(body, &result)
+
+	reason := result.Error.Status
+	for _, detail := range result.Error.Details {
+		if detail.ErrorCode != "" {
+			reason = detail.ErrorCode
+			break
+		}
+	}
+	if reason == "" {
+		reason = http.StatusText(statusCode)
+	}
+	return &PushProviderError{Provider: "fcm", StatusCode: statusCode, Reason: reason, Message: result.Error.Message}
+}
+
+// token returns a cached access token, requesting a new one with a signed
+// service account assertion shortly before it expires
+func (p *FCMProvider) token(ctx context.Context) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.accessToken != "" && time.Now().Before(p.expiresAt) {
+		return p.accessToken, nil
+	}
+
+	tokenURL := withDefault(p.TokenURL, defaultFCMTokenURL)
+	now := time.Now()
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":   p.ClientEmail,
+		"scope": fcmScope,
+		"aud":   tokenURL,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}).SignedString(p.PrivateKey)
+	if err != nil {
+		return "", fmt.Errorf("fcm: signing assertion: %w", err)
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := pushClient(p.Client).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fcm: token: %w", err)
+	}
+	defer resp.Body.Close()
+	var result struct {
+		AccessToken      string `json:"access_token"`
+		ExpiresIn        int    `json:"expires_in"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil && resp.StatusCode == http.StatusOK {
+		return "", fmt.Errorf("fcm: token: invalid response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || result.AccessToken == "" {
+		return "", fmt.Errorf("fcm: token: status %d: %s", resp.StatusCode, result.ErrorDescription)
+	}
+
+	p.accessToken = result.AccessToken
+	// refresh a minute early so a token doesn't expire in flight
+	p.expiresAt = now.Add(time.Duration(result.ExpiresIn)*time.Second - time.Minute)
+	return p.accessToken, nil
+}
+
+func pushClient(c *http.Client) *http.Client {
+	if c == nil {
+		return &http.Client{Timeout: defaultPushTimeout}
+	}
+	return c
+}
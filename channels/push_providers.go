@@ -0,0 +1,172 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+const (
+	PushPlatformAndroid = "android"
+	PushPlatformIOS     = "ios"
+	PushPlatformWeb     = "web"
+
+	PushPriorityHigh   = "high"
+	PushPriorityNormal = "normal"
+
+	defaultPushTimeout = 10 * time.Second
+)
+
+// PushProvider delivers a notification to a single device and returns the
+// message ID assigned by the provider
+type PushProvider interface {
+	Name() string
+	SendPush(ctx context.Context, push PushMessage) (messageID string, err error)
+}
+
+// pushRenderer is implemented by providers that can show the request body they
+// would send, for previews
+type pushRenderer interface {
+	Render(push PushMessage) ([]byte, error)
+}
+
+// PushMessage is a notification addressed to one device token
+type PushMessage struct {
+	Token    string
+	Platform string
+	Title    string
+	Body     string
+	Data     map[string]string
+	Options  PushOptions
+}
+
+// PushOptions are the delivery options read from meta.options
+type PushOptions struct {
+	// Priority is "high" or "normal"; providers use their default when empty
+	Priority string
+	// TTL is how long the provider keeps an undelivered message; zero uses the provider default
+	TTL time.Duration
+	// CollapseKey groups messages so only the latest is delivered to an offline device
+	CollapseKey string
+	// Badge sets the app icon badge on iOS; nil leaves it unchanged
+	Badge *int
+	Sound string
+}
+
+// parsePushOptions reads meta.options, a JSON object whose values may be strings
+// or numbers. ttl is a number of seconds or a duration such as "1h"
+func parsePushOptions(s string) (PushOptions, error) {
+	var opts PushOptions
+	if s == "" {
+		return opts, nil
+	}
+	var raw map[string]any
+	if err := json.Unmarshal([]byte(s), &raw); err != nil {
+		return opts, fmt.Errorf("invalid options json: %w", err)
+	}
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		if str, ok := v.(string); ok {
+			values[k] = str
+		} else {
+			values[k] = fmt.Sprint(v)
+		}
+	}
+
+	switch values["priority"] {
+	case "", PushPriorityHigh, PushPriorityNormal:
+		opts.Priority = values["priority"]
+	default:
+		return opts, fmt.Errorf("invalid priority %q, expected high or normal", values["priority"])
+	}
+	if ttl := values["ttl"]; ttl != "" {
+		if seconds, err := strconv.Atoi(ttl); err == nil {
+			opts.TTL = time.Duration(seconds) * time.Second
+		} else if opts.TTL, err = time.ParseDuration(ttl); err != nil {
+			return opts, fmt.Errorf("invalid ttl %q", ttl)
+		}
+		if opts.TTL < 0 {
+			return opts, fmt.Errorf("invalid ttl %q", ttl)
+		}
+	}
+	if badge := values["badge"]; badge != "" {
+		n, err := strconv.Atoi(badge)
+		if err != nil || n < 0 {
+			return opts, fmt.Errorf("invalid badge %q", badge)
+		}
+		opts.Badge = &n
+	}
+	opts.CollapseKey = values["collapse_key"]
+	opts.Sound = values["sound"]
+	return opts, nil
+}
+
+// PushProviderError is returned when the provider rejects a notification
+type PushProviderError struct {
+	Provider   string
+	StatusCode int
+	// Reason is the provider's error code, e.g. UNREGISTERED or BadDeviceToken
+	Reason  string
+	Message string
+}
+
+func (e *PushProviderError) Error() string {
+	if e.Message == "" || e.Message == e.Reason {
+		return fmt.Sprintf("%s: status %d: %s", e.Provider, e.StatusCode, e.Reason)
+	}
+	return fmt.Sprintf("%s: status %d: %s: %s", e.Provider, e.StatusCode, e.Reason, e.Message)
+}
+
+// TokenInvalid reports whether the device token is no longer usable, e.g. the
+// app was uninstalled
+func (e *PushProviderError) TokenInvalid() bool {
+	switch e.Reason {
+	case "UNREGISTERED", "SENDER_ID_MISMATCH", // FCM
+		"BadDeviceToken", "Unregistered", "DeviceTokenNotForTopic": // APNs
+		return true
+	}
+	return false
+}
+
+// Permanent reports whether the notification itself was rejected, so sending it
+// again can't succeed. Authentication errors aren't permanent: they fail every
+// notification until the credentials are fixed
+func (e *PushProviderError) Permanent() bool {
+	switch e.StatusCode {
+	case http.StatusBadRequest, http.StatusNotFound, http.StatusGone, http.StatusRequestEntityTooLarge:
+		return true
+	}
+	return e.TokenInvalid()
+}
+
+// PushProvidersFromEnv configures FCM for Android and web when FCM_CREDENTIALS_FILE
+// points to a service account key, and APNs for iOS when APNS_KEY_FILE points to
+// a .p8 signing key. Providers are keyed by platform
+func PushProvidersFromEnv() (map[string]PushProvider, error) {
+	providers := make(map[string]PushProvider)
+	if path := os.Getenv("FCM_CREDENTIALS_FILE"); path != "" {
+		fcm, err := NewFCMProvider(path)
+		if err != nil {
+			return nil, err
+		}
+		fcm.BaseURL = os.Getenv("FCM_BASE_URL")
+		providers[PushPlatformAndroid] = fcm
+		providers[PushPlatformWeb] = fcm
+	}
+	if path := os.Getenv("APNS_KEY_FILE"); path != "" {
+		apns, err := NewAPNsProvider(path, os.Getenv("APNS_KEY_ID"), os.Getenv("APNS_TEAM_ID"), os.Getenv("APNS_TOPIC"))
+		if err != nil {
+			return nil, err
+		}
+		apns.BaseURL = os.Getenv("APNS_BASE_URL")
+		if apns.BaseURL == "" && os.Getenv("APNS_SANDBOX") == "true" {
+			apns.BaseURL = APNsSandboxURL
+		}
+		providers[PushPlatformIOS] = apns
+	}
+	return providers, nil
+}
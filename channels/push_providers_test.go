@@ -0,0 +1,600 @@
+package channels
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"notification/models/channel"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestParsePushOptions(t *testing.T) {
+	opts, err := parsePushOptions(`{"priority":"high","ttl":3600,"collapse_key":"chat-7","badge":3,"sound":"ping.caf"}`)
+	if err != nil {
+		t.Fatalf("parsePushOptions: %v", err)
+	}
+	if opts.Priority != PushPriorityHigh || opts.TTL != time.Hour || opts.CollapseKey != "chat-7" ||
+		opts.Badge == nil || *opts.Badge != 3 || opts.Sound != "ping.caf" {
+		t.Fatalf("unexpected options: %+v", opts)
+	}
+
+	if opts, err := parsePushOptions(`{"ttl":"90m"}`); err != nil || opts.TTL != 90*time.Minute {
+		t.Fatalf("expected duration ttl, got %+v, %v", opts, err)
+	}
+	for _, s := range []string{`{"priority":"urgent"}`, `{"ttl":"soon"}`, `{"badge":-1}`, `not json`} {
+		if _, err := parsePushOptions(s); err == nil {
+			t.Errorf("%s: expected error", s)
+		}
+	}
+}
+
+// fcmStandIn serves the OAuth token endpoint and the v1 send endpoint
+func fcmStandIn(t *testing.T, key *rsa.PrivateKey, send http.HandlerFunc) (*httptest.Server, *int32) {
+	t.Helper()
+	var tokenRequests int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&tokenRequests, 1)
+		if r.FormValue("grant_type") != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("unexpected grant_type %q", r.FormValue("grant_type"))
+		}
+		claims := jwt.MapClaims{}
+		if _, err := jwt.ParseWithClaims(r.FormValue("assertion"), claims, func(*jwt.Token) (any, error) {
+			return &key.PublicKey, nil
+		}); err != nil {
+			t.Errorf("invalid assertion: %v", err)
+		}
+		if claims["iss"] != "push@example.iam.gserviceaccount.com" || claims["scope"] != fcmScope {
+			t.Errorf("unexpected claims %v", claims)
+		}
+		w.Write([]byte(`{"access_token":"ya29.test","expires_in":3600,"token_type":"Bearer"}`))
+	})
+	mux.HandleFunc("POST /v1/projects/demo-app/messages:send", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer ya29.test" {
+			t.Errorf("unexpected authorization %q", r.Header.Get("Authorization"))
+		}
+		send(w, r)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, &tokenRequests
+}
+
+func writeFCMCredentials(t *testing.T, key *rsa.PrivateKey, tokenURL string) string {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	creds, _ := json.Marshal(map[string]string{
+		"type":         "service_account",
+		"project_id":   "demo-app",
+		"client_email": "push@example.iam.gserviceaccount.com",
+		"private_key":  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		"token_uri":    tokenURL,
+	})
+	path := filepath.Join(t.TempDir(), "service-account.json")
+	if err := os.WriteFile(path, creds, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFCMProvider_Send(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]fcmMessage
+	srv, tokenRequests := fcmStandIn(t, key, func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+		w.Write([]byte(`{"name":"projects/demo-app/messages/0:1234"}`))
+	})
+
+	p, err := NewFCMProvider(writeFCMCredentials(t, key, srv.URL+"/token"))
+	if err != nil {
+		t.Fatalf("NewFCMProvider: %v", err)
+	}
+	p.BaseURL = srv.URL
+
+	push := PushMessage{
+		Token:    "fcm-token-123",
+		Platform: PushPlatformAndroid,
+		Title:    "Hi",
+		Body:     "New message",
+		Data:     map[string]string{"chat_id": "7"},
+		Options:  PushOptions{Priority: PushPriorityHigh, TTL: time.Hour, CollapseKey: "chat-7", Sound: "default"},
+	}
+	for i := 0; i < 2; i++ {
+		id, err := p.SendPush(context.Background(), push)
+		if err != nil {
+			t.Fatalf("SendPush: %v", err)
+		}
+		if id != "projects/demo-app/messages/0:1234" {
+			t.Fatalf("unexpected id %q", id)
+		}
+	}
+	if n := atomic.LoadInt32(tokenRequests); n != 1 {
+		t.Fatalf("expected the access token to be cached, got %d token requests", n)
+	}
+
+	msg := got["message"]
+	if msg.Token != "fcm-token-123" || msg.Notification.Title != "Hi" || msg.Data["chat_id"] != "7" {
+		t.Fatalf("unexpected message %+v", msg)
+	}
+	if msg.Android == nil || msg.Android.Priority != "HIGH" || msg.Android.TTL != "3600s" ||
+		msg.Android.CollapseKey != "chat-7" || msg.Android.Notification.Sound != "default" {
+		t.Fatalf("unexpected android options %+v", msg.Android)
+	}
+}
+
+func TestFCMProvider_Webpush(t *testing.T) {
+	msg := buildFCMMessage(PushMessage{
+		Platform: PushPlatformWeb,
+		Options:  PushOptions{Priority: PushPriorityNormal, TTL: time.Minute, CollapseKey: "news"},
+	})
+	if msg.Android != nil || msg.Webpush == nil {
+		t.Fatalf("expected webpush options only, got %+v", msg)
+	}
+	h := msg.Webpush.Headers
+	if h["TTL"] != "60" || h["Urgency"] != "normal" || h["Topic"] != "news" {
+		t.Fatalf("unexpected webpush headers %v", h)
+	}
+}
+
+func TestFCMProvider_Error(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, _ := fcmStandIn(t, key, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"code":404,"message":"Requested entity was not found.","status":"NOT_FOUND",
+			"details":[{"@type":"type.googleapis.com/google.firebase.fcm.v1.FcmError","errorCode":"UNREGISTERED"}]}}`))
+	})
+	p := &FCMProvider{ProjectID: "demo-app", ClientEmail: "push@example.iam.gserviceaccount.com", PrivateKey: key,
+		TokenURL: srv.URL + "/token", BaseURL: srv.URL}
+
+	_, err = p.SendPush(context.Background(), PushMessage{Token: "stale-token", Platform: PushPlatformAndroid})
+	var providerErr *PushProviderError
+	if !errors.As(err, &providerErr) {
+		t.Fatalf("expected PushProviderError, got %v", err)
+	}
+	if providerErr.Reason != "UNREGISTERED" || providerErr.StatusCode != http.StatusNotFound || !providerErr.Permanent() {
+		t.Fatalf("unexpected error %+v", providerErr)
+	}
+}
+
+func writeAPNsKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "AuthKey_ABC123.p8")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return key, path
+}
+
+func TestAPNsProvider_Send(t *testing.T) {
+	key, path := writeAPNsKey(t)
+	var payload map[string]any
+	var header http.Header
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 {
+			t.Errorf("expected HTTP/2, got %s", r.Proto)
+		}
+		if r.URL.Path != "/3/device/apns-token-123" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		header = r.Header.Clone()
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &payload)
+		w.Header().Set("apns-id", "EC1BF194-B3B2-424A-89A9-5A918A6E6B5E")
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	defer srv.Close()
+
+	p, err := NewAPNsProvider(path, "ABC123", "TEAM42", "com.example.app")
+	if err != nil {
+		t.Fatalf("NewAPNsProvider: %v", err)
+	}
+	p.BaseURL = srv.URL
+	p.Client = srv.Client()
+
+	badge := 4
+	id, err := p.SendPush(context.Background(), PushMessage{
+		Token:    "apns-token-123",
+		Platform: PushPlatformIOS,
+		Title:    "Hi",
+		Body:     "New message",
+		Data:     map[string]string{"chat_id": "7"},
+		Options:  PushOptions{Priority: PushPriorityNormal, TTL: time.Hour, CollapseKey: "chat-7", Badge: &badge, Sound: "ping.caf"},
+	})
+	if err != nil {
+		t.Fatalf("SendPush: %v", err)
+	}
+	if id != "EC1BF194-B3B2-424A-89A9-5A918A6E6B5E" {
+		t.Fatalf("unexpected id %q", id)
+	}
+
+	if header.Get("apns-topic") != "com.example.app" || header.Get("apns-priority") != "5" ||
+		header.Get("apns-collapse-id") != "chat-7" || header.Get("apns-push-type") != "alert" {
+		t.Fatalf("unexpected headers %v", header)
+	}
+	expiration, _ := strconv.ParseInt(header.Get("apns-expiration"), 10, 64)
+	if d := time.Until(time.Unix(expiration, 0)); d < 59*time.Minute || d > time.Hour {
+		t.Fatalf("unexpected expiration %s", header.Get("apns-expiration"))
+	}
+
+	claims := jwt.MapClaims{}
+	token, err := jwt.ParseWithClaims(header.Get("Authorization")[len("bearer "):], claims, func(*jwt.Token) (any, error) {
+		return &key.PublicKey, nil
+	}, jwt.WithValidMethods([]string{"ES256"}))
+	if err != nil || token.Header["kid"] != "ABC123" || claims["iss"] != "TEAM42" {
+		t.Fatalf("invalid provider token: %v %v", err, claims)
+	}
+
+	aps := payload["aps"].(map[string]any)
+	alert := aps["alert"].(map[string]any)
+	if alert["title"] != "Hi" || alert["body"] != "New message" || aps["badge"] != float64(4) ||
+		aps["sound"] != "ping.caf" || payload["chat_id"] != "7" {
+		t.Fatalf("unexpected payload %v", payload)
+	}
+}
+
+func TestAPNsProvider_Error(t *testing.T) {
+	_, path := writeAPNsKey(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"reason":"BadDeviceToken"}`))
+	}))
+	defer srv.Close()
+
+	p, err := NewAPNsProvider(path, "ABC123", "TEAM42", "com.example.app")
+	if err != nil {
+		t.Fatalf("NewAPNsProvider: %v", err)
+	}
+	p.BaseURL = srv.URL
+	p.Client = srv.Client()
+
+	_, err = p.SendPush(context.Background(), PushMessage{Token: "bad", Platform: PushPlatformIOS})
+	var providerErr *PushProviderError
+	if !errors.As(err, &providerErr) || providerErr.Reason != "BadDeviceToken" || providerErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+type fakePushProvider struct {
+	name string
+	sent []PushMessage
+}
+
+func (p *fakePushProvider) Name() string { return p.name }
+
+func (p *fakePushProvider) SendPush(ctx context.Context, push PushMessage) (string, error) {
+	p.sent = append(p.sent, push)
+	return p.name + "-1", nil
+}
+
+func TestPushSend_NotificationPriority(t *testing.T) {
+	fcm := &fakePushProvider{name: "fcm"}
+	c := &PushChannel{Providers: map[string]PushProvider{PushPlatformAndroid: fcm}}
+	tests := []struct {
+		priority, options, want string
+	}{
+		{"high", "", PushPriorityHigh},
+		{"low", "", PushPriorityNormal},
+		{"normal", "", ""},
+		{"low", `{"priority":"high"}`, PushPriorityHigh},
+	}
+	for _, tt := range tests {
+		msg := channel.Message{Title: "t", Priority: tt.priority, Meta: map[string]string{"token": "device_token_1234567890", "platform": "android", "options": tt.options}}
+		if err := c.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+		if got := fcm.sent[len(fcm.sent)-1].Options.Priority; got != tt.want {
+			t.Errorf("priority %s with options %q: expected %q, got %q", tt.priority, tt.options, tt.want, got)
+		}
+	}
+}
+
+func TestPushSend_RoutesByPlatform(t *testing.T) {
+	fcm := &fakePushProvider{name: "fcm"}
+	apns := &fakePushProvider{name: "apns"}
+	c := &PushChannel{Providers: map[string]PushProvider{
+		PushPlatformAndroid: fcm,
+		PushPlatformWeb:     fcm,
+		PushPlatformIOS:     apns,
+	}}
+
+	tests := []struct {
+		platform string
+		want     *fakePushProvider
+	}{
+		{"android", fcm},
+		{"iOS", apns},
+		{"web", fcm},
+	}
+	for _, tt := range tests {
+		receipt := &channel.Receipt{}
+		msg := channel.Message{
+			Title:   "Hi",
+			Content: "Body",
+			Meta:    map[string]string{"token": "device_token_xyz123", "platform": tt.platform, "options": `{"priority":"high"}`},
+		}
+		if err := c.Send(channel.WithReceipt(context.Background(), receipt), msg); err != nil {
+			t.Fatalf("%s: Send: %v", tt.platform, err)
+		}
+		if receipt.Provider != tt.want.name || receipt.ProviderMessageID != tt.want.name+"-1" {
+			t.Errorf("%s: unexpected receipt %+v", tt.platform, receipt)
+		}
+		last := tt.want.sent[len(tt.want.sent)-1]
+		if last.Options.Priority != PushPriorityHigh || last.Token != "device_token_xyz123" {
+			t.Errorf("%s: unexpected push %+v", tt.platform, last)
+		}
+	}
+
+	delete(c.Providers, PushPlatformWeb)
+	msg := channel.Message{Meta: map[string]string{"token": "device_token_xyz123", "platform": "web"}}
+	var permanent *channel.PermanentError
+	if err := c.Send(context.Background(), msg); !errors.As(err, &permanent) {
+		t.Fatalf("expected a permanent error for platform without provider, got %v", err)
+	}
+	msg = channel.Message{Meta: map[string]string{"token": "device_token_xyz123"}}
+	if err := c.Send(context.Background(), msg); !errors.As(err, &permanent) {
+		t.Fatalf("expected a permanent error without platform, got %v", err)
+	}
+}
+
+func TestPushValidate_PlatformAndOptions(t *testing.T) {
+	c := &PushChannel{}
+	if err := c.Validate(map[string]string{"token": "device_token_xyz123", "platform": "windows"}); err == nil {
+		t.Fatal("expected error for unknown platform")
+	}
+	if err := c.Validate(map[string]string{"token": "device_token_xyz123", "platform": "ios", "options": `{"badge":"many"}`}); err == nil {
+		t.Fatal("expected error for invalid options")
+	}
+	if err := c.Validate(map[string]string{"token": "device_token_xyz123", "platform": "ios", "options": `{"badge":1,"sound":"default"}`}); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+
+	// with providers configured a token must say where to route it
+	c.Providers = map[string]PushProvider{PushPlatformAndroid: &failingPushProvider{}}
+	if err := c.Validate(map[string]string{"token": "device_token_xyz123"}); err == nil {
+		t.Fatal("expected error for token without platform")
+	}
+	if err := c.Validate(map[string]string{"token": "device_token_xyz123", "platform": "ios"}); err == nil {
+		t.Fatal("expected error for platform without provider")
+	}
+	if err := c.Validate(map[string]string{"token": "device_token_xyz123", "platform": "android"}); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+}
+
+type failingPushProvider struct {
+	err   error
+	calls int
+}
+
+func (p *failingPushProvider) Name() string { return "fcm" }
+
+func (p *failingPushProvider) SendPush(ctx context.Context, push PushMessage) (string, error) {
+	p.calls++
+	return "", p.err
+}
+
+type memoryPushTokens map[string]string
+
+func (m memoryPushTokens) Invalid(ctx context.Context, token string) (bool, error) {
+	_, ok := m[token]
+	return ok, nil
+}
+
+func (m memoryPushTokens) Invalidate(ctx context.Context, token, platform, reason string) error {
+	m[token] = reason
+	return nil
+}
+
+func TestPushSend_InvalidatesDeadTokens(t *testing.T) {
+	provider := &failingPushProvider{err: &PushProviderError{Provider: "fcm", StatusCode: http.StatusNotFound, Reason: "UNREGISTERED"}}
+	tokens := memoryPushTokens{}
+	c := &PushChannel{Providers: map[string]PushProvider{PushPlatformAndroid: provider}, Tokens: tokens}
+	msg := channel.Message{Meta: map[string]string{"token": "device_token_xyz123", "platform": "android"}}
+
+	err := c.Send(context.Background(), msg)
+	var permanent *channel.PermanentError
+	if !errors.As(err, &permanent) {
+		t.Fatalf("expected a permanent error, got %v", err)
+	}
+	if tokens["device_token_xyz123"] != "UNREGISTERED" {
+		t.Fatalf("expected token to be invalidated, got %v", tokens)
+	}
+
+	// later notifications skip the provider
+	err = c.Send(context.Background(), msg)
+	if !errors.As(err, &permanent) || provider.calls != 1 {
+		t.Fatalf("expected the invalid token to be skipped, got %v after %d calls", err, provider.calls)
+	}
+}
+
+func TestPushSend_ClassifiesProviderErrors(t *testing.T) {
+	tests := []struct {
+		err       error
+		permanent bool
+	}{
+		{&PushProviderError{StatusCode: http.StatusBadRequest, Reason: "INVALID_ARGUMENT"}, true},
+		{&PushProviderError{StatusCode: http.StatusGone, Reason: "Unregistered"}, true},
+		{&PushProviderError{StatusCode: http.StatusTooManyRequests, Reason: "TooManyRequests"}, false},
+		{&PushProviderError{StatusCode: http.StatusServiceUnavailable, Reason: "UNAVAILABLE"}, false},
+		{&PushProviderError{StatusCode: http.StatusForbidden, Reason: "InvalidProviderToken"}, false},
+		{errors.New("connection reset"), false},
+	}
+	for _, tt := range tests {
+		tokens := memoryPushTokens{}
+		c := &PushChannel{Providers: map[string]PushProvider{PushPlatformIOS: &failingPushProvider{err: tt.err}}, Tokens: tokens}
+		err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"token": "device_token_xyz123", "platform": "ios"}})
+		var permanent *channel.PermanentError
+		if errors.As(err, &permanent) != tt.permanent {
+			t.Errorf("%v: expected permanent=%v, got %v", tt.err, tt.permanent, err)
+		}
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%v: expected the provider error to be wrapped, got %v", tt.err, err)
+		}
+	}
+}
+
+type staticPushDevices []PushDevice
+
+func (d staticPushDevices) ActiveDevices(ctx context.Context, userID uint) ([]PushDevice, error) {
+	if userID != 7 {
+		return nil, nil
+	}
+	return d, nil
+}
+
+// platformPushProvider fails for the tokens in errs and records the others
+type platformPushProvider struct {
+	name string
+	errs map[string]error
+	sent []string
+}
+
+func (p *platformPushProvider) Name() string { return p.name }
+
+func (p *platformPushProvider) SendPush(ctx context.Context, push PushMessage) (string, error) {
+	if err := p.errs[push.Token]; err != nil {
+		return "", err
+	}
+	p.sent = append(p.sent, push.Token)
+	return p.name + "-" + push.Token, nil
+}
+
+func TestPushSend_FansOutToUserDevices(t *testing.T) {
+	unregistered := &PushProviderError{Provider: "apns", StatusCode: http.StatusGone, Reason: "Unregistered"}
+	fcm := &platformPushProvider{name: "fcm"}
+	apns := &platformPushProvider{name: "apns", errs: map[string]error{"ios_token_dead": unregistered}}
+	tokens := memoryPushTokens{}
+	c := &PushChannel{
+		Providers: map[string]PushProvider{PushPlatformAndroid: fcm, PushPlatformWeb: fcm, PushPlatformIOS: apns},
+		Tokens:    tokens,
+		Devices: staticPushDevices{
+			{Token: "android_token", Platform: "android"},
+			{Token: "ios_token_dead", Platform: "ios"},
+			{Token: "ios_token", Platform: "ios"},
+		},
+	}
+
+	meta := map[string]string{"user_id": "7"}
+	if err := c.Validate(meta); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	receipt := &channel.Receipt{}
+	if err := c.Send(channel.WithReceipt(context.Background(), receipt), channel.Message{Title: "Hi", Meta: meta}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if len(fcm.sent) != 1 || len(apns.sent) != 1 || tokens["ios_token_dead"] != "Unregistered" {
+		t.Fatalf("unexpected deliveries: fcm %v, apns %v, invalidated %v", fcm.sent, apns.sent, tokens)
+	}
+	if receipt.Provider != "fcm,apns" || receipt.ProviderMessageID != "fcm-android_token,apns-ios_token" {
+		t.Fatalf("unexpected receipt %+v", receipt)
+	}
+
+	// a user without devices can't be reached
+	err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"user_id": "8"}})
+	var permanent *channel.PermanentError
+	if !errors.As(err, &permanent) {
+		t.Fatalf("expected a permanent error, got %v", err)
+	}
+}
+
+func TestPushSend_FanOutRetriesTransientFailures(t *testing.T) {
+	unavailable := &PushProviderError{Provider: "fcm", StatusCode: http.StatusServiceUnavailable, Reason: "UNAVAILABLE"}
+	c := &PushChannel{
+		Providers: map[string]PushProvider{PushPlatformAndroid: &platformPushProvider{name: "fcm", errs: map[string]error{
+			"android_token_a": unavailable,
+			"android_token_b": &PushProviderError{Provider: "fcm", StatusCode: http.StatusNotFound, Reason: "UNREGISTERED"},
+		}}},
+		Devices: staticPushDevices{{Token: "android_token_a", Platform: "android"}, {Token: "android_token_b", Platform: "android"}},
+	}
+	err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"user_id": "7"}})
+	var permanent *channel.PermanentError
+	if err == nil || errors.As(err, &permanent) || !errors.Is(err, unavailable) {
+		t.Fatalf("expected a transient error, got %v", err)
+	}
+}
+
+func TestPushValidate_UserID(t *testing.T) {
+	c := &PushChannel{}
+	if err := c.Validate(map[string]string{"user_id": "7"}); err == nil {
+		t.Fatal("expected error without a device registry")
+	}
+	c.Devices = staticPushDevices{}
+	if err := c.Validate(map[string]string{"user_id": "seven"}); err == nil {
+		t.Fatal("expected error for invalid user_id")
+	}
+	if err := c.Validate(map[string]string{"user_id": "7"}); err != nil {
+		t.Fatalf("Validate failed: %v", err)
+	}
+}
+
+func TestPushPreview_ProviderBody(t *testing.T) {
+	c := &PushChannel{
+		Providers: map[string]PushProvider{PushPlatformAndroid: &FCMProvider{}, PushPlatformIOS: &APNsProvider{}},
+		Devices:   staticPushDevices{{Token: "ios_token_1", Platform: PushPlatformIOS}, {Token: "android_token_1", Platform: PushPlatformAndroid}},
+	}
+
+	msg := channel.Message{Title: "Title", Content: "Body", Priority: "high", Meta: map[string]string{"token": "android_token_1", "platform": "android"}}
+	preview, err := c.Preview(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	var fcm struct {
+		Message fcmMessage `json:"message"`
+	}
+	if err := json.Unmarshal(preview.Payload, &fcm); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if fcm.Message.Token != "android_token_1" || fcm.Message.Notification.Title != "Title" || fcm.Message.Android == nil || fcm.Message.Android.Priority != "HIGH" {
+		t.Fatalf("expected the FCM v1 message, got %s", preview.Payload)
+	}
+
+	// a user's devices are rendered per platform
+	msg.Meta = map[string]string{"user_id": "7"}
+	if preview, err = c.Preview(context.Background(), msg); err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	var bodies map[string]map[string]any
+	if err := json.Unmarshal(preview.Payload, &bodies); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if _, ok := bodies[PushPlatformIOS]["aps"]; !ok {
+		t.Fatalf("expected an APNs payload for ios, got %s", preview.Payload)
+	}
+	if _, ok := bodies[PushPlatformAndroid]["message"]; !ok {
+		t.Fatalf("expected an FCM message for android, got %s", preview.Payload)
+	}
+}
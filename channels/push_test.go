@@ -1,15 +1,29 @@
 package channels
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
-	"io"
+	"log"
 	"notification/models/channel"
 	"os"
 	"strings"
 	"testing"
 )
 
+// captureLog collects what the standard logger writes during a test
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	})
+	return &buf
+}
+
 func TestPushValidate_OK(t *testing.T) {
 	c := &PushChannel{}
 	meta := map[string]string{
@@ -62,21 +76,12 @@ func TestPushSend_OK(t *testing.T) {
 		},
 	}
 
-	old := os.Stdout
-	r, w, err := os.Pipe()
-	if err != nil {
-		t.Fatalf("pipe: %v", err)
-	}
-	os.Stdout = w
-	defer func() { os.Stdout = old }()
-
+	logged := captureLog(t)
 	if err := c.Send(context.Background(), msg); err != nil {
 		t.Fatalf("Send failed: %v", err)
 	}
-
-	_ = w.Close()
-	out, _ := io.ReadAll(r)
-	output := string(out)
+	// the payload is logged as "Push to <platform>: <json>"
+	_, output, _ := strings.Cut(strings.TrimSpace(logged.String()), ": ")
 
 	if !strings.Contains(output, "Test Notification") {
 		t.Fatalf("expected output to contain title, got: %q", output)
@@ -101,21 +106,12 @@ func TestPushSend_WithData(t *testing.T) {
 		},
 	}
 
-	old := os.Stdout
-	r, w, err := os.Pipe()
-	if err != nil {
-		t.Fatalf("pipe: %v", err)
-	}
-	os.Stdout = w
-	defer func() { os.Stdout = old }()
-
+	logged := captureLog(t)
 	if err := c.Send(context.Background(), msg); err != nil {
 		t.Fatalf("Send failed: %v", err)
 	}
-
-	_ = w.Close()
-	out, _ := io.ReadAll(r)
-	output := string(out)
+	// the payload is logged as "Push to <platform>: <json>"
+	_, output, _ := strings.Cut(strings.TrimSpace(logged.String()), ": ")
 
 	var payload pushPayload
 	if err := json.Unmarshal([]byte(output), &payload); err != nil {
@@ -166,21 +162,12 @@ func TestPushSend_EmptyData(t *testing.T) {
 		},
 	}
 
-	old := os.Stdout
-	r, w, err := os.Pipe()
-	if err != nil {
-		t.Fatalf("pipe: %v", err)
-	}
-	os.Stdout = w
-	defer func() { os.Stdout = old }()
-
+	logged := captureLog(t)
 	if err := c.Send(context.Background(), msg); err != nil {
 		t.Fatalf("Send failed: %v", err)
 	}
-
-	_ = w.Close()
-	out, _ := io.ReadAll(r)
-	output := string(out)
+	// the payload is logged as "Push to <platform>: <json>"
+	_, output, _ := strings.Cut(strings.TrimSpace(logged.String()), ": ")
 
 	var payload pushPayload
 	if err := json.Unmarshal([]byte(output), &payload); err != nil {
@@ -198,3 +185,24 @@ func TestPushName(t *testing.T) {
 		t.Fatalf("expected name 'push', got %q", c.Name())
 	}
 }
+
+func TestPushPreview_Payload(t *testing.T) {
+	c := &PushChannel{}
+	msg := channel.Message{
+		Title:   "Title",
+		Content: "Body",
+		Meta:    map[string]string{"token": "device_token_xyz123", "data": `{"order_id":"42"}`},
+	}
+	preview, err := c.Preview(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+
+	var payload pushPayload
+	if err := json.Unmarshal(preview.Payload, &payload); err != nil {
+		t.Fatalf("invalid payload: %v", err)
+	}
+	if payload.Token != "device_token_xyz123" || payload.Title != "Title" || payload.Data["order_id"] != "42" {
+		t.Errorf("unexpected payload: %+v", payload)
+	}
+}
@@ -2,17 +2,41 @@ package channels
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
+	"net/http"
 	"notification/models/channel"
-	"regexp"
+	"slices"
+	"strings"
 )
 
-type SMSChannel struct{}
+type SMSChannel struct {
+	// Providers are the SMS gateways by name; messages are logged when empty
+	Providers map[string]SMSProvider
+	// Routes maps carriers (meta.carrier) to provider names. Carriers without a
+	// route use the provider with the same name, then DefaultProvider
+	Routes          map[string]string
+	DefaultProvider string
+	// MaxSegments caps the length of a message; longer content is truncated. Defaults to 1
+	MaxSegments int
+	// DefaultRegion is the ISO 3166-1 code national numbers are read in, e.g. US
+	DefaultRegion string
+	// AllowedRegions, when set, are the only destination regions; DeniedRegions are always refused
+	AllowedRegions []string
+	DeniedRegions  []string
+	// Contacts resolves the phone number of notifications sent to a recipient_user_id
+	Contacts channel.ContactSource
+	// Templates resolves meta.template to a stored SMS body
+	Templates channel.TemplateSource
+}
 
 // ValidSMSMeta represents the required metadata for SMS notifications
 type ValidSMSMeta struct {
-	Phone   string `json:"phone" example:"+1234567890"`
+	Phone   string `json:"phone" example:"+14155552671"`
 	Carrier string `json:"carrier" example:"verizon"`
+	// Region overrides the default region for a phone number in national format
+	Region string `json:"region,omitempty" example:"US"`
 }
 
 func (c *SMSChannel) Name() string {
@@ -20,12 +44,69 @@ func (c *SMSChannel) Name() string {
 }
 
 func (c *SMSChannel) Send(ctx context.Context, msg channel.Message) error {
+	if len(c.Providers) == 0 {
+		log.Printf("SMS to %s: %s", msg.Meta["phone"], msg.Content) // no provider configured
+		return nil
+	}
+
+	name, provider, err := c.provider(msg.Meta["carrier"])
+	if err != nil {
+		return err
+	}
+	id, err := provider.SendSMS(ctx, msg.Meta["phone"], msg.Content)
+	var providerErr *SMSProviderError
+	if errors.As(err, &providerErr) {
+		if providerErr.StatusCode == http.StatusTooManyRequests {
+			return &channel.RetryAfterError{After: retryDelay(providerErr.RetryAfter), Err: err}
+		}
+		if providerErr.Permanent() {
+			return channel.Permanent(err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	receipt := channel.ReceiptFrom(ctx)
+	receipt.Provider = name
+	receipt.ProviderMessageID = id
+	receipt.Segments = AnalyzeSMS(msg.Content).Segments
 	return nil
 }
 
+// provider selects the provider for a carrier
+func (c *SMSChannel) provider(carrier string) (string, SMSProvider, error) {
+	carrier = strings.ToLower(carrier)
+	name := c.Routes[carrier]
+	if name == "" {
+		if _, ok := c.Providers[carrier]; ok {
+			name = carrier
+		} else {
+			name = c.DefaultProvider
+		}
+	}
+	if name == "" && len(c.Providers) == 1 {
+		for only := range c.Providers {
+			name = only
+		}
+	}
+
+	provider, ok := c.Providers[name]
+	if !ok {
+		return "", nil, fmt.Errorf("no SMS provider for carrier %q", carrier)
+	}
+	return name, provider, nil
+}
+
 func (c *SMSChannel) Validate(meta map[string]string) error {
-	if phone, ok := meta["phone"]; !ok || !regexp.MustCompile(`^\+[1-9]\d{1,14}$`).MatchString(phone) {
-		return fmt.Errorf("phone field with valid phone number is required")
+	// the carrier is optional when the number comes from the contact profile
+	if meta["phone"] == "" {
+		if resolvable, err := canResolve(c.Contacts, meta); err == nil && resolvable {
+			return nil
+		}
+	}
+	if _, err := c.destination(meta); err != nil {
+		return err
 	}
 	if carrier, ok := meta["carrier"]; !ok || carrier == "" {
 		return fmt.Errorf("carrier field is required")
@@ -33,9 +114,51 @@ func (c *SMSChannel) Validate(meta map[string]string) error {
 	return nil
 }
 
+// destination parses meta.phone and checks its region against the allow and deny lists
+func (c *SMSChannel) destination(meta map[string]string) (Phone, error) {
+	raw, ok := meta["phone"]
+	if !ok || raw == "" {
+		return Phone{}, fmt.Errorf("phone field with valid phone number is required")
+	}
+	region := meta["region"]
+	if region == "" {
+		region = c.DefaultRegion
+	}
+	phone, err := ParsePhone(raw, region)
+	if err != nil {
+		return Phone{}, fmt.Errorf("phone field with valid phone number is required: %w", err)
+	}
+
+	if slices.ContainsFunc(c.DeniedRegions, func(r string) bool { return strings.EqualFold(r, phone.Region) }) ||
+		len(c.AllowedRegions) > 0 && !slices.ContainsFunc(c.AllowedRegions, func(r string) bool { return strings.EqualFold(r, phone.Region) }) {
+		return Phone{}, fmt.Errorf("SMS to region %s is not allowed", phone.Region)
+	}
+	return phone, nil
+}
+
 func (c *SMSChannel) Prepare(ctx context.Context, msg *channel.Message) error {
-	if len(msg.Content) > 160 {
-		msg.Content = msg.Content[:160]
+	if err := msg.RenderVariables(false); err != nil {
+		return err
+	}
+	if err := resolveAddress(ctx, c.Contacts, msg, "phone", func(contact channel.Contact) string { return contact.Phone }); err != nil {
+		return err
+	}
+	if _, ok := msg.Meta["phone"]; ok {
+		// the number or its region won't change by retrying
+		phone, err := c.destination(msg.Meta)
+		if err != nil {
+			return channel.Permanent(err)
+		}
+		msg.Meta["phone"] = phone.E164
 	}
+	if err := applyStoredTemplate(ctx, c.Templates, c.Name(), msg); err != nil {
+		return err
+	}
+	msg.Content = truncateSMS(msg.Content, c.MaxSegments)
 	return nil
 }
+
+func (c *SMSChannel) Preview(ctx context.Context, msg channel.Message) (channel.Preview, error) {
+	info := AnalyzeSMS(msg.Content)
+	return channel.Preview{Text: msg.Content, Segments: info.Segments, Encoding: info.Encoding}, nil
+}
@@ -0,0 +1,123 @@
+package channels
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	SMSEncodingGSM7 = "GSM-7"
+	SMSEncodingUCS2 = "UCS-2"
+
+	// characters per segment; concatenated segments lose room to the user data header
+	gsm7SingleSegment = 160
+	gsm7MultiSegment  = 153
+	ucs2SingleSegment = 70
+	ucs2MultiSegment  = 67
+)
+
+// GSM 03.38 default alphabet (without the escape character) and the extension
+// table, whose characters take two septets
+const (
+	gsm7Basic     = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	gsm7Extension = "\f^{}\\[~]|€"
+)
+
+// SMSInfo describes how a text is sent as SMS
+type SMSInfo struct {
+	Encoding string
+	// Units is the length in septets (GSM-7) or UTF-16 code units (UCS-2)
+	Units    int
+	Segments int
+}
+
+func smsEncoding(text string) string {
+	for _, r := range text {
+		if !strings.ContainsRune(gsm7Basic, r) && !strings.ContainsRune(gsm7Extension, r) {
+			return SMSEncodingUCS2
+		}
+	}
+	return SMSEncodingGSM7
+}
+
+// smsUnits is the room a character takes in the given encoding
+func smsUnits(r rune, encoding string) int {
+	if encoding == SMSEncodingGSM7 {
+		if strings.ContainsRune(gsm7Extension, r) {
+			return 2
+		}
+		return 1
+	}
+	return len(utf16.Encode([]rune{r}))
+}
+
+func segmentLimits(encoding string) (single, multi int) {
+	if encoding == SMSEncodingGSM7 {
+		return gsm7SingleSegment, gsm7MultiSegment
+	}
+	return ucs2SingleSegment, ucs2MultiSegment
+}
+
+// AnalyzeSMS detects the encoding of text and counts the segments it is split
+// into. Characters are never split across segments, so an escaped GSM-7
+// character or a UCS-2 surrogate pair that doesn't fit starts the next one
+func AnalyzeSMS(text string) SMSInfo {
+	encoding := smsEncoding(text)
+	single, multi := segmentLimits(encoding)
+
+	units := 0
+	for _, r := range text {
+		units += smsUnits(r, encoding)
+	}
+	if units <= single {
+		return SMSInfo{Encoding: encoding, Units: units, Segments: 1}
+	}
+
+	segments, used := 1, 0
+	for _, r := range text {
+		n := smsUnits(r, encoding)
+		if used+n > multi {
+			segments++
+			used = 0
+		}
+		used += n
+	}
+	return SMSInfo{Encoding: encoding, Units: units, Segments: segments}
+}
+
+// truncateSMS shortens text to at most maxSegments segments, cutting between characters
+func truncateSMS(text string, maxSegments int) string {
+	if maxSegments < 1 {
+		maxSegments = 1
+	}
+	if AnalyzeSMS(text).Segments <= maxSegments {
+		return text
+	}
+
+	encoding := smsEncoding(text)
+	single, multi := segmentLimits(encoding)
+	if maxSegments == 1 {
+		units := 0
+		for i, r := range text {
+			units += smsUnits(r, encoding)
+			if units > single {
+				return text[:i]
+			}
+		}
+		return text
+	}
+
+	segments, used := 1, 0
+	for i, r := range text {
+		n := smsUnits(r, encoding)
+		if used+n > multi {
+			if segments == maxSegments {
+				return text[:i]
+			}
+			segments++
+			used = 0
+		}
+		used += n
+	}
+	return text
+}
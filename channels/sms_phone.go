@@ -0,0 +1,145 @@
+package channels
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// phoneRegionsCSV holds the calling code, trunk prefix and national number
+// lengths of the supported destination regions
+//
+//go:embed sms/regions.csv
+var phoneRegionsCSV []byte
+
+type phoneRegion struct {
+	Region      string
+	CallingCode string
+	Trunk       string
+	MinLength   int
+	MaxLength   int
+	Leading     []string
+}
+
+var (
+	phoneRegions       = loadPhoneRegions(phoneRegionsCSV)
+	phoneRegionsByCode = indexPhoneRegions(phoneRegions)
+)
+
+func loadPhoneRegions(data []byte) map[string]phoneRegion {
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comment = '#'
+	records, err := r.ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("channels: reading phone regions: %v", err))
+	}
+	regions := make(map[string]phoneRegion, len(records))
+	for _, rec := range records {
+		minLength, errMin := strconv.Atoi(rec[3])
+		maxLength, errMax := strconv.Atoi(rec[4])
+		if errMin != nil || errMax != nil {
+			panic(fmt.Sprintf("channels: invalid lengths for phone region %s", rec[0]))
+		}
+		regions[rec[0]] = phoneRegion{
+			Region:      rec[0],
+			CallingCode: rec[1],
+			Trunk:       rec[2],
+			MinLength:   minLength,
+			MaxLength:   maxLength,
+			Leading:     strings.Fields(rec[5]),
+		}
+	}
+	return regions
+}
+
+func indexPhoneRegions(regions map[string]phoneRegion) map[string][]phoneRegion {
+	byCode := make(map[string][]phoneRegion)
+	for _, region := range regions {
+		byCode[region.CallingCode] = append(byCode[region.CallingCode], region)
+	}
+	return byCode
+}
+
+// Phone is a validated phone number
+type Phone struct {
+	// E164 is the number in E.164 format, e.g. +14155552671
+	E164 string
+	// Region is the ISO 3166-1 alpha-2 code of the destination, e.g. US
+	Region string
+}
+
+// ParsePhone normalizes raw to E.164. International numbers start with + or 00;
+// anything else is read as a national number of defaultRegion, dropping its
+// trunk prefix. Spaces, dots, dashes, slashes and parentheses are ignored
+func ParsePhone(raw, defaultRegion string) (Phone, error) {
+	digits := strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '.', '-', '/', '(', ')':
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(raw))
+
+	international := false
+	switch {
+	case strings.HasPrefix(digits, "+"):
+		digits, international = digits[1:], true
+	case strings.HasPrefix(digits, "00"):
+		digits, international = digits[2:], true
+	}
+	if digits == "" || strings.IndexFunc(digits, func(r rune) bool { return r < '0' || r > '9' }) >= 0 {
+		return Phone{}, fmt.Errorf("phone number %q must contain only digits", raw)
+	}
+
+	var code, national string
+	if international {
+		for n := 1; n <= 3 && n < len(digits); n++ {
+			if _, ok := phoneRegionsByCode[digits[:n]]; ok {
+				code, national = digits[:n], digits[n:]
+				break
+			}
+		}
+		if code == "" {
+			return Phone{}, fmt.Errorf("phone number %q has an unknown country calling code", raw)
+		}
+	} else {
+		if defaultRegion == "" {
+			return Phone{}, fmt.Errorf("phone number %q must be in international format", raw)
+		}
+		region, ok := phoneRegions[strings.ToUpper(defaultRegion)]
+		if !ok {
+			return Phone{}, fmt.Errorf("unknown region %q", defaultRegion)
+		}
+		code, national = region.CallingCode, digits
+		// a number that is too short without the prefix, like Russian 800 numbers, keeps it
+		if stripped, ok := strings.CutPrefix(national, region.Trunk); ok && region.Trunk != "" && len(stripped) >= region.MinLength {
+			national = stripped
+		}
+	}
+
+	region := regionFor(code, national)
+	if len(national) < region.MinLength || len(national) > region.MaxLength {
+		return Phone{}, fmt.Errorf("phone number %q has the wrong length for %s", raw, region.Region)
+	}
+	return Phone{E164: "+" + code + national, Region: region.Region}, nil
+}
+
+// regionFor picks the region of a national number among those sharing its calling code
+func regionFor(code, national string) phoneRegion {
+	var main phoneRegion
+	for _, region := range phoneRegionsByCode[code] {
+		if len(region.Leading) == 0 {
+			main = region
+			continue
+		}
+		for _, prefix := range region.Leading {
+			if strings.HasPrefix(national, prefix) {
+				return region
+			}
+		}
+	}
+	return main
+}
@@ -0,0 +1,97 @@
+package channels
+
+import (
+	"context"
+	"notification/models/channel"
+	"testing"
+)
+
+func TestParsePhone(t *testing.T) {
+	tests := []struct {
+		raw    string
+		region string
+		e164   string
+		want   string
+	}{
+		{"+14155552671", "", "+14155552671", "US"},
+		{"+1 (416) 555-0123", "", "+14165550123", "CA"},
+		{"(415) 555-2671", "US", "+14155552671", "US"},
+		{"1-415-555-2671", "us", "+14155552671", "US"},
+		{"030 1234567", "DE", "+49301234567", "DE"},
+		{"0049 30 1234567", "", "+49301234567", "DE"},
+		{"07911 123456", "GB", "+447911123456", "GB"},
+		{"8 (912) 345-67-89", "RU", "+79123456789", "RU"},
+		{"8 800 555 35 35", "RU", "+78005553535", "RU"},
+		{"+77011234567", "", "+77011234567", "KZ"},
+		{"06 12 34 56 78", "FR", "+33612345678", "FR"},
+		{"312 345 6789", "IT", "+393123456789", "IT"},
+	}
+	for _, tt := range tests {
+		phone, err := ParsePhone(tt.raw, tt.region)
+		if err != nil {
+			t.Errorf("%q: %v", tt.raw, err)
+			continue
+		}
+		if phone.E164 != tt.e164 || phone.Region != tt.want {
+			t.Errorf("%q: expected %s (%s), got %s (%s)", tt.raw, tt.e164, tt.want, phone.E164, phone.Region)
+		}
+	}
+}
+
+func TestParsePhone_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		raw    string
+		region string
+	}{
+		{"national without region", "4155552671", ""},
+		{"unknown region", "4155552671", "XX"},
+		{"unknown calling code", "+999123456789", ""},
+		{"too short", "+1415555", ""},
+		{"too long", "+4479111234567", ""},
+		{"letters", "+1415555CALL", ""},
+		{"empty", "+", ""},
+	}
+	for _, tt := range tests {
+		if phone, err := ParsePhone(tt.raw, tt.region); err == nil {
+			t.Errorf("%s: expected error, got %+v", tt.name, phone)
+		}
+	}
+}
+
+func TestSMSValidate_Regions(t *testing.T) {
+	c := &SMSChannel{DefaultRegion: "US", AllowedRegions: []string{"us", "CA"}, DeniedRegions: []string{"CA"}}
+	tests := []struct {
+		phone   string
+		region  string
+		allowed bool
+	}{
+		{"(415) 555-2671", "", true},
+		{"+14165550123", "", false},
+		{"+447911123456", "", false},
+		{"07911 123456", "GB", false},
+	}
+	for _, tt := range tests {
+		err := c.Validate(map[string]string{"phone": tt.phone, "region": tt.region, "carrier": "verizon"})
+		if (err == nil) != tt.allowed {
+			t.Errorf("%s: expected allowed=%v, got %v", tt.phone, tt.allowed, err)
+		}
+	}
+}
+
+func TestSMSPrepare_NormalizesPhone(t *testing.T) {
+	c := &SMSChannel{DefaultRegion: "DE"}
+	msg := channel.Message{Content: "hi", Meta: map[string]string{"phone": "030 1234567", "carrier": "telekom"}}
+	if err := c.Prepare(context.Background(), &msg); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if msg.Meta["phone"] != "+49301234567" {
+		t.Fatalf("expected normalized phone, got %q", msg.Meta["phone"])
+	}
+
+	c.DeniedRegions = []string{"DE"}
+	msg.Meta["phone"] = "030 1234567"
+	if err := c.Prepare(context.Background(), &msg); err == nil {
+		t.Fatal("expected error for denied region")
+	}
+}
@@ -0,0 +1,270 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTwilioBaseURL = "https://api.twilio.com"
+	defaultSMSTimeout    = 10 * time.Second
+
+	SMSFormatJSON = "json"
+	SMSFormatForm = "form"
+
+	SMSAuthNone   = ""
+	SMSAuthBasic  = "basic"
+	SMSAuthBearer = "bearer"
+)
+
+// SMSProvider sends a text message through an SMS gateway and returns the
+// message ID assigned by the provider
+type SMSProvider interface {
+	SendSMS(ctx context.Context, to, body string) (messageID string, err error)
+}
+
+// SMSProviderError is returned when the provider rejects a message
+type SMSProviderError struct {
+	Provider   string
+	StatusCode int
+	Message    string
+	// RetryAfter is how long a rate limited (429) provider asked to wait
+	RetryAfter time.Duration
+}
+
+func (e *SMSProviderError) Error() string {
+	return fmt.Sprintf("%s: status %d: %s", e.Provider, e.StatusCode, e.Message)
+}
+
+// Temporary reports whether sending again later may succeed
+func (e *SMSProviderError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// Permanent reports whether the message itself was rejected, so sending it
+// again can't succeed. Authentication errors aren't permanent: they fail every
+// message until the credentials are fixed
+func (e *SMSProviderError) Permanent() bool {
+	switch e.StatusCode {
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return false
+	}
+	return !e.Temporary()
+}
+
+// TwilioProvider sends through the Twilio Messages API, or any API compatible with it
+type TwilioProvider struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	// BaseURL defaults to https://api.twilio.com
+	BaseURL string
+	// Client sends the requests, a client with a 10s timeout is used when nil
+	Client *http.Client
+}
+
+func (p *TwilioProvider) SendSMS(ctx context.Context, to, body string) (string, error) {
+	baseURL := p.BaseURL
+	if baseURL == "" {
+		baseURL = defaultTwilioBaseURL
+	}
+	endpoint := strings.TrimRight(baseURL, "/") + "/2010-04-01/Accounts/" + url.PathEscape(p.AccountSID) + "/Messages.json"
+	form := url.Values{"To": {to}, "From": {p.From}, "Body": {body}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(p.AccountSID, p.AuthToken)
+
+	var result struct {
+		SID     string `json:"sid"`
+		Message string `json:"message"`
+	}
+	if err := doSMSRequest(smsClient(p.Client), req, "twilio", &result, func() string { return result.Message }); err != nil {
+		return "", err
+	}
+	return result.SID, nil
+}
+
+// RESTProvider sends through a generic HTTP API that takes the recipient,
+// sender and text as JSON or form fields
+type RESTProvider struct {
+	// Name identifies the provider in errors and receipts, defaults to "rest"
+	Name   string
+	URL    string
+	Format string // "json" (default) or "form"
+	Auth   string // "basic", "bearer" or empty
+	// Username and Password are used for basic auth, Token for bearer auth
+	Username string
+	Password string
+	Token    string
+	From     string
+	// Request field names, default to "to", "from" and "body"
+	ToField   string
+	FromField string
+	BodyField string
+	// IDField is the top-level response field holding the message ID, defaults to "id"
+	IDField string
+	// Client sends the requests, a client with a 10s timeout is used when nil
+	Client *http.Client
+}
+
+func (p *RESTProvider) SendSMS(ctx context.Context, to, body string) (string, error) {
+	fields := map[string]string{
+		withDefault(p.ToField, "to"):     to,
+		withDefault(p.BodyField, "body"): body,
+	}
+	if p.From != "" {
+		fields[withDefault(p.FromField, "from")] = p.From
+	}
+
+	var reqBody io.Reader
+	contentType := "application/json"
+	if p.Format == SMSFormatForm {
+		form := url.Values{}
+		for k, v := range fields {
+			form.Set(k, v)
+		}
+		reqBody = strings.NewReader(form.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	} else {
+		b, err := json.Marshal(fields)
+		if err != nil {
+			return "", err
+		}
+		reqBody = strings.NewReader(string(b))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.URL, reqBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	switch p.Auth {
+	case SMSAuthBasic:
+		req.SetBasicAuth(p.Username, p.Password)
+	case SMSAuthBearer:
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	var result map[string]any
+	errorMessage := func() string {
+		for _, key := range []string{"message", "error"} {
+			if s, ok := result[key].(string); ok {
+				return s
+			}
+		}
+		return ""
+	}
+	if err := doSMSRequest(smsClient(p.Client), req, p.name(), &result, errorMessage); err != nil {
+		return "", err
+	}
+
+	switch id := result[withDefault(p.IDField, "id")].(type) {
+	case string:
+		return id, nil
+	case float64:
+		return fmt.Sprint(id), nil
+	default:
+		return "", nil
+	}
+}
+
+func (p *RESTProvider) name() string {
+	return withDefault(p.Name, "rest")
+}
+
+// doSMSRequest sends req and decodes the JSON response into result. Non-2xx
+// responses are returned as an SMSProviderError with the message reported by errorMessage
+func doSMSRequest(client *http.Client, req *http.Request, provider string, result any, errorMessage func() string) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s: %w", provider, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return fmt.Errorf("%s: %w", provider, err)
+	}
+	decodeErr := json.Unmarshal(body, result)
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message := errorMessage()
+		if message == "" {
+			message = http.StatusText(resp.StatusCode)
+		}
+		providerErr := &SMSProviderError{Provider: provider, StatusCode: resp.StatusCode, Message: message}
+		if resp.StatusCode == http.StatusTooManyRequests {
+			providerErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		}
+		return providerErr
+	}
+	if decodeErr != nil && len(body) > 0 {
+		return fmt.Errorf("%s: invalid response: %w", provider, decodeErr)
+	}
+	return nil
+}
+
+func smsClient(c *http.Client) *http.Client {
+	if c == nil {
+		return &http.Client{Timeout: defaultSMSTimeout}
+	}
+	return c
+}
+
+func withDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
+
+// SMSProvidersFromEnv configures the "twilio" provider when TWILIO_ACCOUNT_SID is
+// set and the "rest" provider when SMS_REST_URL is set. Routes map carriers to
+// provider names, read from SMS_CARRIER_ROUTES as "carrier=provider,..."
+func SMSProvidersFromEnv() (providers map[string]SMSProvider, routes map[string]string) {
+	providers = make(map[string]SMSProvider)
+	if sid := os.Getenv("TWILIO_ACCOUNT_SID"); sid != "" {
+		providers["twilio"] = &TwilioProvider{
+			AccountSID: sid,
+			AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+			From:       os.Getenv("TWILIO_FROM"),
+			BaseURL:    os.Getenv("TWILIO_BASE_URL"),
+		}
+	}
+	if u := os.Getenv("SMS_REST_URL"); u != "" {
+		providers["rest"] = &RESTProvider{
+			URL:       u,
+			Format:    strings.ToLower(os.Getenv("SMS_REST_FORMAT")),
+			Auth:      strings.ToLower(os.Getenv("SMS_REST_AUTH")),
+			Username:  os.Getenv("SMS_REST_USERNAME"),
+			Password:  os.Getenv("SMS_REST_PASSWORD"),
+			Token:     os.Getenv("SMS_REST_TOKEN"),
+			From:      os.Getenv("SMS_REST_FROM"),
+			ToField:   os.Getenv("SMS_REST_TO_FIELD"),
+			FromField: os.Getenv("SMS_REST_FROM_FIELD"),
+			BodyField: os.Getenv("SMS_REST_BODY_FIELD"),
+			IDField:   os.Getenv("SMS_REST_ID_FIELD"),
+		}
+	}
+
+	routes = make(map[string]string)
+	for _, route := range strings.Split(os.Getenv("SMS_CARRIER_ROUTES"), ",") {
+		carrier, provider, ok := strings.Cut(strings.TrimSpace(route), "=")
+		if ok && carrier != "" && provider != "" {
+			routes[strings.ToLower(carrier)] = provider
+		}
+	}
+	return providers, routes
+}
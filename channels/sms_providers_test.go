@@ -0,0 +1,245 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"notification/models/channel"
+	"testing"
+	"time"
+)
+
+// smsStandIn emulates an SMS gateway, answering every request with status and body
+type smsStandIn struct {
+	*httptest.Server
+	status   int
+	body     string
+	requests []*http.Request
+	bodies   []string
+}
+
+func newSMSStandIn(t *testing.T, status int, body string) *smsStandIn {
+	s := &smsStandIn{status: status, body: body}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		s.requests = append(s.requests, r)
+		s.bodies = append(s.bodies, string(b))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(s.status)
+		w.Write([]byte(s.body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestTwilioProvider_OK(t *testing.T) {
+	srv := newSMSStandIn(t, http.StatusCreated, `{"sid":"SM123","status":"queued"}`)
+	p := &TwilioProvider{AccountSID: "AC1", AuthToken: "token", From: "+15550000000", BaseURL: srv.URL}
+
+	id, err := p.SendSMS(context.Background(), "+15551234567", "hello")
+	if err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+	if id != "SM123" {
+		t.Fatalf("expected message ID SM123, got %q", id)
+	}
+
+	req := srv.requests[0]
+	if req.URL.Path != "/2010-04-01/Accounts/AC1/Messages.json" {
+		t.Fatalf("unexpected path: %s", req.URL.Path)
+	}
+	if user, pass, ok := req.BasicAuth(); !ok || user != "AC1" || pass != "token" {
+		t.Fatalf("unexpected basic auth: %q %q %v", user, pass, ok)
+	}
+	form, _ := url.ParseQuery(srv.bodies[0])
+	if form.Get("To") != "+15551234567" || form.Get("From") != "+15550000000" || form.Get("Body") != "hello" {
+		t.Fatalf("unexpected form: %v", form)
+	}
+}
+
+func TestTwilioProvider_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    int
+		body      string
+		message   string
+		temporary bool
+	}{
+		{"invalid number", http.StatusBadRequest, `{"code":21211,"message":"The 'To' number is not valid."}`, "The 'To' number is not valid.", false},
+		{"server error", http.StatusServiceUnavailable, ``, "Service Unavailable", true},
+		{"rate limited", http.StatusTooManyRequests, `{"message":"Too many requests"}`, "Too many requests", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newSMSStandIn(t, tt.status, tt.body)
+			p := &TwilioProvider{AccountSID: "AC1", BaseURL: srv.URL}
+
+			_, err := p.SendSMS(context.Background(), "+15551234567", "hello")
+			var providerErr *SMSProviderError
+			if !errors.As(err, &providerErr) {
+				t.Fatalf("expected SMSProviderError, got %v", err)
+			}
+			if providerErr.StatusCode != tt.status || providerErr.Message != tt.message || providerErr.Temporary() != tt.temporary {
+				t.Fatalf("unexpected error: %+v (temporary %v)", providerErr, providerErr.Temporary())
+			}
+		})
+	}
+}
+
+func TestRESTProvider_JSONBearer(t *testing.T) {
+	srv := newSMSStandIn(t, http.StatusOK, `{"id":"msg-1"}`)
+	p := &RESTProvider{URL: srv.URL, Auth: SMSAuthBearer, Token: "secret", From: "ACME"}
+
+	id, err := p.SendSMS(context.Background(), "+15551234567", "hello")
+	if err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+	if id != "msg-1" {
+		t.Fatalf("expected message ID msg-1, got %q", id)
+	}
+	req := srv.requests[0]
+	if req.Header.Get("Authorization") != "Bearer secret" || req.Header.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected headers: %v", req.Header)
+	}
+	var body map[string]string
+	if err := json.Unmarshal([]byte(srv.bodies[0]), &body); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if body["to"] != "+15551234567" || body["from"] != "ACME" || body["body"] != "hello" {
+		t.Fatalf("unexpected body: %v", body)
+	}
+}
+
+func TestRESTProvider_FormBasicCustomFields(t *testing.T) {
+	srv := newSMSStandIn(t, http.StatusAccepted, `{"message_id":42}`)
+	p := &RESTProvider{
+		URL: srv.URL, Format: SMSFormatForm, Auth: SMSAuthBasic, Username: "u", Password: "p",
+		ToField: "msisdn", BodyField: "text", IDField: "message_id",
+	}
+
+	id, err := p.SendSMS(context.Background(), "+15551234567", "hello")
+	if err != nil {
+		t.Fatalf("SendSMS: %v", err)
+	}
+	if id != "42" {
+		t.Fatalf("expected message ID 42, got %q", id)
+	}
+	if user, pass, ok := srv.requests[0].BasicAuth(); !ok || user != "u" || pass != "p" {
+		t.Fatalf("unexpected basic auth: %q %q %v", user, pass, ok)
+	}
+	form, _ := url.ParseQuery(srv.bodies[0])
+	if form.Get("msisdn") != "+15551234567" || form.Get("text") != "hello" || form.Has("from") {
+		t.Fatalf("unexpected form: %v", form)
+	}
+}
+
+func TestRESTProvider_ClientError(t *testing.T) {
+	srv := newSMSStandIn(t, http.StatusUnauthorized, `{"error":"bad token"}`)
+	p := &RESTProvider{Name: "acme", URL: srv.URL}
+
+	_, err := p.SendSMS(context.Background(), "+15551234567", "hello")
+	var providerErr *SMSProviderError
+	if !errors.As(err, &providerErr) || providerErr.Provider != "acme" || providerErr.Message != "bad token" || providerErr.Temporary() {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+type fakeSMSProvider struct {
+	id   string
+	to   string
+	body string
+}
+
+func (f *fakeSMSProvider) SendSMS(ctx context.Context, to, body string) (string, error) {
+	f.to, f.body = to, body
+	return f.id, nil
+}
+
+func TestSMSSend_Routing(t *testing.T) {
+	twilio := &fakeSMSProvider{id: "tw-1"}
+	rest := &fakeSMSProvider{id: "rest-1"}
+	c := &SMSChannel{
+		Providers:       map[string]SMSProvider{"twilio": twilio, "rest": rest},
+		Routes:          map[string]string{"verizon": "rest"},
+		DefaultProvider: "twilio",
+	}
+
+	tests := []struct {
+		carrier      string
+		wantProvider string
+		wantID       string
+	}{
+		{"Verizon", "rest", "rest-1"},
+		{"twilio", "twilio", "tw-1"},
+		{"att", "twilio", "tw-1"},
+	}
+	for _, tt := range tests {
+		receipt := &channel.Receipt{}
+		msg := channel.Message{Content: "hi", Meta: map[string]string{"phone": "+15551234567", "carrier": tt.carrier}}
+		if err := c.Send(channel.WithReceipt(context.Background(), receipt), msg); err != nil {
+			t.Fatalf("%s: Send: %v", tt.carrier, err)
+		}
+		if receipt.Provider != tt.wantProvider || receipt.ProviderMessageID != tt.wantID || receipt.Segments != 1 {
+			t.Errorf("%s: unexpected receipt %+v", tt.carrier, receipt)
+		}
+	}
+
+	c.DefaultProvider = ""
+	if err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"carrier": "att"}}); err == nil {
+		t.Fatal("expected error when no provider matches")
+	}
+}
+
+type failingSMSProvider struct{ err error }
+
+func (f *failingSMSProvider) SendSMS(ctx context.Context, to, body string) (string, error) {
+	return "", f.err
+}
+
+func TestSMSSend_ProviderErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        *SMSProviderError
+		permanent  bool
+		retryAfter time.Duration
+	}{
+		{"invalid number", &SMSProviderError{Provider: "twilio", StatusCode: http.StatusBadRequest}, true, 0},
+		{"rate limited", &SMSProviderError{Provider: "twilio", StatusCode: http.StatusTooManyRequests, RetryAfter: 30 * time.Second}, false, 30 * time.Second},
+		{"rate limited without delay", &SMSProviderError{Provider: "twilio", StatusCode: http.StatusTooManyRequests}, false, minRetryAfter},
+		{"unavailable", &SMSProviderError{Provider: "twilio", StatusCode: http.StatusServiceUnavailable}, false, 0},
+		{"bad credentials", &SMSProviderError{Provider: "twilio", StatusCode: http.StatusUnauthorized}, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &SMSChannel{Providers: map[string]SMSProvider{"twilio": &failingSMSProvider{err: tt.err}}}
+			err := c.Send(context.Background(), channel.Message{Content: "hi", Meta: map[string]string{"phone": "+15551234567", "carrier": "att"}})
+			var permanent *channel.PermanentError
+			if errors.As(err, &permanent) != tt.permanent {
+				t.Fatalf("expected permanent %v, got %v", tt.permanent, err)
+			}
+			var retryAfter *channel.RetryAfterError
+			if errors.As(err, &retryAfter) != (tt.retryAfter > 0) || (retryAfter != nil && retryAfter.After != tt.retryAfter) {
+				t.Fatalf("expected retry after %s, got %v", tt.retryAfter, err)
+			}
+		})
+	}
+}
+
+func TestTwilioProvider_RetryAfter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+	p := &TwilioProvider{AccountSID: "AC1", BaseURL: srv.URL}
+
+	_, err := p.SendSMS(context.Background(), "+15551234567", "hello")
+	var providerErr *SMSProviderError
+	if !errors.As(err, &providerErr) || providerErr.RetryAfter != 30*time.Second {
+		t.Fatalf("expected a 30s retry after, got %v", err)
+	}
+}
@@ -10,7 +10,7 @@ import (
 func TestSMSValidate_OK(t *testing.T) {
 	c := &SMSChannel{}
 	meta := map[string]string{
-		"phone":   "+1234567890",
+		"phone":   "+14155552671",
 		"carrier": "verizon",
 	}
 	if err := c.Validate(meta); err != nil {
@@ -36,7 +36,7 @@ func TestSMSValidate_InvalidPhoneFormat(t *testing.T) {
 		{"starts with zero", "+0123456789"},
 		{"too short", "+1"},
 		{"letters", "+123abc"},
-		{"spaces", "+1 234 567 890"},
+		{"wrong length", "+1 234 567 890"},
 	}
 
 	for _, tt := range tests {
@@ -54,7 +54,7 @@ func TestSMSValidate_InvalidPhoneFormat(t *testing.T) {
 
 func TestSMSValidate_MissingCarrier(t *testing.T) {
 	c := &SMSChannel{}
-	meta := map[string]string{"phone": "+1234567890"}
+	meta := map[string]string{"phone": "+14155552671"}
 	if err := c.Validate(meta); err == nil {
 		t.Fatal("expected error for missing carrier")
 	}
@@ -63,7 +63,7 @@ func TestSMSValidate_MissingCarrier(t *testing.T) {
 func TestSMSValidate_EmptyCarrier(t *testing.T) {
 	c := &SMSChannel{}
 	meta := map[string]string{
-		"phone":   "+1234567890",
+		"phone":   "+14155552671",
 		"carrier": "",
 	}
 	if err := c.Validate(meta); err == nil {
@@ -77,7 +77,7 @@ func TestSMSSend_OK(t *testing.T) {
 		Title:   "Test",
 		Content: "Hello SMS",
 		Meta: map[string]string{
-			"phone":   "+1234567890",
+			"phone":   "+14155552671",
 			"carrier": "att",
 		},
 	}
@@ -93,7 +93,7 @@ func TestSMSPrepare_TruncatesLongContent(t *testing.T) {
 		Title:   "Test",
 		Content: longContent,
 		Meta: map[string]string{
-			"phone":   "+1234567890",
+			"phone":   "+14155552671",
 			"carrier": "verizon",
 		},
 	}
@@ -114,7 +114,7 @@ func TestSMSPrepare_ShortContentUnchanged(t *testing.T) {
 		Title:   "Test",
 		Content: shortContent,
 		Meta: map[string]string{
-			"phone":   "+1234567890",
+			"phone":   "+14155552671",
 			"carrier": "tmobile",
 		},
 	}
@@ -134,3 +134,107 @@ func TestSMSName(t *testing.T) {
 		t.Fatalf("expected name 'sms', got %q", c.Name())
 	}
 }
+
+func TestSMSPrepare_RendersVariables(t *testing.T) {
+	c := &SMSChannel{}
+	msg := channel.Message{
+		Content:   "Hi {{.name}}, your code is {{.code}}",
+		Meta:      map[string]string{"phone": "+14155552671", "carrier": "verizon"},
+		Variables: map[string]string{"name": "Ana", "code": "1234"},
+	}
+	if err := c.Prepare(context.Background(), &msg); err != nil {
+		t.Fatalf("Prepare failed: %v", err)
+	}
+	if msg.Content != "Hi Ana, your code is 1234" {
+		t.Fatalf("unexpected content: %q", msg.Content)
+	}
+}
+
+func TestSMSPreview_Segments(t *testing.T) {
+	c := &SMSChannel{}
+	tests := []struct {
+		length int
+		want   int
+	}{
+		{0, 1},
+		{160, 1},
+		{161, 2},
+		{306, 2},
+		{307, 3},
+	}
+	for _, tt := range tests {
+		preview, err := c.Preview(context.Background(), channel.Message{Content: strings.Repeat("a", tt.length)})
+		if err != nil {
+			t.Fatalf("Preview failed: %v", err)
+		}
+		if preview.Segments != tt.want {
+			t.Errorf("length %d: expected %d segments, got %d", tt.length, tt.want, preview.Segments)
+		}
+	}
+}
+
+func TestAnalyzeSMS(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		encoding string
+		units    int
+		segments int
+	}{
+		{"gsm single", strings.Repeat("a", 160), SMSEncodingGSM7, 160, 1},
+		{"extension characters count twice", strings.Repeat("€", 80), SMSEncodingGSM7, 160, 1},
+		{"extension character overflows", strings.Repeat("a", 159) + "[", SMSEncodingGSM7, 161, 2},
+		{"ucs2 single", strings.Repeat("ж", 70), SMSEncodingUCS2, 70, 1},
+		{"ucs2 concatenated", strings.Repeat("ж", 71), SMSEncodingUCS2, 71, 2},
+		{"ucs2 three parts", strings.Repeat("ж", 135), SMSEncodingUCS2, 135, 3},
+		{"surrogate pairs", strings.Repeat("😀", 35), SMSEncodingUCS2, 70, 1},
+		// 134 units would fit two parts, but a pair can't straddle the 67-unit boundary
+		{"surrogate pair not split", "xx" + strings.Repeat("😀", 66), SMSEncodingUCS2, 134, 3},
+	}
+	for _, tt := range tests {
+		info := AnalyzeSMS(tt.text)
+		if info.Encoding != tt.encoding || info.Units != tt.units || info.Segments != tt.segments {
+			t.Errorf("%s: expected %s/%d/%d, got %s/%d/%d", tt.name,
+				tt.encoding, tt.units, tt.segments, info.Encoding, info.Units, info.Segments)
+		}
+	}
+}
+
+func TestSMSPrepare_TruncatesToMaxSegments(t *testing.T) {
+	tests := []struct {
+		name        string
+		content     string
+		maxSegments int
+		want        string
+	}{
+		{"ucs2 single", strings.Repeat("ж", 100), 1, strings.Repeat("ж", 70)},
+		{"gsm concatenated", strings.Repeat("a", 400), 2, strings.Repeat("a", 306)},
+		{"ucs2 concatenated", strings.Repeat("ж", 200), 2, strings.Repeat("ж", 134)},
+		{"extension not split", strings.Repeat("a", 159) + "{}", 1, strings.Repeat("a", 159)},
+		{"fits", strings.Repeat("a", 300), 2, strings.Repeat("a", 300)},
+	}
+	for _, tt := range tests {
+		c := &SMSChannel{MaxSegments: tt.maxSegments}
+		msg := channel.Message{Content: tt.content}
+		if err := c.Prepare(context.Background(), &msg); err != nil {
+			t.Fatalf("%s: Prepare failed: %v", tt.name, err)
+		}
+		if msg.Content != tt.want {
+			t.Errorf("%s: expected %d runes, got %d", tt.name, len([]rune(tt.want)), len([]rune(msg.Content)))
+		}
+		if got := AnalyzeSMS(msg.Content).Segments; got > tt.maxSegments {
+			t.Errorf("%s: expected at most %d segments, got %d", tt.name, tt.maxSegments, got)
+		}
+	}
+}
+
+func TestSMSPreview_Encoding(t *testing.T) {
+	c := &SMSChannel{}
+	preview, err := c.Preview(context.Background(), channel.Message{Content: "Olá 👋"})
+	if err != nil {
+		t.Fatalf("Preview failed: %v", err)
+	}
+	if preview.Encoding != SMSEncodingUCS2 || preview.Segments != 1 {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+}
@@ -0,0 +1,318 @@
+package channels
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MailTransport delivers an already formatted RFC 5322 message to the given recipients
+type MailTransport interface {
+	Send(ctx context.Context, from string, to []string, msg []byte) error
+}
+
+const (
+	SMTPAuthNone  = ""
+	SMTPAuthPlain = "plain"
+	SMTPAuthLogin = "login"
+
+	SMTPTLSNone     = "none"
+	SMTPTLSStartTLS = "starttls"
+	SMTPTLSImplicit = "tls"
+)
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	Auth     string // "plain", "login" or empty for no authentication
+	TLS      string // "starttls", "tls" (implicit) or "none"
+	PoolSize int
+	Timeout  time.Duration
+	// TLSConfig overrides the default TLS configuration (e.g. custom root CAs)
+	TLSConfig *tls.Config
+}
+
+// SMTPConfigFromEnv reads the SMTP_* environment variables, falling back to
+// STARTTLS on port 587 with a pool of 2 connections. An unknown SMTP_AUTH or
+// SMTP_TLS is an error rather than a silent fallback to plaintext
+func SMTPConfigFromEnv() (SMTPConfig, error) {
+	config := SMTPConfig{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     os.Getenv("SMTP_PORT"),
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		Auth:     strings.ToLower(os.Getenv("SMTP_AUTH")),
+		TLS:      strings.ToLower(os.Getenv("SMTP_TLS")),
+		PoolSize: 2,
+		Timeout:  10 * time.Second,
+	}
+	if config.Port == "" {
+		config.Port = "587"
+	}
+	if config.TLS == "" {
+		config.TLS = SMTPTLSStartTLS
+	}
+	if config.Auth == "" && config.Username != "" {
+		config.Auth = SMTPAuthPlain
+	}
+	if n, err := strconv.Atoi(os.Getenv("SMTP_POOL_SIZE")); err == nil && n > 0 {
+		config.PoolSize = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("SMTP_TIMEOUT")); err == nil && d > 0 {
+		config.Timeout = d
+	}
+
+	switch config.Auth {
+	case SMTPAuthNone:
+	case SMTPAuthPlain, SMTPAuthLogin:
+		if config.Username == "" {
+			return config, fmt.Errorf("SMTP_AUTH %s requires SMTP_USERNAME", config.Auth)
+		}
+	default:
+		return config, fmt.Errorf("unknown SMTP_AUTH %q, use plain or login", config.Auth)
+	}
+	switch config.TLS {
+	case SMTPTLSNone, SMTPTLSStartTLS, SMTPTLSImplicit:
+	default:
+		return config, fmt.Errorf("unknown SMTP_TLS %q, use starttls, tls or none", config.TLS)
+	}
+	return config, nil
+}
+
+type smtpConn struct {
+	conn   net.Conn
+	client *smtp.Client
+}
+
+// SMTPTransport sends mail through a single SMTP relay, keeping up to
+// PoolSize idle connections open between sends
+type SMTPTransport struct {
+	config SMTPConfig
+	pool   chan *smtpConn
+}
+
+func NewSMTPTransport(config SMTPConfig) *SMTPTransport {
+	if config.PoolSize <= 0 {
+		config.PoolSize = 1
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &SMTPTransport{config: config, pool: make(chan *smtpConn, config.PoolSize)}
+}
+
+func (t *SMTPTransport) Send(ctx context.Context, from string, to []string, msg []byte) error {
+	if len(to) == 0 {
+		return errors.New("smtp: no recipients")
+	}
+	c, err := t.get(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := t.deliver(ctx, c, from, to, msg); err != nil {
+		c.close()
+		return err
+	}
+	t.put(c)
+	return nil
+}
+
+// Close closes all idle pooled connections
+func (t *SMTPTransport) Close() error {
+	for {
+		select {
+		case c := <-t.pool:
+			c.quit()
+		default:
+			return nil
+		}
+	}
+}
+
+func (t *SMTPTransport) deliver(ctx context.Context, c *smtpConn, from string, to []string, msg []byte) error {
+	c.conn.SetDeadline(t.deadline(ctx))
+	if err := c.client.Mail(from); err != nil {
+		return fmt.Errorf("smtp MAIL FROM: %w", err)
+	}
+	for _, rcpt := range to {
+		if err := c.client.Rcpt(rcpt); err != nil {
+			return fmt.Errorf("smtp RCPT TO: %w", err)
+		}
+	}
+	w, err := c.client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp DATA: %w", err)
+	}
+	return nil
+}
+
+// get returns a pooled connection that still answers NOOP, or dials a new one
+func (t *SMTPTransport) get(ctx context.Context) (*smtpConn, error) {
+	for {
+		select {
+		case c := <-t.pool:
+			c.conn.SetDeadline(t.deadline(ctx))
+			if err := c.client.Noop(); err == nil {
+				return c, nil
+			}
+			c.close()
+		default:
+			return t.dial(ctx)
+		}
+	}
+}
+
+func (t *SMTPTransport) put(c *smtpConn) {
+	c.conn.SetDeadline(time.Time{})
+	select {
+	case t.pool <- c:
+	default:
+		c.quit()
+	}
+}
+
+func (t *SMTPTransport) dial(ctx context.Context) (*smtpConn, error) {
+	addr := net.JoinHostPort(t.config.Host, t.config.Port)
+	dialer := &net.Dialer{Timeout: t.config.Timeout}
+
+	var conn net.Conn
+	var err error
+	switch t.config.TLS {
+	case SMTPTLSImplicit:
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: t.tlsConfig()}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", addr)
+	case SMTPTLSStartTLS, SMTPTLSNone, "":
+		conn, err = dialer.DialContext(ctx, "tcp", addr)
+	default:
+		return nil, fmt.Errorf("smtp: unknown TLS mode %q", t.config.TLS)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("smtp dial: %w", err)
+	}
+	conn.SetDeadline(t.deadline(ctx))
+
+	client, err := smtp.NewClient(conn, t.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp handshake: %w", err)
+	}
+	c := &smtpConn{conn: conn, client: client}
+
+	if t.config.TLS == SMTPTLSStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			c.close()
+			return nil, errors.New("smtp: server does not support STARTTLS")
+		}
+		if err := client.StartTLS(t.tlsConfig()); err != nil {
+			c.close()
+			return nil, fmt.Errorf("smtp STARTTLS: %w", err)
+		}
+	}
+
+	if auth := t.auth(); auth != nil {
+		if err := client.Auth(auth); err != nil {
+			c.close()
+			return nil, fmt.Errorf("smtp AUTH: %w", err)
+		}
+	}
+	return c, nil
+}
+
+func (t *SMTPTransport) auth() smtp.Auth {
+	switch t.config.Auth {
+	case SMTPAuthPlain:
+		return smtp.PlainAuth("", t.config.Username, t.config.Password, t.config.Host)
+	case SMTPAuthLogin:
+		return &loginAuth{host: t.config.Host, username: t.config.Username, password: t.config.Password}
+	}
+	return nil
+}
+
+func (t *SMTPTransport) tlsConfig() *tls.Config {
+	if t.config.TLSConfig != nil {
+		config := t.config.TLSConfig.Clone()
+		if config.ServerName == "" {
+			config.ServerName = t.config.Host
+		}
+		return config
+	}
+	return &tls.Config{ServerName: t.config.Host}
+}
+
+func (t *SMTPTransport) deadline(ctx context.Context) time.Time {
+	deadline := time.Now().Add(t.config.Timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		return d
+	}
+	return deadline
+}
+
+func (c *smtpConn) quit() {
+	c.conn.SetDeadline(time.Now().Add(time.Second))
+	if err := c.client.Quit(); err != nil {
+		c.conn.Close()
+	}
+}
+
+func (c *smtpConn) close() {
+	c.client.Close()
+}
+
+// loginAuth implements the AUTH LOGIN mechanism, which net/smtp does not provide
+type loginAuth struct {
+	host     string
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Same rule as smtp.PlainAuth: never send credentials in the clear to a remote host
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected server challenge: %q", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
+
+// ConsoleTransport prints messages to stdout instead of delivering them, useful for local development
+type ConsoleTransport struct{}
+
+func (ConsoleTransport) Send(ctx context.Context, from string, to []string, msg []byte) error {
+	fmt.Println(from, strings.Join(to, ","), string(msg))
+	return nil
+}
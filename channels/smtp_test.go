@@ -0,0 +1,389 @@
+package channels
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"math/big"
+	"net"
+	"notification/models/channel"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// smtpEnvelope is what the stand-in server recorded for a single transaction
+type smtpEnvelope struct {
+	From string
+	To   []string
+	Data string
+}
+
+// smtpStandIn is a minimal in-process SMTP server that records envelopes and DATA
+type smtpStandIn struct {
+	ln          net.Listener
+	tlsConfig   *tls.Config
+	implicitTLS bool
+	username    string
+	password    string
+
+	mu        sync.Mutex
+	envelopes []smtpEnvelope
+	conns     int
+	authed    []string
+}
+
+type smtpStandInOption func(*smtpStandIn)
+
+func withSMTPCredentials(username, password string) smtpStandInOption {
+	return func(s *smtpStandIn) { s.username, s.password = username, password }
+}
+
+func withSMTPImplicitTLS() smtpStandInOption {
+	return func(s *smtpStandIn) { s.implicitTLS = true }
+}
+
+func newSMTPStandIn(t *testing.T, opts ...smtpStandInOption) *smtpStandIn {
+	t.Helper()
+	s := &smtpStandIn{tlsConfig: &tls.Config{Certificates: []tls.Certificate{selfSignedCert(t)}}}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	if s.implicitTLS {
+		ln = tls.NewListener(ln, s.tlsConfig)
+	}
+	s.ln = ln
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpStandIn) port() string {
+	_, port, _ := net.SplitHostPort(s.ln.Addr().String())
+	return port
+}
+
+// config returns a transport config pointing at the stand-in that trusts its certificate
+func (s *smtpStandIn) config(tlsMode string) SMTPConfig {
+	pool := x509.NewCertPool()
+	leaf, _ := x509.ParseCertificate(s.tlsConfig.Certificates[0].Certificate[0])
+	pool.AddCert(leaf)
+	return SMTPConfig{
+		Host:      "127.0.0.1",
+		Port:      s.port(),
+		TLS:       tlsMode,
+		PoolSize:  1,
+		Timeout:   5 * time.Second,
+		TLSConfig: &tls.Config{RootCAs: pool},
+	}
+}
+
+func (s *smtpStandIn) messages() []smtpEnvelope {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]smtpEnvelope(nil), s.envelopes...)
+}
+
+func (s *smtpStandIn) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(lines ...string) {
+		for _, l := range lines {
+			conn.Write([]byte(l + "\r\n"))
+		}
+	}
+	readLine := func() (string, bool) {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return "", false
+		}
+		return strings.TrimRight(line, "\r\n"), true
+	}
+
+	_, isTLS := conn.(*tls.Conn)
+	var env smtpEnvelope
+	reply("220 127.0.0.1 ESMTP stand-in")
+	for {
+		line, ok := readLine()
+		if !ok {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			lines := []string{"250-127.0.0.1", "250-8BITMIME", "250-AUTH PLAIN LOGIN"}
+			if !isTLS {
+				lines = append(lines, "250-STARTTLS")
+			}
+			reply(append(lines, "250 OK")...)
+		case "STARTTLS":
+			reply("220 ready to start TLS")
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, isTLS = tlsConn, true
+			r = bufio.NewReader(conn)
+		case "AUTH":
+			mech, initial, _ := strings.Cut(arg, " ")
+			var user, pass string
+			switch strings.ToUpper(mech) {
+			case "PLAIN":
+				raw, _ := base64.StdEncoding.DecodeString(initial)
+				parts := strings.Split(string(raw), "\x00")
+				if len(parts) == 3 {
+					user, pass = parts[1], parts[2]
+				}
+			case "LOGIN":
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Username:")))
+				l, _ := readLine()
+				u, _ := base64.StdEncoding.DecodeString(l)
+				reply("334 " + base64.StdEncoding.EncodeToString([]byte("Password:")))
+				l, _ = readLine()
+				p, _ := base64.StdEncoding.DecodeString(l)
+				user, pass = string(u), string(p)
+			}
+			if user != s.username || pass != s.password {
+				reply("535 authentication failed")
+				continue
+			}
+			s.mu.Lock()
+			s.authed = append(s.authed, strings.ToUpper(mech))
+			s.mu.Unlock()
+			reply("235 authenticated")
+		case "MAIL":
+			env = smtpEnvelope{From: angleAddr(arg)}
+			reply("250 OK")
+		case "RCPT":
+			env.To = append(env.To, angleAddr(arg))
+			reply("250 OK")
+		case "DATA":
+			reply("354 end with <CRLF>.<CRLF>")
+			var data strings.Builder
+			for {
+				l, ok := readLine()
+				if !ok {
+					return
+				}
+				if l == "." {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, ".") + "\r\n")
+			}
+			env.Data = data.String()
+			s.mu.Lock()
+			s.envelopes = append(s.envelopes, env)
+			s.mu.Unlock()
+			reply("250 queued")
+		case "RSET", "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 command not implemented")
+		}
+	}
+}
+
+func (s *smtpStandIn) authMechanisms() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.authed...)
+}
+
+// angleAddr extracts the address from "FROM:<addr> PARAMS" style arguments
+func angleAddr(arg string) string {
+	_, rest, _ := strings.Cut(arg, "<")
+	addr, _, _ := strings.Cut(rest, ">")
+	return addr
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "127.0.0.1"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("create certificate: %v", err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestSMTPConfigFromEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		wantErr bool
+	}{
+		{"defaults", map[string]string{}, false},
+		{"login over implicit tls", map[string]string{"SMTP_USERNAME": "u", "SMTP_AUTH": "LOGIN", "SMTP_TLS": "tls"}, false},
+		{"unknown tls", map[string]string{"SMTP_TLS": "ssl"}, true},
+		{"unknown auth", map[string]string{"SMTP_USERNAME": "u", "SMTP_AUTH": "cram-md5"}, true},
+		{"auth without username", map[string]string{"SMTP_AUTH": "plain"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"SMTP_USERNAME", "SMTP_AUTH", "SMTP_TLS"} {
+				t.Setenv(key, tt.env[key])
+			}
+			config, err := SMTPConfigFromEnv()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SMTPConfigFromEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.name == "defaults" && (config.TLS != SMTPTLSStartTLS || config.Auth != SMTPAuthNone || config.Port != "587") {
+				t.Fatalf("unexpected defaults %+v", config)
+			}
+		})
+	}
+}
+
+func TestSMTPTransport_PlainConnection(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	tr := NewSMTPTransport(srv.config(SMTPTLSNone))
+	defer tr.Close()
+
+	msg := []byte("Subject: hi\r\n\r\nhello\r\n.leading dot\r\n")
+	if err := tr.Send(context.Background(), "from@example.com", []string{"to@example.com"}, msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := srv.messages()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(got))
+	}
+	if got[0].From != "from@example.com" || len(got[0].To) != 1 || got[0].To[0] != "to@example.com" {
+		t.Fatalf("unexpected envelope: %+v", got[0])
+	}
+	if !strings.Contains(got[0].Data, "hello\r\n.leading dot") {
+		t.Fatalf("unexpected data: %q", got[0].Data)
+	}
+}
+
+func TestSMTPTransport_StartTLSWithPlainAuth(t *testing.T) {
+	srv := newSMTPStandIn(t, withSMTPCredentials("user", "secret"))
+	config := srv.config(SMTPTLSStartTLS)
+	config.Auth, config.Username, config.Password = SMTPAuthPlain, "user", "secret"
+	tr := NewSMTPTransport(config)
+	defer tr.Close()
+
+	if err := tr.Send(context.Background(), "from@example.com", []string{"to@example.com"}, []byte("Subject: s\r\n\r\nbody")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if auth := srv.authMechanisms(); len(srv.messages()) != 1 || len(auth) != 1 || auth[0] != "PLAIN" {
+		t.Fatalf("expected one PLAIN-authenticated message, got %d messages, auth %v", len(srv.messages()), auth)
+	}
+}
+
+func TestSMTPTransport_ImplicitTLSWithLoginAuth(t *testing.T) {
+	srv := newSMTPStandIn(t, withSMTPImplicitTLS(), withSMTPCredentials("user", "secret"))
+	config := srv.config(SMTPTLSImplicit)
+	config.Auth, config.Username, config.Password = SMTPAuthLogin, "user", "secret"
+	tr := NewSMTPTransport(config)
+	defer tr.Close()
+
+	if err := tr.Send(context.Background(), "from@example.com", []string{"to@example.com"}, []byte("Subject: s\r\n\r\nbody")); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if auth := srv.authMechanisms(); len(srv.messages()) != 1 || len(auth) != 1 || auth[0] != "LOGIN" {
+		t.Fatalf("expected one LOGIN-authenticated message, got %d messages, auth %v", len(srv.messages()), auth)
+	}
+}
+
+func TestSMTPTransport_AuthFailure(t *testing.T) {
+	srv := newSMTPStandIn(t, withSMTPCredentials("user", "secret"))
+	config := srv.config(SMTPTLSStartTLS)
+	config.Auth, config.Username, config.Password = SMTPAuthPlain, "user", "wrong"
+	tr := NewSMTPTransport(config)
+
+	if err := tr.Send(context.Background(), "from@example.com", []string{"to@example.com"}, []byte("x")); err == nil {
+		t.Fatal("expected authentication error")
+	}
+	if len(srv.messages()) != 0 {
+		t.Fatalf("expected no messages, got %d", len(srv.messages()))
+	}
+}
+
+func TestSMTPTransport_ReusesPooledConnection(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	tr := NewSMTPTransport(srv.config(SMTPTLSNone))
+	defer tr.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := tr.Send(context.Background(), "from@example.com", []string{"to@example.com"}, []byte("x")); err != nil {
+			t.Fatalf("Send %d: %v", i, err)
+		}
+	}
+	if len(srv.messages()) != 3 {
+		t.Fatalf("expected 3 messages, got %d", len(srv.messages()))
+	}
+	if srv.connections() != 1 {
+		t.Fatalf("expected 1 connection, got %d", srv.connections())
+	}
+}
+
+func TestEmailSend_DeliversThroughTransport(t *testing.T) {
+	srv := newSMTPStandIn(t)
+	t.Setenv("EMAIL_FROM", "noreply@example.com")
+	c := &EmailChannel{Transport: NewSMTPTransport(srv.config(SMTPTLSNone))}
+
+	msg := channel.Message{Title: "Hola", Content: "Mundo", Meta: map[string]string{"template": "titled", "to": "user@example.com", "subject": "Greetings"}}
+	if err := c.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	got := srv.messages()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(got))
+	}
+	if got[0].From != "noreply@example.com" || got[0].To[0] != "user@example.com" {
+		t.Fatalf("unexpected envelope: %+v", got[0])
+	}
+	if !strings.Contains(got[0].Data, "Subject: Greetings") || !strings.Contains(got[0].Data, "Notification: Hola") {
+		t.Fatalf("unexpected data: %q", got[0].Data)
+	}
+}
@@ -0,0 +1,78 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"errors"
+	"fmt"
+	"notification/models/channel"
+	texttemplate "text/template"
+)
+
+// builtinEmailTemplates are compiled into the binary so they load regardless of the working directory
+//
+//go:embed email/*.tmpl
+var builtinEmailTemplates embed.FS
+
+// lookupTemplate returns the stored template named in meta.template for the
+// channel, at the version recorded in meta or the latest one. ok is false when
+// there is no template source, no name, or no stored body for the channel. A
+// pinned version that was deleted fails permanently rather than sending the
+// built-in template
+func lookupTemplate(ctx context.Context, src channel.TemplateSource, channelName string, msg channel.Message) (tmpl channel.Template, ok bool, err error) {
+	name := msg.Meta["template"]
+	if src == nil || name == "" {
+		return channel.Template{}, false, nil
+	}
+	version, err := channel.TemplateVersion(msg.Meta)
+	if err != nil {
+		return channel.Template{}, false, channel.Permanent(err)
+	}
+	tmpl, err = src.Lookup(ctx, name, version, channelName)
+	if errors.Is(err, channel.ErrTemplateNotFound) {
+		if version > 0 {
+			return channel.Template{}, false, channel.Permanent(fmt.Errorf("template %s version %d for %s no longer exists", name, version, channelName))
+		}
+		return channel.Template{}, false, nil
+	}
+	if err != nil {
+		return channel.Template{}, false, err
+	}
+	return tmpl, true, nil
+}
+
+func renderText(text string, msg channel.Message) (string, error) {
+	t, err := texttemplate.New("").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, msg); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// applyStoredTemplate replaces the title and content of msg with the stored
+// template body for the channel, if meta.template names one
+func applyStoredTemplate(ctx context.Context, src channel.TemplateSource, channelName string, msg *channel.Message) error {
+	tmpl, ok, err := lookupTemplate(ctx, src, channelName, *msg)
+	if err != nil || !ok {
+		return err
+	}
+
+	content, err := renderText(tmpl.Text, *msg)
+	if err != nil {
+		return err
+	}
+	if tmpl.Subject != "" {
+		title, err := renderText(tmpl.Subject, *msg)
+		if err != nil {
+			return err
+		}
+		msg.Title = title
+	}
+	msg.Content = content
+	return nil
+}
@@ -0,0 +1,157 @@
+package channels
+
+import (
+	"context"
+	"errors"
+	"notification/models/channel"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// fakeTemplateSource serves templates keyed by "name/channel", and pinned
+// versions by "name/channel/version"
+type fakeTemplateSource map[string]channel.Template
+
+func (f fakeTemplateSource) Lookup(ctx context.Context, name string, version int, channelName string) (channel.Template, error) {
+	key := name + "/" + channelName
+	if version > 0 {
+		key += "/" + strconv.Itoa(version)
+	}
+	tmpl, ok := f[key]
+	if !ok {
+		return channel.Template{}, channel.ErrTemplateNotFound
+	}
+	return tmpl, nil
+}
+
+type failingTemplateSource struct{}
+
+func (failingTemplateSource) Lookup(ctx context.Context, name string, version int, channelName string) (channel.Template, error) {
+	return channel.Template{}, errors.New("database is down")
+}
+
+func TestEmailSend_StoredTemplate(t *testing.T) {
+	tr := &recordingTransport{}
+	c := &EmailChannel{Transport: tr, Templates: fakeTemplateSource{
+		"welcome/email": {Name: "welcome", Version: 3, Subject: "Welcome, {{.Title}}", Text: "Text: {{.Content}}", HTML: "<b>{{.Content}}</b>"},
+	}}
+	msg := channel.Message{Title: "Ana", Content: "<hello>", Meta: map[string]string{"to": "user@example.com", "template": "welcome"}}
+	if err := c.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	header, parts := readAlternatives(t, tr.msg)
+	if header.Get("Subject") != "Welcome, Ana" {
+		t.Fatalf("unexpected subject: %q", header.Get("Subject"))
+	}
+	if parts["text/plain"] != "Text: <hello>" {
+		t.Fatalf("unexpected text part: %q", parts["text/plain"])
+	}
+	if parts["text/html"] != "<b>&lt;hello&gt;</b>" {
+		t.Fatalf("unexpected html part: %q", parts["text/html"])
+	}
+}
+
+func TestEmailSend_UnknownStoredTemplateFallsBackToBuiltin(t *testing.T) {
+	tr := &recordingTransport{}
+	c := &EmailChannel{Transport: tr, Templates: fakeTemplateSource{}}
+	msg := channel.Message{Title: "Hola", Content: "Mundo", Meta: map[string]string{"to": "user@example.com", "template": "titled"}}
+	if err := c.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if _, parts := readAlternatives(t, tr.msg); !strings.Contains(parts["text/html"], "Notification: Hola") {
+		t.Fatalf("expected built-in titled template, got %q", parts["text/html"])
+	}
+}
+
+func TestEmailSend_TemplateSourceError(t *testing.T) {
+	c := &EmailChannel{Transport: &recordingTransport{}, Templates: failingTemplateSource{}}
+	msg := channel.Message{Meta: map[string]string{"to": "user@example.com", "template": "welcome"}}
+	if err := c.Send(context.Background(), msg); err == nil {
+		t.Fatal("expected lookup error")
+	}
+}
+
+func TestSMSPrepare_StoredTemplate(t *testing.T) {
+	c := &SMSChannel{Templates: fakeTemplateSource{"otp/sms": {Text: "Code: {{.Content}}"}}}
+	msg := channel.Message{Content: "123456", Meta: map[string]string{"template": "otp"}}
+	if err := c.Prepare(context.Background(), &msg); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if msg.Content != "Code: 123456" {
+		t.Fatalf("unexpected content: %q", msg.Content)
+	}
+}
+
+func TestSMSPrepare_PinnedTemplateVersion(t *testing.T) {
+	c := &SMSChannel{Templates: fakeTemplateSource{
+		"otp/sms":   {Version: 2, Text: "Your code: {{.Content}}"},
+		"otp/sms/1": {Version: 1, Text: "Code: {{.Content}}"},
+	}}
+	msg := channel.Message{Content: "123456", Meta: map[string]string{"template": "otp", channel.MetaTemplateVersion: "1"}}
+	if err := c.Prepare(context.Background(), &msg); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if msg.Content != "Code: 123456" {
+		t.Fatalf("expected the pinned version, got %q", msg.Content)
+	}
+
+	msg = channel.Message{Content: "123456", Meta: map[string]string{"template": "otp", channel.MetaTemplateVersion: "latest"}}
+	var permanent *channel.PermanentError
+	if err := c.Prepare(context.Background(), &msg); !errors.As(err, &permanent) {
+		t.Fatalf("expected a permanent error for an invalid version, got %v", err)
+	}
+}
+
+func TestEmailSend_DeletedPinnedVersionFails(t *testing.T) {
+	tr := &recordingTransport{}
+	c := &EmailChannel{Transport: tr, Templates: fakeTemplateSource{
+		"welcome/email": {Name: "welcome", Version: 3, Text: "Hi {{.Title}}"},
+	}}
+	msg := channel.Message{Title: "Ana", Meta: map[string]string{"to": "user@example.com", "template": "welcome", channel.MetaTemplateVersion: "2"}}
+	err := c.Send(context.Background(), msg)
+	var permanent *channel.PermanentError
+	if !errors.As(err, &permanent) || !strings.Contains(err.Error(), "version 2") {
+		t.Fatalf("expected a permanent error naming the deleted version, got %v", err)
+	}
+	if tr.msg != nil {
+		t.Fatal("the built-in template should not be sent instead")
+	}
+}
+
+func TestPushPrepare_StoredTemplate(t *testing.T) {
+	c := &PushChannel{Templates: fakeTemplateSource{"chat/push": {Subject: "New message from {{.Title}}", Text: "{{.Content}}"}}}
+	msg := channel.Message{Title: "John", Content: "hi", Meta: map[string]string{"template": "chat"}}
+	if err := c.Prepare(context.Background(), &msg); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if msg.Title != "New message from John" || msg.Content != "hi" {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+}
+
+func TestPushPrepare_NoTemplate(t *testing.T) {
+	c := &PushChannel{Templates: fakeTemplateSource{}}
+	msg := channel.Message{Title: "t", Content: "c", Meta: map[string]string{"template": "missing"}}
+	if err := c.Prepare(context.Background(), &msg); err != nil {
+		t.Fatalf("Prepare: %v", err)
+	}
+	if msg.Title != "t" || msg.Content != "c" {
+		t.Fatalf("message should be unchanged: %+v", msg)
+	}
+}
+
+func TestEmailPreview_StoredTemplate(t *testing.T) {
+	c := &EmailChannel{Templates: fakeTemplateSource{
+		"welcome/email": {Subject: "Welcome, {{.Title}}", Text: "Text: {{.Content}}", HTML: "<b>{{.Content}}</b>"},
+	}}
+	msg := channel.Message{Title: "Ana", Content: "hi", Meta: map[string]string{"to": "user@example.com", "template": "welcome"}}
+	preview, err := c.Preview(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if preview.Subject != "Welcome, Ana" || preview.Text != "Text: hi" || preview.HTML != "<b>hi</b>" {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+}
@@ -0,0 +1,171 @@
+package channels
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"notification/models/channel"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultWebhookTimeout = 10 * time.Second
+
+	// WebhookSignatureHeader carries "sha256=" followed by the hex HMAC-SHA256 of
+	// the timestamp header value, a dot and the request body
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookTimestampHeader = "X-Webhook-Timestamp"
+)
+
+// WebhookSecrets provides the secret used to sign the webhooks of a user
+type WebhookSecrets interface {
+	Secret(ctx context.Context, userID uint) (string, error)
+}
+
+// ValidWebhookMeta represents the required metadata for webhook notifications
+type ValidWebhookMeta struct {
+	URL  string            `json:"url" example:"https://example.com/hooks/notifications"`
+	Data map[string]string `json:"data,omitempty" swaggertype:"object,string"`
+}
+
+type webhookPayload struct {
+	Title   string            `json:"title"`
+	Content string            `json:"content"`
+	UserID  uint              `json:"user_id,omitempty"`
+	Data    map[string]string `json:"data,omitempty"`
+}
+
+type WebhookChannel struct {
+	// Secrets provides the per-user signing secret; requests are sent unsigned when nil
+	Secrets WebhookSecrets
+	// Client sends the requests. When nil they only go to public addresses and
+	// redirects aren't followed
+	Client *http.Client
+	// Timeout bounds each request, defaults to 10s when zero
+	Timeout time.Duration
+	// Templates resolves meta.template to a stored webhook title (subject) and body
+	Templates channel.TemplateSource
+}
+
+func (c *WebhookChannel) Name() string {
+	return "webhook"
+}
+
+func (c *WebhookChannel) Validate(meta map[string]string) error {
+	u, err := url.Parse(meta["url"])
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url field with a valid http(s) URL is required")
+	}
+	if s := meta["data"]; s != "" {
+		if err := json.Unmarshal([]byte(s), &map[string]string{}); err != nil {
+			return fmt.Errorf("invalid data json: %w", err)
+		}
+	}
+	return nil
+}
+
+func (c *WebhookChannel) Prepare(ctx context.Context, msg *channel.Message) error {
+	if err := msg.RenderVariables(false); err != nil {
+		return err
+	}
+	return applyStoredTemplate(ctx, c.Templates, c.Name(), msg)
+}
+
+// Send POSTs the message as JSON to meta.url. Any response other than 2xx is
+// returned as an error so the delivery can be retried
+func (c *WebhookChannel) Send(ctx context.Context, msg channel.Message) error {
+	body, err := buildWebhookBody(msg)
+	if err != nil {
+		return err
+	}
+
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = defaultWebhookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.Meta["url"], bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if err := c.sign(ctx, req, msg.UserID, body); err != nil {
+		return err
+	}
+
+	resp, err := c.client().Do(req)
+	if errors.Is(err, errPrivateAddress) {
+		return channel.Permanent(fmt.Errorf("webhook request failed: %w", err))
+	}
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	// drain so the connection can be reused
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (c *WebhookChannel) Preview(ctx context.Context, msg channel.Message) (channel.Preview, error) {
+	body, err := buildWebhookBody(msg)
+	if err != nil {
+		return channel.Preview{}, err
+	}
+	return channel.Preview{Subject: msg.Title, Text: msg.Content, Payload: body}, nil
+}
+
+func (c *WebhookChannel) client() *http.Client {
+	if c.Client == nil {
+		return outboundClient
+	}
+	return c.Client
+}
+
+func (c *WebhookChannel) sign(ctx context.Context, req *http.Request, userID uint, body []byte) error {
+	if c.Secrets == nil {
+		return nil
+	}
+	secret, err := c.Secrets.Secret(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("webhook secret: %w", err)
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set(WebhookTimestampHeader, timestamp)
+	req.Header.Set(WebhookSignatureHeader, "sha256="+SignWebhook(secret, timestamp, body))
+	return nil
+}
+
+// SignWebhook returns the hex HMAC-SHA256 of timestamp + "." + body. Receivers
+// recompute it with their secret and compare it to the signature header
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func buildWebhookBody(msg channel.Message) ([]byte, error) {
+	payload := webhookPayload{Title: msg.Title, Content: msg.Content, UserID: msg.UserID}
+	if s := msg.Meta["data"]; s != "" {
+		if err := json.Unmarshal([]byte(s), &payload.Data); err != nil {
+			return nil, fmt.Errorf("invalid data json: %w", err)
+		}
+	}
+	return json.Marshal(payload)
+}
@@ -0,0 +1,188 @@
+package channels
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"notification/models/channel"
+	"testing"
+	"time"
+)
+
+type staticSecrets map[uint]string
+
+func (s staticSecrets) Secret(ctx context.Context, userID uint) (string, error) {
+	secret, ok := s[userID]
+	if !ok {
+		return "", errors.New("no secret")
+	}
+	return secret, nil
+}
+
+func TestWebhookValidate(t *testing.T) {
+	c := &WebhookChannel{}
+	tests := []struct {
+		name    string
+		meta    map[string]string
+		wantErr bool
+	}{
+		{"https", map[string]string{"url": "https://example.com/hook"}, false},
+		{"http with data", map[string]string{"url": "http://localhost:9000/hook", "data": `{"k":"v"}`}, false},
+		{"missing url", map[string]string{}, true},
+		{"relative url", map[string]string{"url": "/hook"}, true},
+		{"unsupported scheme", map[string]string{"url": "ftp://example.com/hook"}, true},
+		{"invalid data", map[string]string{"url": "https://example.com/hook", "data": "not-json"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.Validate(tt.meta); (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWebhookSend_Signed(t *testing.T) {
+	var gotBody []byte
+	var gotHeader http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotBody, _ = io.ReadAll(r.Body)
+		gotHeader = r.Header
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := &WebhookChannel{Secrets: staticSecrets{7: "s3cret"}, Client: srv.Client()}
+	msg := channel.Message{Title: "Deploy", Content: "done", UserID: 7, Meta: map[string]string{"url": srv.URL, "data": `{"env":"prod"}`}}
+	if err := c.Send(context.Background(), msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var payload webhookPayload
+	if err := json.Unmarshal(gotBody, &payload); err != nil {
+		t.Fatalf("invalid body: %v", err)
+	}
+	if payload.Title != "Deploy" || payload.Content != "done" || payload.UserID != 7 || payload.Data["env"] != "prod" {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+	if gotHeader.Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected content type: %q", gotHeader.Get("Content-Type"))
+	}
+	timestamp := gotHeader.Get(WebhookTimestampHeader)
+	if want := "sha256=" + SignWebhook("s3cret", timestamp, gotBody); gotHeader.Get(WebhookSignatureHeader) != want || timestamp == "" {
+		t.Fatalf("unexpected signature %q (timestamp %q), want %q", gotHeader.Get(WebhookSignatureHeader), timestamp, want)
+	}
+}
+
+func TestWebhookSend_Non2xx(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c := &WebhookChannel{Client: srv.Client()}
+	if err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"url": srv.URL}}); err == nil {
+		t.Fatal("expected error for 502 response")
+	}
+}
+
+func TestWebhookSend_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer srv.Close()
+	defer close(release)
+
+	c := &WebhookChannel{Timeout: 50 * time.Millisecond, Client: srv.Client()}
+	if err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"url": srv.URL}}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+}
+
+func TestWebhookSend_SecretError(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	c := &WebhookChannel{Secrets: staticSecrets{}, Client: srv.Client()}
+	if err := c.Send(context.Background(), channel.Message{UserID: 1, Meta: map[string]string{"url": srv.URL}}); err == nil {
+		t.Fatal("expected secret error")
+	}
+	if called {
+		t.Fatal("unsigned request should not be sent")
+	}
+}
+
+func TestWebhookSend_RefusesPrivateAddresses(t *testing.T) {
+	called := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer srv.Close()
+
+	c := &WebhookChannel{}
+	for _, u := range []string{srv.URL, "http://10.0.0.1/hook", "http://169.254.169.254/latest/meta-data/", "http://[::1]:9/hook"} {
+		err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"url": u}})
+		var permanent *channel.PermanentError
+		if !errors.Is(err, errPrivateAddress) || !errors.As(err, &permanent) {
+			t.Fatalf("%s: expected a permanent private address error, got %v", u, err)
+		}
+	}
+	if called {
+		t.Fatal("the loopback server should not be reached")
+	}
+}
+
+func TestWebhookSend_DoesNotFollowRedirects(t *testing.T) {
+	redirected := false
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redirected = true
+	}))
+	defer target.Close()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target.URL, http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	// the default client, allowed to reach the loopback test servers
+	client := *outboundClient
+	client.Transport = srv.Client().Transport
+	c := &WebhookChannel{Client: &client}
+	if err := c.Send(context.Background(), channel.Message{Meta: map[string]string{"url": srv.URL}}); err == nil {
+		t.Fatal("expected an error for the redirect response")
+	}
+	if redirected {
+		t.Fatal("the redirect should not be followed")
+	}
+}
+
+func TestPublicAddressOnly(t *testing.T) {
+	tests := []struct {
+		address string
+		public  bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"[::1]:80", false},
+		{"10.1.2.3:80", false},
+		{"172.16.0.1:80", false},
+		{"192.168.1.1:80", false},
+		{"169.254.169.254:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+		{"0.0.0.0:80", false},
+	}
+	for _, tt := range tests {
+		if err := publicAddressOnly("tcp", tt.address, nil); (err == nil) != tt.public {
+			t.Errorf("%s: expected public %v, got %v", tt.address, tt.public, err)
+		}
+	}
+}
@@ -6,6 +6,9 @@
 // @description - Email: channels.ValidEmailMeta
 // @description - SMS: channels.ValidSMSMeta
 // @description - Push: channels.ValidPushMeta
+// @description - Webhook: channels.ValidWebhookMeta
+// @description - Chat: channels.ValidChatMeta
+// @description - In-app: channels.ValidInAppMeta
 // @host localhost:8080
 // @BasePath /
 // @schemes http
@@ -18,9 +21,13 @@ package main
 import (
 	"context"
 	"log"
+	"maps"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -30,8 +37,17 @@ import (
 	_ "notification/docs"
 	"notification/models"
 	"notification/models/channel"
+	"notification/services/categories"
+	"notification/services/deadletters"
+	"notification/services/devices"
+	"notification/services/events"
+	"notification/services/inbox"
 	"notification/services/notifier"
+	"notification/services/preferences"
+	"notification/services/profiles"
+	"notification/services/templates"
 	usersvc "notification/services/user"
+	"notification/services/webhooks"
 	"notification/storage"
 
 	"github.com/gin-gonic/gin"
@@ -54,34 +70,187 @@ func main() {
 		log.Fatalf("Error connecting to database: %v", err)
 	}
 	db.Debug()
-	db.AutoMigrate(&models.User{}, &models.Notification{}, &models.Outbox{})
+	db.AutoMigrate(&models.User{}, &models.Notification{}, &models.Outbox{}, &models.Template{}, &models.TemplateBody{}, &models.WebhookSecret{}, &models.InboxMessage{}, &models.Device{}, &models.ContactProfile{}, &models.Preference{}, &models.DeliveryError{})
+
+	templateService := templates.New(db)
+	webhookService := webhooks.New(db)
+	deviceService := devices.New(db)
+	// verification codes for new profile addresses go straight to the email and
+	// SMS channels, never through the notifications the user can read
+	codeChannels := map[string]channel.Channel{}
+	profileService := profiles.New(db, profiles.WithCodeSender(profiles.ChannelCodeSender(codeChannels)))
+	// events buffered per SSE/WebSocket connection before a slow client is dropped
+	streamBuffer, _ := strconv.Atoi(os.Getenv("STREAM_BUFFER"))
+	hub := events.NewHub(streamBuffer)
+	inboxService := inbox.New(db, inbox.WithEvents(hub))
+
+	// Email goes to the console unless an SMTP relay is configured
+	var mailTransport channels.MailTransport = channels.ConsoleTransport{}
+	if os.Getenv("SMTP_HOST") != "" {
+		smtpConfig, err := channels.SMTPConfigFromEnv()
+		if err != nil {
+			log.Fatalf("Invalid SMTP configuration: %v", err)
+		}
+		smtpTransport := channels.NewSMTPTransport(smtpConfig)
+		defer smtpTransport.Close()
+		mailTransport = smtpTransport
+	}
+
+	emailChannel := &channels.EmailChannel{Transport: mailTransport, Contacts: profileService, Templates: templateService}
+	if dir := os.Getenv("EMAIL_BLOB_DIR"); dir != "" {
+		blobs, err := channels.NewDirBlobStore(dir)
+		if err != nil {
+			log.Fatalf("Error opening email blob store: %v", err)
+		}
+		emailChannel.Blobs = blobs
+	}
+
+	webhookChannel := &channels.WebhookChannel{Secrets: webhookService, Templates: templateService}
+	if s := os.Getenv("WEBHOOK_TIMEOUT"); s != "" {
+		timeout, err := time.ParseDuration(s)
+		if err != nil {
+			log.Fatalf("Invalid WEBHOOK_TIMEOUT: %v", err)
+		}
+		webhookChannel.Timeout = timeout
+	}
+	smsProviders, smsRoutes := channels.SMSProvidersFromEnv()
+	smsMaxSegments, _ := strconv.Atoi(os.Getenv("SMS_MAX_SEGMENTS"))
+	smsChannel := &channels.SMSChannel{
+		Providers:       smsProviders,
+		Routes:          smsRoutes,
+		DefaultProvider: os.Getenv("SMS_PROVIDER"),
+		MaxSegments:     smsMaxSegments,
+		DefaultRegion:   os.Getenv("SMS_DEFAULT_REGION"),
+		AllowedRegions:  strings.Fields(strings.ReplaceAll(os.Getenv("SMS_ALLOWED_COUNTRIES"), ",", " ")),
+		DeniedRegions:   strings.Fields(strings.ReplaceAll(os.Getenv("SMS_DENIED_COUNTRIES"), ",", " ")),
+		Contacts:        profileService,
+		Templates:       templateService,
+	}
+	pushProviders, err := channels.PushProvidersFromEnv()
+	if err != nil {
+		log.Fatalf("Error configuring push providers: %v", err)
+	}
+	pushChannel := &channels.PushChannel{Providers: pushProviders, Tokens: deviceService, Devices: deviceService, Templates: templateService}
+	chatChannel := &channels.ChatChannel{Timeout: webhookChannel.Timeout, Templates: templateService}
+
+	codeChannels["email"], codeChannels["sms"] = emailChannel, smsChannel
 
 	// Initialize notifier service
 	channelList := map[string]channel.Channel{
-		"email": &channels.EmailChannel{},
-		"sms":   &channels.SMSChannel{},
-		"push":  &channels.PushChannel{},
+		"email":   emailChannel,
+		"sms":     smsChannel,
+		"push":    pushChannel,
+		"webhook": webhookChannel,
+		"chat":    chatChannel,
+		"inapp":   &channels.InAppChannel{Inbox: inboxService, Templates: templateService},
 	}
 
-	notifierService := notifier.NewNotifierService(db, channelList)
+	channelNames := slices.Sorted(maps.Keys(channelList))
+	categoryList := categories.Builtin
+	if path := os.Getenv("CATEGORIES_FILE"); path != "" {
+		if categoryList, err = categories.ReadFile(path); err != nil {
+			log.Fatalf("Error loading categories: %v", err)
+		}
+	}
+	categoryRegistry, err := categories.New(categoryList, channelNames)
+	if err != nil {
+		log.Fatalf("Invalid categories: %v", err)
+	}
+	preferenceService := preferences.New(db, channelNames, categoryRegistry)
+	retryPolicies, err := notifier.ParseRetryPolicies(os.Getenv("RETRY_POLICIES"))
+	if err != nil {
+		log.Fatalf("Invalid RETRY_POLICIES: %v", err)
+	}
+	for name := range retryPolicies {
+		if _, ok := channelList[name]; !ok && name != "*" {
+			log.Fatalf("Invalid RETRY_POLICIES: unknown channel %q", name)
+		}
+	}
+	notifierService := notifier.NewNotifierService(db, channelList,
+		notifier.WithEvents(hub), notifier.WithPreferences(preferenceService), notifier.WithCategories(categoryRegistry),
+		notifier.WithRetryPolicies(retryPolicies), notifier.WithTemplates(templateService))
 	notifierController := controllers.NewNotificationController(notifierService)
 
 	// Initialize worker
-	worker := notifier.NewWorker(db, notifierService, 30*time.Second, 1)
+	var leaseDuration time.Duration
+	if s := os.Getenv("OUTBOX_LEASE"); s != "" {
+		if leaseDuration, err = time.ParseDuration(s); err != nil || leaseDuration <= 0 {
+			log.Fatalf("Invalid OUTBOX_LEASE: %q", s)
+		}
+	}
+	batchSize, _ := strconv.Atoi(os.Getenv("WORKER_BATCH_SIZE"))
+	if batchSize <= 0 {
+		batchSize = 50
+	}
+	concurrency, _ := strconv.Atoi(os.Getenv("WORKER_CONCURRENCY"))
+	channelConcurrency, err := notifier.ParseChannelConcurrency(os.Getenv("WORKER_CHANNEL_CONCURRENCY"))
+	if err != nil {
+		log.Fatalf("Invalid WORKER_CHANNEL_CONCURRENCY: %v", err)
+	}
+	for name := range channelConcurrency {
+		if _, ok := channelList[name]; !ok {
+			log.Fatalf("Invalid WORKER_CHANNEL_CONCURRENCY: unknown channel %q", name)
+		}
+	}
+	var drainTimeout time.Duration
+	if s := os.Getenv("WORKER_DRAIN_TIMEOUT"); s != "" {
+		if drainTimeout, err = time.ParseDuration(s); err != nil || drainTimeout <= 0 {
+			log.Fatalf("Invalid WORKER_DRAIN_TIMEOUT: %q", s)
+		}
+	}
+	worker := notifier.NewWorker(db, notifierService, 30*time.Second, batchSize,
+		notifier.WithLease(os.Getenv("WORKER_ID"), leaseDuration),
+		notifier.WithConcurrency(concurrency),
+		notifier.WithChannelConcurrency(channelConcurrency),
+		notifier.WithDrainTimeout(drainTimeout))
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
-	go worker.Start(ctx)
+	workerDone := make(chan struct{})
+	go func() {
+		worker.Start(ctx)
+		close(workerDone)
+	}()
 
-	router := gin.Default()
+	// the default logger would write the ?access_token= of streaming clients to the log
+	router := gin.New()
+	router.Use(middleware.Logger(), gin.Recovery())
 	// Users routes
 	userService := usersvc.New(db)
 	userController := controllers.NewUserController(userService)
 
+	templateController := controllers.NewTemplateController(templateService)
+	webhookController := controllers.NewWebhookController(webhookService)
+	inboxController := controllers.NewInboxController(inboxService)
+	deviceController := controllers.NewDeviceController(deviceService)
+	profileController := controllers.NewProfileController(profileService)
+	preferenceController := controllers.NewPreferenceController(preferenceService, categoryRegistry)
+	deadLetterController := controllers.NewDeadLetterController(deadletters.New(db))
+	streamController := controllers.NewStreamController(hub, inboxService)
+	webSocketController := controllers.NewWebSocketController(hub, notifierService, inboxService)
+
 	// Setup routes and middleware
-	SetupRoutes(router, userController, notifierController, middleware.AuthMiddleware(userService))
+	SetupRoutes(router, Controllers{
+		User:         userController,
+		Notification: notifierController,
+		Template:     templateController,
+		Webhook:      webhookController,
+		Inbox:        inboxController,
+		Device:       deviceController,
+		Profile:      profileController,
+		Preference:   preferenceController,
+		DeadLetter:   deadLetterController,
+		Stream:       streamController,
+		WebSocket:    webSocketController,
+	}, Middlewares{
+		Auth:       middleware.AuthMiddleware(userService),
+		StreamAuth: middleware.StreamAuthMiddleware(userService),
+		Admin:      middleware.AdminMiddleware(),
+	})
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
 	srv := &http.Server{Addr: ":8080", Handler: router}
+	// end open event streams, or Shutdown would wait for them forever
+	srv.RegisterOnShutdown(hub.Close)
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("listen: %v", err)
@@ -89,4 +258,6 @@ func main() {
 	}()
 	<-ctx.Done()
 	_ = srv.Shutdown(context.Background())
+	// let the sends in flight finish
+	<-workerDone
 }
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"net/http"
+	"notification/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AdminMiddleware lets only admins through. It runs after AuthMiddleware
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := c.Get("user")
+		if !ok {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		if !user.(models.User).IsAdmin {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		c.Next()
+	}
+}
@@ -25,28 +25,47 @@ func AuthMiddleware(up UserProvider) gin.HandlerFunc {
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
+		authenticate(c, up, tokenString)
+	}
+}
 
-		token, _ := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected method: %s", token.Header["alg"])
-			}
-			return []byte(os.Getenv("JWT_SECRET")), nil
-		})
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			if float64(claims["exp"].(float64)) < float64(time.Now().Unix()) {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
-				c.Abort()
-				return
-			}
-			user, err := up.GetById(c.Request.Context(), uint(claims["sub"].(float64)))
-			if err != nil {
-				c.AbortWithStatus(http.StatusUnauthorized)
-				return
-			}
-			c.Set("user", user)
-			c.Next()
-		} else {
+// StreamAuthMiddleware also accepts the token in the access_token query
+// parameter, since browser EventSource and WebSocket clients can't set headers
+func StreamAuthMiddleware(up UserProvider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString, found := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !found {
+			tokenString = c.Query("access_token")
+		}
+		if tokenString == "" {
 			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+		authenticate(c, up, tokenString)
+	}
+}
+
+func authenticate(c *gin.Context, up UserProvider, tokenString string) {
+	token, _ := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected method: %s", token.Header["alg"])
+		}
+		return []byte(os.Getenv("JWT_SECRET")), nil
+	})
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		if float64(claims["exp"].(float64)) < float64(time.Now().Unix()) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			c.Abort()
+			return
+		}
+		user, err := up.GetById(c.Request.Context(), uint(claims["sub"].(float64)))
+		if err != nil {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
 		}
+		c.Set("user", user)
+		c.Next()
+	} else {
+		c.AbortWithStatus(http.StatusUnauthorized)
 	}
 }
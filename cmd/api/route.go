@@ -6,20 +6,88 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(router *gin.Engine, userController *controllers.UserController, notifierController *controllers.NotificationController, authMiddleware gin.HandlerFunc) {
+// Controllers holds the handlers of the API routes
+type Controllers struct {
+	User         *controllers.UserController
+	Notification *controllers.NotificationController
+	Template     *controllers.TemplateController
+	Webhook      *controllers.WebhookController
+	Inbox        *controllers.InboxController
+	Device       *controllers.DeviceController
+	Profile      *controllers.ProfileController
+	Preference   *controllers.PreferenceController
+	DeadLetter   *controllers.DeadLetterController
+	Stream       *controllers.StreamController
+	WebSocket    *controllers.WebSocketController
+}
+
+// Middlewares holds the middleware guarding the API routes
+type Middlewares struct {
+	Auth gin.HandlerFunc
+	// StreamAuth also accepts the token as the access_token query parameter
+	StreamAuth gin.HandlerFunc
+	Admin      gin.HandlerFunc
+}
+
+func SetupRoutes(router *gin.Engine, c Controllers, m Middlewares) {
 	// Public routes
-	router.POST("/signup", userController.Signup)
-	router.POST("/login", userController.Login)
-	router.GET("/notifications/channels/schemas", notifierController.GetChannelSchemas)
+	router.POST("/signup", c.User.Signup)
+	router.POST("/login", c.User.Login)
+	router.GET("/notifications/channels/schemas", c.Notification.GetChannelSchemas)
 
 	// Protected routes
 	protected := router.Group("/")
-	protected.Use(authMiddleware)
+	protected.Use(m.Auth)
+	{
+		protected.POST("/notifications", c.Notification.CreateNotification)
+		protected.POST("/notifications/preview", c.Notification.PreviewNotification)
+		protected.GET("/notifications", c.Notification.ListNotifications)
+		protected.GET("/notifications/:id", c.Notification.GetNotification)
+		protected.PATCH("/notifications/:id", c.Notification.UpdateNotification)
+		protected.DELETE("/notifications/:id", c.Notification.DeleteNotification)
+
+		// templates are shared by every user, so only admins change them
+		protected.POST("/templates", m.Admin, c.Template.CreateTemplate)
+		protected.GET("/templates", c.Template.ListTemplates)
+		protected.GET("/templates/:name", c.Template.GetTemplate)
+		protected.GET("/templates/:name/versions", c.Template.ListTemplateVersions)
+		protected.PUT("/templates/:name", m.Admin, c.Template.UpdateTemplate)
+		protected.DELETE("/templates/:name", m.Admin, c.Template.DeleteTemplate)
+
+		protected.GET("/webhooks/secret", c.Webhook.GetSecret)
+		protected.POST("/webhooks/secret/rotate", c.Webhook.RotateSecret)
+
+		protected.GET("/inbox", c.Inbox.ListInbox)
+		protected.GET("/inbox/unread-count", c.Inbox.UnreadCount)
+		protected.POST("/inbox/read-all", c.Inbox.MarkAllRead)
+		protected.POST("/inbox/:id/read", c.Inbox.MarkRead)
+
+		protected.POST("/devices", c.Device.RegisterDevice)
+		protected.GET("/devices", c.Device.ListDevices)
+		protected.DELETE("/devices/:id", c.Device.DeleteDevice)
+
+		protected.GET("/profile", c.Profile.GetProfile)
+		protected.PUT("/profile", c.Profile.UpdateProfile)
+		protected.POST("/profile/verify", c.Profile.VerifyProfile)
+
+		protected.GET("/preferences", c.Preference.ListPreferences)
+		protected.PUT("/preferences", c.Preference.UpdatePreferences)
+		protected.GET("/preferences/categories", c.Preference.ListCategories)
+	}
+
+	// Admin routes
+	admin := protected.Group("/admin")
+	admin.Use(m.Admin)
 	{
-		protected.POST("/notifications", notifierController.CreateNotification)
-		protected.GET("/notifications", notifierController.ListNotifications)
-		protected.GET("/notifications/:id", notifierController.GetNotification)
-		protected.PATCH("/notifications/:id", notifierController.UpdateNotification)
-		protected.DELETE("/notifications/:id", notifierController.DeleteNotification)
+		admin.GET("/dead-letters", c.DeadLetter.ListDeadLetters)
+		admin.POST("/dead-letters/replay", c.DeadLetter.ReplayDeadLetters)
+		admin.POST("/dead-letters/discard", c.DeadLetter.DiscardDeadLetters)
+		admin.GET("/dead-letters/:id", c.DeadLetter.GetDeadLetter)
+		admin.POST("/dead-letters/:id/replay", c.DeadLetter.ReplayDeadLetter)
+		admin.DELETE("/dead-letters/:id", c.DeadLetter.DiscardDeadLetter)
 	}
+
+	// Streaming routes also accept the token as a query parameter
+	router.GET("/stream", m.StreamAuth, c.Stream.Stream)
+	router.GET("/ws", m.StreamAuth, c.WebSocket.Connect)
 }
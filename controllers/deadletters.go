@@ -0,0 +1,207 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"notification/models"
+	"notification/services/deadletters"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxDeadLetterPageSize = 200
+
+type DeadLetterController struct {
+	svc *deadletters.Service
+}
+
+func NewDeadLetterController(svc *deadletters.Service) *DeadLetterController {
+	return &DeadLetterController{svc: svc}
+}
+
+// DeadLetterFilterDTO selects dead letters for a batch replay or discard; empty fields match everything
+type DeadLetterFilterDTO struct {
+	ChannelName string `json:"channel_name,omitempty" example:"sms"`
+	// Error matches dead letters whose last error contains it
+	Error  string `json:"error,omitempty" example:"status 503"`
+	Status string `json:"status,omitempty" example:"DEAD_LETTER" enums:"DEAD_LETTER,FAILED"`
+	// All must be set to discard with an empty filter
+	All bool `json:"all,omitempty" example:"false"`
+}
+
+func (dto DeadLetterFilterDTO) filter() deadletters.Filter {
+	return deadletters.Filter{ChannelName: dto.ChannelName, Error: dto.Error, Status: models.Status(dto.Status), All: dto.All}
+}
+
+// @Summary List dead letters
+// @Description List deliveries that won't be sent again on their own: DEAD_LETTER rows that used all their attempts and FAILED rows that hit a permanent error. Admin only.
+// @Tags admin
+// @Produce json
+// @Param channel query string false "Channel name"
+// @Param error query string false "Text the last error contains"
+// @Param status query string false "Only this status" Enums(DEAD_LETTER, FAILED)
+// @Param limit query int false "Page size (default 50, max 200)"
+// @Param offset query int false "Rows to skip"
+// @Success 200 {array} models.Outbox
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/dead-letters [get]
+func (dc *DeadLetterController) ListDeadLetters(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > maxDeadLetterPageSize {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	filter := deadletters.Filter{ChannelName: c.Query("channel"), Error: c.Query("error"), Status: models.Status(c.Query("status"))}
+
+	list, err := dc.svc.List(c.Request.Context(), filter, limit, offset)
+	if err != nil {
+		if errors.Is(err, deadletters.ErrInvalidFilter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// @Summary Get dead letter
+// @Description Get a dead letter with the error of every failed attempt. Admin only.
+// @Tags admin
+// @Produce json
+// @Param id path int true "Outbox ID"
+// @Success 200 {object} deadletters.DeadLetter
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/dead-letters/{id} [get]
+func (dc *DeadLetterController) GetDeadLetter(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	dl, err := dc.svc.Get(c.Request.Context(), uint(id))
+	if err != nil {
+		if errors.Is(err, deadletters.ErrDeadLetterNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, dl)
+}
+
+// @Summary Replay dead letter
+// @Description Return a dead letter to PENDING so it is sent again with a fresh set of attempts. Its error history is kept. Admin only.
+// @Tags admin
+// @Param id path int true "Outbox ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/dead-letters/{id}/replay [post]
+func (dc *DeadLetterController) ReplayDeadLetter(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := dc.svc.Replay(c.Request.Context(), uint(id)); err != nil {
+		if errors.Is(err, deadletters.ErrDeadLetterNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Replay dead letters
+// @Description Return every dead letter matching the filter to PENDING. An empty filter replays all of them. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param data body DeadLetterFilterDTO true "Filter"
+// @Success 200 {object} models.DeadLetterBatchResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/dead-letters/replay [post]
+func (dc *DeadLetterController) ReplayDeadLetters(c *gin.Context) {
+	var dto DeadLetterFilterDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	count, err := dc.svc.ReplayAll(c.Request.Context(), dto.filter())
+	if err != nil {
+		if errors.Is(err, deadletters.ErrInvalidFilter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, models.DeadLetterBatchResponse{Count: count})
+}
+
+// @Summary Discard dead letter
+// @Description Delete a dead letter and its error history; the notification is kept. Admin only.
+// @Tags admin
+// @Param id path int true "Outbox ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/dead-letters/{id} [delete]
+func (dc *DeadLetterController) DiscardDeadLetter(c *gin.Context) {
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := dc.svc.Discard(c.Request.Context(), uint(id)); err != nil {
+		if errors.Is(err, deadletters.ErrDeadLetterNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Dead letter not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Discard dead letters
+// @Description Delete every dead letter matching the filter with its error history. An empty filter is rejected unless "all" is true, which discards all of them. Admin only.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param data body DeadLetterFilterDTO true "Filter"
+// @Success 200 {object} models.DeadLetterBatchResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /admin/dead-letters/discard [post]
+func (dc *DeadLetterController) DiscardDeadLetters(c *gin.Context) {
+	var dto DeadLetterFilterDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	count, err := dc.svc.DiscardAll(c.Request.Context(), dto.filter())
+	if err != nil {
+		if errors.Is(err, deadletters.ErrInvalidFilter) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, models.DeadLetterBatchResponse{Count: count})
+}
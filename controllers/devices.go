@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"notification/models"
+	"notification/services/devices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type DeviceController struct {
+	svc *devices.Service
+}
+
+func NewDeviceController(svc *devices.Service) *DeviceController {
+	return &DeviceController{svc: svc}
+}
+
+type RegisterDeviceDTO struct {
+	Token      string `json:"token" example:"device_token_xyz123"`
+	Platform   string `json:"platform" example:"android" enums:"android,ios,web"`
+	AppVersion string `json:"app_version,omitempty" example:"2.4.1"`
+}
+
+// @Summary Register device
+// @Description Register a push token for the current user. Push notifications with meta.user_id are sent to every active device of that user.
+// @Description Registering a token again updates it and makes it active if the provider had reported it invalid. A token registered to another user can only be taken over once the provider reported it invalid.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param data body RegisterDeviceDTO true "Device"
+// @Success 201 {object} models.Device
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /devices [post]
+func (dc *DeviceController) RegisterDevice(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var dto RegisterDeviceDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	device, err := dc.svc.Register(c.Request.Context(), user.(models.User).ID, dto.Token, dto.Platform, dto.AppVersion)
+	if err != nil {
+		if errors.Is(err, devices.ErrInvalidDevice) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, devices.ErrDeviceConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusCreated, device)
+}
+
+// @Summary List devices
+// @Description List the devices of the current user, including tokens the push provider reported invalid
+// @Tags devices
+// @Produce json
+// @Success 200 {array} models.Device
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /devices [get]
+func (dc *DeviceController) ListDevices(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	list, err := dc.svc.List(c.Request.Context(), user.(models.User).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// @Summary Delete device
+// @Description Unregister a device of the current user
+// @Tags devices
+// @Param id path int true "Device ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /devices/{id} [delete]
+func (dc *DeviceController) DeleteDevice(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := dc.svc.Delete(c.Request.Context(), user.(models.User).ID, uint(id)); err != nil {
+		if errors.Is(err, devices.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
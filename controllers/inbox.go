@@ -0,0 +1,130 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"notification/models"
+	"notification/services/inbox"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxInboxPageSize = 100
+
+type InboxController struct {
+	svc *inbox.Service
+}
+
+func NewInboxController(svc *inbox.Service) *InboxController {
+	return &InboxController{svc: svc}
+}
+
+// @Summary List inbox
+// @Description List the in-app messages of the current user, newest first
+// @Tags inbox
+// @Produce json
+// @Param unread query bool false "Only unread messages"
+// @Param limit query int false "Page size (default 50, max 100)"
+// @Param offset query int false "Messages to skip"
+// @Success 200 {array} models.InboxMessage
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /inbox [get]
+func (ic *InboxController) ListInbox(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > maxInboxPageSize {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	unread, _ := strconv.ParseBool(c.Query("unread"))
+
+	list, err := ic.svc.List(c.Request.Context(), user.(models.User).ID, unread, limit, offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// @Summary Unread count
+// @Description Count the unread in-app messages of the current user
+// @Tags inbox
+// @Produce json
+// @Success 200 {object} models.UnreadCountResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /inbox/unread-count [get]
+func (ic *InboxController) UnreadCount(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	count, err := ic.svc.UnreadCount(c.Request.Context(), user.(models.User).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, models.UnreadCountResponse{Unread: count})
+}
+
+// @Summary Mark message read
+// @Description Mark an in-app message of the current user as read
+// @Tags inbox
+// @Param id path int true "Inbox message ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /inbox/{id}/read [post]
+func (ic *InboxController) MarkRead(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	id, _ := strconv.Atoi(c.Param("id"))
+	if err := ic.svc.MarkRead(c.Request.Context(), user.(models.User).ID, uint(id)); err != nil {
+		if errors.Is(err, inbox.ErrMessageNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Inbox message not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// @Summary Mark all read
+// @Description Mark every in-app message of the current user as read
+// @Tags inbox
+// @Success 204 "No Content"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /inbox/read-all [post]
+func (ic *InboxController) MarkAllRead(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if _, err := ic.svc.MarkAllRead(c.Request.Context(), user.(models.User).ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -22,11 +22,16 @@ func NewNotificationController(svc *notifier.NotifierService) *NotificationContr
 }
 
 type CreateNotificationDTO struct {
-	Title       string         `json:"title"`
-	Content     string         `json:"content"`
-	ChannelName string         `json:"channel_name"`
-	Meta        map[string]any `json:"meta"`
-	ScheduledAt *string        `json:"scheduled_at,omitempty"`
+	Title           string         `json:"title"`
+	Content         string         `json:"content"`
+	ChannelName     string         `json:"channel_name"`
+	Meta            map[string]any `json:"meta"`
+	Variables       map[string]any `json:"variables,omitempty"`
+	StrictVariables bool           `json:"strict_variables,omitempty"`
+	RecipientUserID uint           `json:"recipient_user_id,omitempty"`
+	Category        string         `json:"category,omitempty"`
+	Priority        string         `json:"priority,omitempty"`
+	ScheduledAt     *string        `json:"scheduled_at,omitempty"`
 }
 
 type UpdateNotificationDTO struct {
@@ -37,17 +42,40 @@ type UpdateNotificationDTO struct {
 }
 
 func (dto *CreateNotificationDTO) normalizeMeta() map[string]string {
-	normalizedMeta := make(map[string]string, len(dto.Meta))
-	for k, v := range dto.Meta {
+	return normalizeValues(dto.Meta)
+}
+
+// normalizeValues keeps strings as-is and encodes any other JSON value as a string
+func normalizeValues(values map[string]any) map[string]string {
+	normalized := make(map[string]string, len(values))
+	for k, v := range values {
 		switch val := v.(type) {
 		case string:
-			normalizedMeta[k] = val
+			normalized[k] = val
 		default:
 			b, _ := json.Marshal(val)
-			normalizedMeta[k] = string(b)
+			normalized[k] = string(b)
 		}
 	}
-	return normalizedMeta
+	return normalized
+}
+
+func (dto *CreateNotificationDTO) request(userID uint) notifier.NotificationRequest {
+	req := notifier.NotificationRequest{
+		Title:           dto.Title,
+		Content:         dto.Content,
+		ChannelName:     dto.ChannelName,
+		Meta:            dto.normalizeMeta(),
+		UserID:          userID,
+		RecipientUserID: dto.RecipientUserID,
+		Category:        dto.Category,
+		Priority:        models.Priority(dto.Priority),
+	}
+	if dto.Variables != nil {
+		req.Variables = normalizeValues(dto.Variables)
+		req.StrictVariables = dto.StrictVariables
+	}
+	return req
 }
 
 func parseTime(s string) (time.Time, error) {
@@ -55,14 +83,25 @@ func parseTime(s string) (time.Time, error) {
 }
 
 // @Summary Create notification
-// @Description Create and enqueue a notification. Supports multiple channels: email, sms, and push.
+// @Description Create and enqueue a notification. Supports multiple channels: email, sms, push, webhook, chat and inapp.
 // @Description
 // @Description **Email Channel** - See channels.ValidEmailMeta for required meta fields
 // @Description **SMS Channel** - See channels.ValidSMSMeta for required meta fields
 // @Description **Push Channel** - See channels.ValidPushMeta for required meta fields
+// @Description **Webhook Channel** - See channels.ValidWebhookMeta for required meta fields
+// @Description **Chat Channel** - See channels.ValidChatMeta for required meta fields
+// @Description **In-app Channel** - See channels.ValidInAppMeta for optional meta fields
+// @Description
+// @Description **recipient_user_id**: Optional. Sends to a user instead of an address: email, SMS and push take the address (to, phone, devices) missing from meta from the user's contact profile, and in-app delivers to that user.
+// @Description
+// @Description **category**: Optional. The kind of notification, one of GET /preferences/categories such as security, transactional or marketing; defaults to general. Notifications the recipient opted out of through their preferences for the channel and category are recorded as SKIPPED instead of sent. Mandatory categories (security, transactional) are always sent.
+// @Description
+// @Description **priority**: Optional. low, normal or high; defaults to the priority of the category. Higher priorities are dispatched first, and push notifications use it when meta.options has no priority.
 // @Description
 // @Description **scheduled_at**: Optional. Use RFC3339 format (e.g., "2025-10-27T10:00:00Z"). If not provided, the notification will be sent immediately.
 // @Description
+// @Description **variables**: Optional. Values substituted into title, content and meta.subject through Go template placeholders such as .name or .order_id. With **strict_variables** the request is rejected when a referenced variable is missing; otherwise missing variables render empty.
+// @Description
 // @Description **Example:** {"title":"Welcome","content":"Welcome message","channel_name":"email","meta":{"to":"user@example.com","subject":"Welcome!"},"scheduled_at":"2025-10-27T10:00:00Z"}
 // @Tags notifications
 // @Accept json
@@ -71,6 +110,7 @@ func parseTime(s string) (time.Time, error) {
 // @Success 202 {object} models.MessageResponse
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Security BearerAuth
 // @Router /notifications [post]
@@ -87,13 +127,7 @@ func (nc *NotificationController) CreateNotification(c *gin.Context) {
 		return
 	}
 
-	req := notifier.NotificationRequest{
-		Title:       dto.Title,
-		Content:     dto.Content,
-		ChannelName: dto.ChannelName,
-		Meta:        dto.normalizeMeta(),
-		UserID:      user.(models.User).ID,
-	}
+	req := dto.request(user.(models.User).ID)
 
 	if dto.ScheduledAt != nil {
 		t, err := parseTime(*dto.ScheduledAt)
@@ -105,11 +139,16 @@ func (nc *NotificationController) CreateNotification(c *gin.Context) {
 	}
 
 	if err := nc.svc.CreateAndEnqueue(c.Request.Context(), req); err != nil {
+		if errors.Is(err, notifier.ErrRecipientNotAllowed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
 		if errors.Is(err, notifier.ErrInvalidChannel) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel name"})
 			return
 		}
-		if errors.Is(err, notifier.ErrInvalidMetadata) {
+		if errors.Is(err, notifier.ErrInvalidMetadata) || errors.Is(err, notifier.ErrInvalidVariables) || errors.Is(err, notifier.ErrInvalidRecipient) ||
+			errors.Is(err, notifier.ErrInvalidCategory) || errors.Is(err, notifier.ErrInvalidPriority) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -119,18 +158,81 @@ func (nc *NotificationController) CreateNotification(c *gin.Context) {
 	c.JSON(http.StatusAccepted, gin.H{"message": "Notification created and enqueued"})
 }
 
+// @Summary Preview notification
+// @Description Render a notification exactly as the worker would send it, without creating it.
+// @Description The body is the same as for creating a notification; scheduled_at is ignored.
+// @Description
+// @Description The response holds the rendered subject and text, the HTML body for email, the provider payload for push and the segment count for SMS.
+// @Tags notifications
+// @Accept json
+// @Produce json
+// @Param data body CreateNotificationDTO true "Notification data"
+// @Success 200 {object} channel.Preview
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 422 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /notifications/preview [post]
+func (nc *NotificationController) PreviewNotification(c *gin.Context) {
+	var dto CreateNotificationDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	preview, err := nc.svc.Preview(c.Request.Context(), dto.request(user.(models.User).ID))
+	if err != nil {
+		if errors.Is(err, notifier.ErrRecipientNotAllowed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, notifier.ErrInvalidChannel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid channel name"})
+			return
+		}
+		if errors.Is(err, notifier.ErrInvalidMetadata) || errors.Is(err, notifier.ErrInvalidVariables) || errors.Is(err, notifier.ErrInvalidRecipient) ||
+			errors.Is(err, notifier.ErrInvalidCategory) || errors.Is(err, notifier.ErrInvalidPriority) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, notifier.ErrRenderFailed) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, preview)
+}
+
 // @Summary List notifications
 // @Description List user notifications
 // @Tags notifications
 // @Produce json
+// @Param category query string false "Only notifications of this category"
+// @Param priority query string false "Only notifications of this priority" Enums(low, normal, high)
 // @Success 200 {array} models.NotificationResponse
 // @Failure 401 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Security BearerAuth
 // @Router /notifications [get]
 func (nc *NotificationController) ListNotifications(c *gin.Context) {
-	var list []models.Notification
-	list, err := nc.svc.ListNotifications(c.Request.Context(), c.GetInt("user_id"), 50, 0)
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	filter := notifier.ListFilter{Category: c.Query("category"), Priority: models.Priority(c.Query("priority"))}
+	list, err := nc.svc.ListNotifications(c.Request.Context(), user.(models.User).ID, filter, 50, 0)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -175,6 +277,7 @@ func (nc *NotificationController) GetNotification(c *gin.Context) {
 // @Success 204 "No Content"
 // @Failure 400 {object} models.ErrorResponse
 // @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
 // @Failure 404 {object} models.ErrorResponse
 // @Failure 500 {object} models.ErrorResponse
 // @Security BearerAuth
@@ -187,22 +290,20 @@ func (nc *NotificationController) UpdateNotification(c *gin.Context) {
 		return
 	}
 
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
 	req := notifier.UpdateNotificationRequest{
+		UserID:  user.(models.User).ID,
 		Title:   dto.Title,
 		Content: dto.Content,
 	}
 
 	if dto.Meta != nil {
-		req.Meta = make(map[string]string, len(dto.Meta))
-		for k, v := range dto.Meta {
-			switch val := v.(type) {
-			case string:
-				req.Meta[k] = val
-			default:
-				b, _ := json.Marshal(val)
-				req.Meta[k] = string(b)
-			}
-		}
+		req.Meta = normalizeValues(dto.Meta)
 	}
 
 	if dto.ScheduledAt != nil {
@@ -219,6 +320,14 @@ func (nc *NotificationController) UpdateNotification(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Notification not found"})
 			return
 		}
+		if errors.Is(err, notifier.ErrRecipientNotAllowed) {
+			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, notifier.ErrInvalidMetadata) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
 		return
 	}
@@ -260,16 +369,32 @@ func (nc *NotificationController) GetChannelSchemas(c *gin.Context) {
 			To:       "user@example.com",
 			Subject:  "Email subject",
 			Template: "titled",
+			Attachments: []channels.EmailAttachment{
+				{Filename: "invoice.pdf", ContentType: "application/pdf", Blob: "invoices/2025/10/123.pdf"},
+				{Filename: "logo.png", Content: "iVBORw0KGgo=", ContentID: "logo"},
+			},
 		},
 		SMS: channels.ValidSMSMeta{
-			Phone:   "+1234567890",
+			Phone:   "+14155552671",
 			Carrier: "verizon",
 		},
 		Push: channels.ValidPushMeta{
 			Token:    "device_token_xyz123",
 			Platform: "android",
 			Data:     map[string]string{"message_id": "123"},
-			Options:  map[string]string{"priority": "high"},
+			Options:  map[string]string{"priority": "high", "ttl": "3600", "collapse_key": "chat-456"},
+		},
+		Webhook: channels.ValidWebhookMeta{
+			URL:  "https://example.com/hooks/notifications",
+			Data: map[string]string{"order_id": "123"},
+		},
+		Chat: channels.ValidChatMeta{
+			WebhookURL: "https://hooks.slack.com/services/T000/B000/XXXX",
+			Context:    "api-prod | us-east-1",
+		},
+		InApp: channels.ValidInAppMeta{
+			UserID: "123",
+			Data:   `{"post_id":"7"}`,
 		},
 	}
 	c.JSON(http.StatusOK, schemas)
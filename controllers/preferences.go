@@ -0,0 +1,109 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"notification/models"
+	"notification/services/categories"
+	"notification/services/preferences"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PreferenceController struct {
+	svc        *preferences.Service
+	categories *categories.Registry
+}
+
+func NewPreferenceController(svc *preferences.Service, registry *categories.Registry) *PreferenceController {
+	return &PreferenceController{svc: svc, categories: registry}
+}
+
+type PreferenceDTO struct {
+	ChannelName string `json:"channel_name" example:"sms"`
+	Category    string `json:"category" example:"marketing"`
+	Enabled     bool   `json:"enabled" example:"false"`
+}
+
+type UpdatePreferencesDTO struct {
+	Preferences []PreferenceDTO `json:"preferences"`
+}
+
+// @Summary List preferences
+// @Description List the notification preferences of the current user. Without a matching preference, a notification is sent if its channel is one of the default channels of its category.
+// @Tags preferences
+// @Produce json
+// @Success 200 {array} models.Preference
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /preferences [get]
+func (pc *PreferenceController) ListPreferences(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	list, err := pc.svc.List(c.Request.Context(), user.(models.User).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// @Summary Update preferences
+// @Description Opt in or out of notifications per channel and category, e.g. {"channel_name":"sms","category":"marketing","enabled":false}. Use * as the channel or category to match all of them.
+// @Description The most specific preference applies: channel and category, then the channel, then the category, then */*. Preferences not listed are kept. Notifications the user opted out of are recorded as SKIPPED.
+// @Description Mandatory categories can't be turned off, and categories sent only on some channels by default need a preference naming the category to be received on others.
+// @Tags preferences
+// @Accept json
+// @Produce json
+// @Param data body UpdatePreferencesDTO true "Preferences"
+// @Success 200 {array} models.Preference
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /preferences [put]
+func (pc *PreferenceController) UpdatePreferences(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var dto UpdatePreferencesDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	updates := make([]preferences.Update, 0, len(dto.Preferences))
+	for _, p := range dto.Preferences {
+		updates = append(updates, preferences.Update{ChannelName: p.ChannelName, Category: p.Category, Enabled: p.Enabled})
+	}
+
+	list, err := pc.svc.Set(c.Request.Context(), user.(models.User).ID, updates)
+	if err != nil {
+		if errors.Is(err, preferences.ErrInvalidPreference) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// @Summary List categories
+// @Description List the notification categories with whether users may opt out of them, the channels they are sent on by default and their default priority
+// @Tags preferences
+// @Produce json
+// @Success 200 {array} models.Category
+// @Failure 401 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /preferences/categories [get]
+func (pc *PreferenceController) ListCategories(c *gin.Context) {
+	c.JSON(http.StatusOK, pc.categories.List())
+}
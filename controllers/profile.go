@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"notification/models"
+	"notification/services/profiles"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ProfileController struct {
+	svc *profiles.Service
+}
+
+func NewProfileController(svc *profiles.Service) *ProfileController {
+	return &ProfileController{svc: svc}
+}
+
+type UpdateProfileDTO struct {
+	Email string `json:"email,omitempty" example:"user@example.com"`
+	Phone string `json:"phone,omitempty" example:"(415) 555-2671"`
+	// Region is the ISO 3166-1 code a phone number in national format is read in
+	Region   string `json:"region,omitempty" example:"US"`
+	Locale   string `json:"locale,omitempty" example:"en-US"`
+	Timezone string `json:"timezone,omitempty" example:"America/New_York"`
+}
+
+type VerifyProfileDTO struct {
+	// Field is the address the code was sent to: email or phone
+	Field string `json:"field" binding:"required" example:"email"`
+	Code  string `json:"code" binding:"required" example:"123456"`
+}
+
+// @Summary Get contact profile
+// @Description Get the contact profile of the current user: the email, phone and devices notifications sent with recipient_user_id are delivered to, plus locale and timezone.
+// @Description The email defaults to the account email.
+// @Tags profile
+// @Produce json
+// @Success 200 {object} models.ContactProfile
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /profile [get]
+func (pc *ProfileController) GetProfile(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	profile, err := pc.svc.Get(c.Request.Context(), user.(models.User).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Update contact profile
+// @Description Replace the contact details of the current user. An empty email falls back to the account email; the phone is normalized to E.164.
+// @Description A new email or phone is sent a verification code and isn't used for notifications until verified with POST /profile/verify.
+// @Description Devices are managed with the /devices endpoints.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param data body UpdateProfileDTO true "Contact details"
+// @Success 200 {object} models.ContactProfile
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /profile [put]
+func (pc *ProfileController) UpdateProfile(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var dto UpdateProfileDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, err := pc.svc.Update(c.Request.Context(), user.(models.User).ID, profiles.ProfileUpdate{
+		Email:    dto.Email,
+		Phone:    dto.Phone,
+		Region:   dto.Region,
+		Locale:   dto.Locale,
+		Timezone: dto.Timezone,
+	})
+	if err != nil {
+		if errors.Is(err, profiles.ErrInvalidProfile) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// @Summary Verify a profile address
+// @Description Verify the profile email or phone with the code sent to it when it was saved. Codes expire after 15 minutes and are invalidated after 5 wrong attempts.
+// @Tags profile
+// @Accept json
+// @Produce json
+// @Param data body VerifyProfileDTO true "Verification code"
+// @Success 200 {object} models.ContactProfile
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /profile/verify [post]
+func (pc *ProfileController) VerifyProfile(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var dto VerifyProfileDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	profile, err := pc.svc.Verify(c.Request.Context(), user.(models.User).ID, dto.Field, dto.Code)
+	if err != nil {
+		if errors.Is(err, profiles.ErrInvalidProfile) || errors.Is(err, profiles.ErrInvalidCode) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
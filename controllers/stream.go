@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"net/http"
+	"notification/models"
+	"notification/services/events"
+	"notification/services/inbox"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamHeartbeat = 30 * time.Second
+	// inbox messages replayed per query when a client resumes
+	streamReplayBatch = 100
+)
+
+type StreamController struct {
+	hub   *events.Hub
+	inbox *inbox.Service
+}
+
+func NewStreamController(hub *events.Hub, inbox *inbox.Service) *StreamController {
+	return &StreamController{hub: hub, inbox: inbox}
+}
+
+// @Summary Event stream
+// @Description Server-Sent Events stream of the current user's inbox messages and sent notifications.
+// @Description
+// @Description **inbox** events carry the new models.InboxMessage and its ID as the event id. Reconnecting clients send it back in the Last-Event-ID header (browsers do this automatically) or the last_event_id query parameter, and every message stored since is replayed first.
+// @Description **notification** events carry the notification_id, channel_name and status of a sent notification. They have no id and are not replayed.
+// @Description
+// @Description Browser EventSource clients can't set headers, so the JWT may be passed in the access_token query parameter instead.
+// @Tags stream
+// @Produce text/event-stream
+// @Param Last-Event-ID header string false "Last inbox event id received"
+// @Param last_event_id query string false "Last inbox event id received"
+// @Param access_token query string false "JWT, when the Authorization header can't be set"
+// @Success 200 {string} string "text/event-stream"
+// @Failure 401 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /stream [get]
+func (sc *StreamController) Stream(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userID := user.(models.User).ID
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	lastID, _ := strconv.ParseUint(lastEventID, 10, 64)
+
+	// subscribe before replaying so nothing published in between is lost
+	sub := sc.hub.Subscribe(userID)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	ctx := c.Request.Context()
+	if lastID > 0 {
+		for {
+			list, err := sc.inbox.Since(ctx, userID, uint(lastID), streamReplayBatch)
+			if err != nil {
+				return
+			}
+			for _, m := range list {
+				if writeEvent(c, inbox.Event(m)) != nil {
+					return
+				}
+				lastID = uint64(m.ID)
+			}
+			if len(list) < streamReplayBatch {
+				break
+			}
+		}
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				// the client fell behind or the server is shutting down; it reconnects
+				// and resumes from its last event ID
+				return
+			}
+			if e.ID != "" {
+				id, _ := strconv.ParseUint(e.ID, 10, 64)
+				if id <= lastID {
+					continue // already replayed
+				}
+				lastID = id
+			}
+			if writeEvent(c, e) != nil {
+				return
+			}
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := c.Writer.WriteString(": ping\n\n"); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeEvent(c *gin.Context, e events.Event) error {
+	return sse.Encode(c.Writer, sse.Event{Id: e.ID, Event: e.Type, Data: e.Data})
+}
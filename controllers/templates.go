@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"notification/services/templates"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type TemplateController struct {
+	svc *templates.Service
+}
+
+func NewTemplateController(svc *templates.Service) *TemplateController {
+	return &TemplateController{svc: svc}
+}
+
+type TemplateBodyDTO struct {
+	ChannelName string `json:"channel_name" example:"email"`
+	Subject     string `json:"subject,omitempty" example:"Welcome aboard"`
+	Text        string `json:"text" example:"Thanks for signing up."`
+	HTML        string `json:"html,omitempty" example:"<p>Thanks for signing up.</p>"`
+}
+
+type CreateTemplateDTO struct {
+	Name   string            `json:"name" example:"welcome"`
+	Bodies []TemplateBodyDTO `json:"bodies"`
+}
+
+type UpdateTemplateDTO struct {
+	Bodies []TemplateBodyDTO `json:"bodies"`
+}
+
+func toBodyRequests(bodies []TemplateBodyDTO) []templates.BodyRequest {
+	reqs := make([]templates.BodyRequest, 0, len(bodies))
+	for _, b := range bodies {
+		reqs = append(reqs, templates.BodyRequest{ChannelName: b.ChannelName, Subject: b.Subject, Text: b.Text, HTML: b.HTML})
+	}
+	return reqs
+}
+
+func templateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, templates.ErrTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+	case errors.Is(err, templates.ErrTemplateExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Template already exists"})
+	case errors.Is(err, templates.ErrInvalidTemplate):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+}
+
+// @Summary Create template
+// @Description Create version 1 of a template with one body per channel. Bodies use Go template syntax and can reference the notification fields .Title, .Content and .Meta.
+// @Description Email bodies require both text and html; other channels only use text, and push uses subject as the title.
+// @Description Admin only.
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param data body CreateTemplateDTO true "Template data"
+// @Success 201 {object} models.Template
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /templates [post]
+func (tc *TemplateController) CreateTemplate(c *gin.Context) {
+	var dto CreateTemplateDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tmpl, err := tc.svc.Create(c.Request.Context(), dto.Name, toBodyRequests(dto.Bodies))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, tmpl)
+}
+
+// @Summary List templates
+// @Description List the latest version of every template
+// @Tags templates
+// @Produce json
+// @Success 200 {array} models.Template
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /templates [get]
+func (tc *TemplateController) ListTemplates(c *gin.Context) {
+	list, err := tc.svc.List(c.Request.Context())
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// @Summary Get template
+// @Description Get the latest version of a template, or a specific one with ?version=
+// @Tags templates
+// @Produce json
+// @Param name path string true "Template name"
+// @Param version query int false "Template version"
+// @Success 200 {object} models.Template
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /templates/{name} [get]
+func (tc *TemplateController) GetTemplate(c *gin.Context) {
+	version, _ := strconv.Atoi(c.Query("version"))
+	tmpl, err := tc.svc.Get(c.Request.Context(), c.Param("name"), version)
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tmpl)
+}
+
+// @Summary List template versions
+// @Description List every version of a template, newest first
+// @Tags templates
+// @Produce json
+// @Param name path string true "Template name"
+// @Success 200 {array} models.Template
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /templates/{name}/versions [get]
+func (tc *TemplateController) ListTemplateVersions(c *gin.Context) {
+	list, err := tc.svc.Versions(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// @Summary Update template
+// @Description Create a new version of a template. Admin only. Notifications created afterwards use the new version; notifications already created keep the version they were created with.
+// @Tags templates
+// @Accept json
+// @Produce json
+// @Param name path string true "Template name"
+// @Param data body UpdateTemplateDTO true "Template bodies"
+// @Success 200 {object} models.Template
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /templates/{name} [put]
+func (tc *TemplateController) UpdateTemplate(c *gin.Context) {
+	var dto UpdateTemplateDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tmpl, err := tc.svc.Update(c.Request.Context(), c.Param("name"), toBodyRequests(dto.Bodies))
+	if err != nil {
+		templateError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, tmpl)
+}
+
+// @Summary Delete template
+// @Description Delete all versions of a template. Admin only.
+// @Tags templates
+// @Param name path string true "Template name"
+// @Success 204 "No Content"
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /templates/{name} [delete]
+func (tc *TemplateController) DeleteTemplate(c *gin.Context) {
+	if err := tc.svc.Delete(c.Request.Context(), c.Param("name")); err != nil {
+		templateError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,67 @@
+package controllers
+
+import (
+	"net/http"
+	"notification/models"
+	"notification/services/webhooks"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebhookController struct {
+	svc *webhooks.Service
+}
+
+func NewWebhookController(svc *webhooks.Service) *WebhookController {
+	return &WebhookController{svc: svc}
+}
+
+// @Summary Get webhook secret
+// @Description Get the secret your webhook notifications are signed with, generating it on first use.
+// @Description
+// @Description Each webhook request carries an X-Webhook-Timestamp header and an X-Webhook-Signature header of the form sha256=HEX, the HMAC-SHA256 of the timestamp, a dot and the raw request body.
+// @Tags webhooks
+// @Produce json
+// @Success 200 {object} models.WebhookSecret
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /webhooks/secret [get]
+func (wc *WebhookController) GetSecret(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	secret, err := wc.svc.Get(c.Request.Context(), user.(models.User).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, secret)
+}
+
+// @Summary Rotate webhook secret
+// @Description Replace the webhook signing secret. Webhooks sent afterwards are signed with the new secret.
+// @Tags webhooks
+// @Produce json
+// @Success 200 {object} models.WebhookSecret
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /webhooks/secret/rotate [post]
+func (wc *WebhookController) RotateSecret(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	secret, err := wc.svc.Rotate(c.Request.Context(), user.(models.User).ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, secret)
+}
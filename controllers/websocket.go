@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"notification/models"
+	"notification/services/events"
+	"notification/services/inbox"
+	"notification/services/notifier"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	// acks are small; anything larger is a misbehaving client
+	wsMaxFrameBytes = 4 << 10
+)
+
+// wsFrame is a message sent to the client: an event, a ping or an error
+type wsFrame struct {
+	Type  string `json:"type"`
+	ID    string `json:"id,omitempty"`
+	Data  any    `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// wsAck is a message received from the client acknowledging a notification
+type wsAck struct {
+	Type           string `json:"type"`
+	NotificationID uint   `json:"notification_id"`
+	Status         string `json:"status"`
+}
+
+type WebSocketController struct {
+	hub      *events.Hub
+	notifier *notifier.NotifierService
+	inbox    *inbox.Service
+}
+
+func NewWebSocketController(hub *events.Hub, notifier *notifier.NotifierService, inbox *inbox.Service) *WebSocketController {
+	return &WebSocketController{hub: hub, notifier: notifier, inbox: inbox}
+}
+
+// @Summary WebSocket gateway
+// @Description Upgrade to a WebSocket that receives the same inbox and notification events as /stream, as JSON frames of the form type, id, data.
+// @Description Every open connection of a user receives every event. A connection that doesn't keep up with its events is closed.
+// @Description
+// @Description Clients acknowledge notifications by sending a frame with type ack, notification_id and status, either delivered or read. Acks set delivered_at and read_at on the notification, and a read ack also marks the inbox message as read.
+// @Description
+// @Description Browsers can't set headers on WebSocket requests, so the JWT may be passed in the access_token query parameter instead.
+// @Tags stream
+// @Param access_token query string false "JWT, when the Authorization header can't be set"
+// @Success 101 "Switching Protocols"
+// @Failure 401 {object} models.ErrorResponse
+// @Security BearerAuth
+// @Router /ws [get]
+func (wc *WebSocketController) Connect(c *gin.Context) {
+	user, ok := c.Get("user")
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	userID := user.(models.User).ID
+
+	// No Origin check: the connection is authorized by the token, not by cookies
+	server := websocket.Server{Handler: func(conn *websocket.Conn) {
+		wc.serve(conn, userID)
+	}}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+func (wc *WebSocketController) serve(conn *websocket.Conn, userID uint) {
+	defer conn.Close()
+	conn.MaxPayloadBytes = wsMaxFrameBytes
+
+	sub := wc.hub.Subscribe(userID)
+	defer sub.Close()
+
+	// replies to acks go through the writer so frames are never written concurrently
+	replies := make(chan wsFrame, 8)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wc.readAcks(conn, userID, replies)
+	}()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		var frame wsFrame
+		select {
+		case <-done:
+			return
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			frame = wsFrame{Type: e.Type, ID: e.ID, Data: e.Data}
+		case frame = <-replies:
+		case <-heartbeat.C:
+			frame = wsFrame{Type: "ping"}
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+		if err := websocket.JSON.Send(conn, frame); err != nil {
+			return
+		}
+	}
+}
+
+func (wc *WebSocketController) readAcks(conn *websocket.Conn, userID uint, replies chan<- wsFrame) {
+	ctx := conn.Request().Context()
+	for {
+		var ack wsAck
+		err := websocket.JSON.Receive(conn, &ack)
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		switch {
+		case err == nil:
+		case errors.Is(err, websocket.ErrFrameTooLarge), errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+			// the frame was discarded, the connection is still usable
+			reply(replies, wsFrame{Type: "error", Error: "invalid frame"})
+			continue
+		default:
+			return
+		}
+		if ack.Type != "ack" {
+			reply(replies, wsFrame{Type: "error", Error: "unknown frame type"})
+			continue
+		}
+
+		err = wc.notifier.Acknowledge(ctx, userID, ack.NotificationID, ack.Status)
+		if errors.Is(err, notifier.ErrInvalidAck) {
+			reply(replies, wsFrame{Type: "error", Error: err.Error()})
+			continue
+		}
+		if errors.Is(err, notifier.ErrNotificationNotFound) {
+			reply(replies, wsFrame{Type: "error", Error: err.Error()})
+			continue
+		}
+		if err != nil {
+			reply(replies, wsFrame{Type: "error", Error: "Internal server error"})
+			continue
+		}
+		if ack.Status == notifier.AckRead {
+			if err := wc.inbox.MarkReadByNotification(ctx, userID, ack.NotificationID); err != nil {
+				reply(replies, wsFrame{Type: "error", Error: "Internal server error"})
+			}
+		}
+	}
+}
+
+// reply drops the frame if the writer is backed up
+func reply(replies chan<- wsFrame, frame wsFrame) {
+	select {
+	case replies <- frame:
+	default:
+	}
+}
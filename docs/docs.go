@@ -15,35 +15,65 @@ const docTemplate = `{
     "host": "{{.Host}}",
     "basePath": "{{.BasePath}}",
     "paths": {
-        "/login": {
-            "post": {
-                "description": "Authenticate a user and return a JWT token",
-                "consumes": [
-                    "application/json"
+        "/admin/dead-letters": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
                 ],
+                "description": "List deliveries that won't be sent again on their own: DEAD_LETTER rows that used all their attempts and FAILED rows that hit a permanent error. Admin only.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "admin"
                 ],
-                "summary": "Login",
+                "summary": "List dead letters",
                 "parameters": [
                     {
-                        "description": "Credentials",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/user.LoginRequest"
-                        }
+                        "type": "string",
+                        "description": "Channel name",
+                        "name": "channel",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Text the last error contains",
+                        "name": "error",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "DEAD_LETTER",
+                            "FAILED"
+                        ],
+                        "type": "string",
+                        "description": "Only this status",
+                        "name": "status",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 200)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Rows to skip",
+                        "name": "offset",
+                        "in": "query"
                     }
                 ],
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.TokenResponse"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Outbox"
+                            }
                         }
                     },
                     "400": {
@@ -58,6 +88,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -67,29 +103,46 @@ const docTemplate = `{
                 }
             }
         },
-        "/notifications": {
-            "get": {
+        "/admin/dead-letters/discard": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "List user notifications",
+                "description": "Delete every dead letter matching the filter with its error history. An empty filter is rejected unless \"all\" is true, which discards all of them. Admin only.",
+                "consumes": [
+                    "application/json"
+                ],
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "notifications"
+                    "admin"
+                ],
+                "summary": "Discard dead letters",
+                "parameters": [
+                    {
+                        "description": "Filter",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.DeadLetterFilterDTO"
+                        }
+                    }
                 ],
-                "summary": "List notifications",
                 "responses": {
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "array",
-                            "items": {
-                                "$ref": "#/definitions/models.NotificationResponse"
-                            }
+                            "$ref": "#/definitions/models.DeadLetterBatchResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
                     "401": {
@@ -98,6 +151,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -105,14 +164,16 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
+            }
+        },
+        "/admin/dead-letters/replay": {
             "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Create and enqueue a notification. Supports multiple channels: email, sms, and push.\n\n**Email Channel** - See channels.ValidEmailMeta for required meta fields\n**SMS Channel** - See channels.ValidSMSMeta for required meta fields\n**Push Channel** - See channels.ValidPushMeta for required meta fields\n\n**scheduled_at**: Optional. Use RFC3339 format (e.g., \"2025-10-27T10:00:00Z\"). If not provided, the notification will be sent immediately.\n\n**Example:** {\"title\":\"Welcome\",\"content\":\"Welcome message\",\"channel_name\":\"email\",\"meta\":{\"to\":\"user@example.com\",\"subject\":\"Welcome!\"},\"scheduled_at\":\"2025-10-27T10:00:00Z\"}",
+                "description": "Return every dead letter matching the filter to PENDING. An empty filter replays all of them. Admin only.",
                 "consumes": [
                     "application/json"
                 ],
@@ -120,25 +181,25 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "notifications"
+                    "admin"
                 ],
-                "summary": "Create notification",
+                "summary": "Replay dead letters",
                 "parameters": [
                     {
-                        "description": "Notification data",
+                        "description": "Filter",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/controllers.CreateNotificationDTO"
+                            "$ref": "#/definitions/controllers.DeadLetterFilterDTO"
                         }
                     }
                 ],
                 "responses": {
-                    "202": {
-                        "description": "Accepted",
+                    "200": {
+                        "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.MessageResponse"
+                            "$ref": "#/definitions/models.DeadLetterBatchResponse"
                         }
                     },
                     "400": {
@@ -153,54 +214,40 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "500": {
-                        "description": "Internal Server Error",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
-                    }
-                }
-            }
-        },
-        "/notifications/channels/schemas": {
-            "get": {
-                "description": "Get the required meta field schemas for each notification channel",
-                "produces": [
-                    "application/json"
-                ],
-                "tags": [
-                    "notifications"
-                ],
-                "summary": "Get channel schemas",
-                "responses": {
-                    "200": {
-                        "description": "OK",
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
                         "schema": {
-                            "$ref": "#/definitions/models.ChannelSchemasResponse"
+                            "$ref": "#/definitions/models.ErrorResponse"
                         }
                     }
                 }
             }
         },
-        "/notifications/{id}": {
+        "/admin/dead-letters/{id}": {
             "get": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Get a notification by ID",
+                "description": "Get a dead letter with the error of every failed attempt. Admin only.",
                 "produces": [
                     "application/json"
                 ],
                 "tags": [
-                    "notifications"
+                    "admin"
                 ],
-                "summary": "Get notification",
+                "summary": "Get dead letter",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Notification ID",
+                        "description": "Outbox ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -210,7 +257,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "$ref": "#/definitions/models.NotificationResponse"
+                            "$ref": "#/definitions/deadletters.DeadLetter"
                         }
                     },
                     "401": {
@@ -219,11 +266,23 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
                     }
                 }
             },
@@ -233,15 +292,15 @@ const docTemplate = `{
                         "BearerAuth": []
                     }
                 ],
-                "description": "Mark a notification as deleted",
+                "description": "Delete a dead letter and its error history; the notification is kept. Admin only.",
                 "tags": [
-                    "notifications"
+                    "admin"
                 ],
-                "summary": "Delete notification",
+                "summary": "Discard dead letter",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Notification ID",
+                        "description": "Outbox ID",
                         "name": "id",
                         "in": "path",
                         "required": true
@@ -257,6 +316,12 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
                     "404": {
                         "description": "Not Found",
                         "schema": {
@@ -270,54 +335,41 @@ const docTemplate = `{
                         }
                     }
                 }
-            },
-            "patch": {
+            }
+        },
+        "/admin/dead-letters/{id}/replay": {
+            "post": {
                 "security": [
                     {
                         "BearerAuth": []
                     }
                 ],
-                "description": "Update allowed fields of a notification (title, content, meta, scheduled_at). Only PENDING notifications can be reprogrammed.\n\n**scheduled_at**: Optional. Use RFC3339 format (e.g., \"2025-10-27T15:00:00Z\") to reschedule PENDING notifications.",
-                "consumes": [
-                    "application/json"
-                ],
-                "produces": [
-                    "application/json"
-                ],
+                "description": "Return a dead letter to PENDING so it is sent again with a fresh set of attempts. Its error history is kept. Admin only.",
                 "tags": [
-                    "notifications"
+                    "admin"
                 ],
-                "summary": "Update notification",
+                "summary": "Replay dead letter",
                 "parameters": [
                     {
                         "type": "integer",
-                        "description": "Notification ID",
+                        "description": "Outbox ID",
                         "name": "id",
                         "in": "path",
                         "required": true
-                    },
-                    {
-                        "description": "Partial update",
-                        "name": "data",
-                        "in": "body",
-                        "required": true,
-                        "schema": {
-                            "$ref": "#/definitions/controllers.UpdateNotificationDTO"
-                        }
                     }
                 ],
                 "responses": {
                     "204": {
                         "description": "No Content"
                     },
-                    "400": {
-                        "description": "Bad Request",
+                    "401": {
+                        "description": "Unauthorized",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
-                    "401": {
-                        "description": "Unauthorized",
+                    "403": {
+                        "description": "Forbidden",
                         "schema": {
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
@@ -337,9 +389,52 @@ const docTemplate = `{
                 }
             }
         },
-        "/signup": {
+        "/devices": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the devices of the current user, including tokens the push provider reported invalid",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "devices"
+                ],
+                "summary": "List devices",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Device"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
             "post": {
-                "description": "Register a new user",
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Register a push token for the current user. Push notifications with meta.user_id are sent to every active device of that user.\nRegistering a token again updates it and makes it active if the provider had reported it invalid. A token registered to another user can only be taken over once the provider reported it invalid.",
                 "consumes": [
                     "application/json"
                 ],
@@ -347,17 +442,17 @@ const docTemplate = `{
                     "application/json"
                 ],
                 "tags": [
-                    "auth"
+                    "devices"
                 ],
-                "summary": "Create user",
+                "summary": "Register device",
                 "parameters": [
                     {
-                        "description": "Registration data",
+                        "description": "Device",
                         "name": "data",
                         "in": "body",
                         "required": true,
                         "schema": {
-                            "$ref": "#/definitions/user.SignupRequest"
+                            "$ref": "#/definitions/controllers.RegisterDeviceDTO"
                         }
                     }
                 ],
@@ -365,7 +460,7 @@ const docTemplate = `{
                     "201": {
                         "description": "Created",
                         "schema": {
-                            "$ref": "#/definitions/models.MessageResponse"
+                            "$ref": "#/definitions/models.Device"
                         }
                     },
                     "400": {
@@ -374,6 +469,18 @@ const docTemplate = `{
                             "$ref": "#/definitions/models.ErrorResponse"
                         }
                     },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -382,114 +489,2135 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "channels.ValidEmailMeta": {
-            "type": "object",
-            "properties": {
-                "subject": {
-                    "type": "string",
-                    "example": "Welcome to our platform"
-                },
-                "template": {
-                    "type": "string",
-                    "example": "titled"
-                },
-                "to": {
-                    "type": "string",
-                    "example": "user@example.com"
-                }
-            }
         },
-        "channels.ValidPushMeta": {
-            "type": "object",
-            "properties": {
-                "data": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "string"
-                    }
-                },
-                "options": {
-                    "type": "object",
-                    "additionalProperties": {
-                        "type": "string"
+        "/devices/{id}": {
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
                     }
-                },
-                "platform": {
-                    "type": "string",
-                    "example": "android"
-                },
+                ],
+                "description": "Unregister a device of the current user",
+                "tags": [
+                    "devices"
+                ],
+                "summary": "Delete device",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Device ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/inbox": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the in-app messages of the current user, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "inbox"
+                ],
+                "summary": "List inbox",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Only unread messages",
+                        "name": "unread",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Page size (default 50, max 100)",
+                        "name": "limit",
+                        "in": "query"
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Messages to skip",
+                        "name": "offset",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.InboxMessage"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/inbox/read-all": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Mark every in-app message of the current user as read",
+                "tags": [
+                    "inbox"
+                ],
+                "summary": "Mark all read",
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/inbox/unread-count": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Count the unread in-app messages of the current user",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "inbox"
+                ],
+                "summary": "Unread count",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.UnreadCountResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/inbox/{id}/read": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Mark an in-app message of the current user as read",
+                "tags": [
+                    "inbox"
+                ],
+                "summary": "Mark message read",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Inbox message ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/login": {
+            "post": {
+                "description": "Authenticate a user and return a JWT token",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Login",
+                "parameters": [
+                    {
+                        "description": "Credentials",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.LoginRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.TokenResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List user notifications",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "List notifications",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Only notifications of this category",
+                        "name": "category",
+                        "in": "query"
+                    },
+                    {
+                        "enum": [
+                            "low",
+                            "normal",
+                            "high"
+                        ],
+                        "type": "string",
+                        "description": "Only notifications of this priority",
+                        "name": "priority",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.NotificationResponse"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create and enqueue a notification. Supports multiple channels: email, sms, push, webhook, chat and inapp.\n\n**Email Channel** - See channels.ValidEmailMeta for required meta fields\n**SMS Channel** - See channels.ValidSMSMeta for required meta fields\n**Push Channel** - See channels.ValidPushMeta for required meta fields\n**Webhook Channel** - See channels.ValidWebhookMeta for required meta fields\n**Chat Channel** - See channels.ValidChatMeta for required meta fields\n**In-app Channel** - See channels.ValidInAppMeta for optional meta fields\n\n**recipient_user_id**: Optional. Sends to a user instead of an address: email, SMS and push take the address (to, phone, devices) missing from meta from the user's contact profile, and in-app delivers to that user.\n\n**category**: Optional. The kind of notification, one of GET /preferences/categories such as security, transactional or marketing; defaults to general. Notifications the recipient opted out of through their preferences for the channel and category are recorded as SKIPPED instead of sent. Mandatory categories (security, transactional) are always sent.\n\n**priority**: Optional. low, normal or high; defaults to the priority of the category. Higher priorities are dispatched first, and push notifications use it when meta.options has no priority.\n\n**scheduled_at**: Optional. Use RFC3339 format (e.g., \"2025-10-27T10:00:00Z\"). If not provided, the notification will be sent immediately.\n\n**variables**: Optional. Values substituted into title, content and meta.subject through Go template placeholders such as .name or .order_id. With **strict_variables** the request is rejected when a referenced variable is missing; otherwise missing variables render empty.\n\n**Example:** {\"title\":\"Welcome\",\"content\":\"Welcome message\",\"channel_name\":\"email\",\"meta\":{\"to\":\"user@example.com\",\"subject\":\"Welcome!\"},\"scheduled_at\":\"2025-10-27T10:00:00Z\"}",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Create notification",
+                "parameters": [
+                    {
+                        "description": "Notification data",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.CreateNotificationDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "202": {
+                        "description": "Accepted",
+                        "schema": {
+                            "$ref": "#/definitions/models.MessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/channels/schemas": {
+            "get": {
+                "description": "Get the required meta field schemas for each notification channel",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Get channel schemas",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ChannelSchemasResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/preview": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Render a notification exactly as the worker would send it, without creating it.\nThe body is the same as for creating a notification; scheduled_at is ignored.\n\nThe response holds the rendered subject and text, the HTML body for email, the provider payload for push and the segment count for SMS.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Preview notification",
+                "parameters": [
+                    {
+                        "description": "Notification data",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.CreateNotificationDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/channel.Preview"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "422": {
+                        "description": "Unprocessable Entity",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/notifications/{id}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get a notification by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Get notification",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Notification ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.NotificationResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Mark a notification as deleted",
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Delete notification",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Notification ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "patch": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Update allowed fields of a notification (title, content, meta, scheduled_at). Only PENDING notifications can be reprogrammed.\n\n**scheduled_at**: Optional. Use RFC3339 format (e.g., \"2025-10-27T15:00:00Z\") to reschedule PENDING notifications.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "notifications"
+                ],
+                "summary": "Update notification",
+                "parameters": [
+                    {
+                        "type": "integer",
+                        "description": "Notification ID",
+                        "name": "id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Partial update",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UpdateNotificationDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/preferences": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the notification preferences of the current user. Without a matching preference, a notification is sent if its channel is one of the default channels of its category.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "preferences"
+                ],
+                "summary": "List preferences",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Preference"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Opt in or out of notifications per channel and category, e.g. {\"channel_name\":\"sms\",\"category\":\"marketing\",\"enabled\":false}. Use * as the channel or category to match all of them.\nThe most specific preference applies: channel and category, then the channel, then the category, then */*. Preferences not listed are kept. Notifications the user opted out of are recorded as SKIPPED.\nMandatory categories can't be turned off, and categories sent only on some channels by default need a preference naming the category to be received on others.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "preferences"
+                ],
+                "summary": "Update preferences",
+                "parameters": [
+                    {
+                        "description": "Preferences",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UpdatePreferencesDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Preference"
+                            }
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/preferences/categories": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the notification categories with whether users may opt out of them, the channels they are sent on by default and their default priority",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "preferences"
+                ],
+                "summary": "List categories",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Category"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/profile": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the contact profile of the current user: the email, phone and devices notifications sent with recipient_user_id are delivered to, plus locale and timezone.\nThe email defaults to the account email.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profile"
+                ],
+                "summary": "Get contact profile",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ContactProfile"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace the contact details of the current user. An empty email falls back to the account email; the phone is normalized to E.164.\nA new email or phone is sent a verification code and isn't used for notifications until verified with POST /profile/verify.\nDevices are managed with the /devices endpoints.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profile"
+                ],
+                "summary": "Update contact profile",
+                "parameters": [
+                    {
+                        "description": "Contact details",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UpdateProfileDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ContactProfile"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/profile/verify": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Verify the profile email or phone with the code sent to it when it was saved. Codes expire after 15 minutes and are invalidated after 5 wrong attempts.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "profile"
+                ],
+                "summary": "Verify a profile address",
+                "parameters": [
+                    {
+                        "description": "Verification code",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.VerifyProfileDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ContactProfile"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/signup": {
+            "post": {
+                "description": "Register a new user",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "auth"
+                ],
+                "summary": "Create user",
+                "parameters": [
+                    {
+                        "description": "Registration data",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/user.SignupRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.MessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/stream": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Server-Sent Events stream of the current user's inbox messages and sent notifications.\n\n**inbox** events carry the new models.InboxMessage and its ID as the event id. Reconnecting clients send it back in the Last-Event-ID header (browsers do this automatically) or the last_event_id query parameter, and every message stored since is replayed first.\n**notification** events carry the notification_id, channel_name and status of a sent notification. They have no id and are not replayed.\n\nBrowser EventSource clients can't set headers, so the JWT may be passed in the access_token query parameter instead.",
+                "produces": [
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "stream"
+                ],
+                "summary": "Event stream",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Last inbox event id received",
+                        "name": "Last-Event-ID",
+                        "in": "header"
+                    },
+                    {
+                        "type": "string",
+                        "description": "Last inbox event id received",
+                        "name": "last_event_id",
+                        "in": "query"
+                    },
+                    {
+                        "type": "string",
+                        "description": "JWT, when the Authorization header can't be set",
+                        "name": "access_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "text/event-stream",
+                        "schema": {
+                            "type": "string"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/templates": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List the latest version of every template",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "templates"
+                ],
+                "summary": "List templates",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Template"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create version 1 of a template with one body per channel. Bodies use Go template syntax and can reference the notification fields .Title, .Content and .Meta.\nEmail bodies require both text and html; other channels only use text, and push uses subject as the title.\nAdmin only.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "templates"
+                ],
+                "summary": "Create template",
+                "parameters": [
+                    {
+                        "description": "Template data",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.CreateTemplateDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "201": {
+                        "description": "Created",
+                        "schema": {
+                            "$ref": "#/definitions/models.Template"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/templates/{name}": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the latest version of a template, or a specific one with ?version=",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "templates"
+                ],
+                "summary": "Get template",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Template name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "type": "integer",
+                        "description": "Template version",
+                        "name": "version",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Template"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "put": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Create a new version of a template. Admin only. Notifications created afterwards use the new version; notifications already created keep the version they were created with.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "templates"
+                ],
+                "summary": "Update template",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Template name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Template bodies",
+                        "name": "data",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/controllers.UpdateTemplateDTO"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.Template"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Delete all versions of a template. Admin only.",
+                "tags": [
+                    "templates"
+                ],
+                "summary": "Delete template",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Template name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "204": {
+                        "description": "No Content"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "403": {
+                        "description": "Forbidden",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/templates/{name}/versions": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "List every version of a template, newest first",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "templates"
+                ],
+                "summary": "List template versions",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Template name",
+                        "name": "name",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/models.Template"
+                            }
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/secret": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Get the secret your webhook notifications are signed with, generating it on first use.\n\nEach webhook request carries an X-Webhook-Timestamp header and an X-Webhook-Signature header of the form sha256=HEX, the HMAC-SHA256 of the timestamp, a dot and the raw request body.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Get webhook secret",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookSecret"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/webhooks/secret/rotate": {
+            "post": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Replace the webhook signing secret. Webhooks sent afterwards are signed with the new secret.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "webhooks"
+                ],
+                "summary": "Rotate webhook secret",
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.WebhookSecret"
+                        }
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        },
+        "/ws": {
+            "get": {
+                "security": [
+                    {
+                        "BearerAuth": []
+                    }
+                ],
+                "description": "Upgrade to a WebSocket that receives the same inbox and notification events as /stream, as JSON frames of the form type, id, data.\nEvery open connection of a user receives every event. A connection that doesn't keep up with its events is closed.\n\nClients acknowledge notifications by sending a frame with type ack, notification_id and status, either delivered or read. Acks set delivered_at and read_at on the notification, and a read ack also marks the inbox message as read.\n\nBrowsers can't set headers on WebSocket requests, so the JWT may be passed in the access_token query parameter instead.",
+                "tags": [
+                    "stream"
+                ],
+                "summary": "WebSocket gateway",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "JWT, when the Authorization header can't be set",
+                        "name": "access_token",
+                        "in": "query"
+                    }
+                ],
+                "responses": {
+                    "101": {
+                        "description": "Switching Protocols"
+                    },
+                    "401": {
+                        "description": "Unauthorized",
+                        "schema": {
+                            "$ref": "#/definitions/models.ErrorResponse"
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "channel.Preview": {
+            "type": "object",
+            "properties": {
+                "encoding": {
+                    "type": "string",
+                    "example": "GSM-7"
+                },
+                "html": {
+                    "type": "string",
+                    "example": "\u003cp\u003eWelcome to our platform!\u003c/p\u003e"
+                },
+                "payload": {
+                    "description": "Payload is the provider request body, for channels that send JSON",
+                    "type": "object"
+                },
+                "segments": {
+                    "description": "Segments is the number of SMS parts the text is split into, and Encoding\nthe SMS encoding used (GSM-7 or UCS-2)",
+                    "type": "integer",
+                    "example": 1
+                },
+                "subject": {
+                    "type": "string",
+                    "example": "Welcome!"
+                },
+                "text": {
+                    "type": "string",
+                    "example": "Welcome to our platform!"
+                }
+            }
+        },
+        "channels.EmailAttachment": {
+            "type": "object",
+            "properties": {
+                "blob": {
+                    "type": "string",
+                    "example": "invoices/2025/10/123.pdf"
+                },
+                "content": {
+                    "type": "string",
+                    "example": "JVBERi0xLjQK"
+                },
+                "content_id": {
+                    "type": "string",
+                    "example": "logo"
+                },
+                "content_type": {
+                    "type": "string",
+                    "example": "application/pdf"
+                },
+                "filename": {
+                    "type": "string",
+                    "example": "invoice.pdf"
+                }
+            }
+        },
+        "channels.ValidChatMeta": {
+            "type": "object",
+            "properties": {
+                "context": {
+                    "type": "string",
+                    "example": "api-prod | us-east-1"
+                },
+                "webhook_url": {
+                    "type": "string",
+                    "example": "https://hooks.slack.com/services/T000/B000/XXXX"
+                }
+            }
+        },
+        "channels.ValidEmailMeta": {
+            "type": "object",
+            "properties": {
+                "attachments": {
+                    "description": "Attachments is sent as a JSON array in meta.attachments",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/channels.EmailAttachment"
+                    }
+                },
+                "subject": {
+                    "type": "string",
+                    "example": "Welcome to our platform"
+                },
+                "template": {
+                    "type": "string",
+                    "example": "titled"
+                },
+                "to": {
+                    "type": "string",
+                    "example": "user@example.com"
+                }
+            }
+        },
+        "channels.ValidInAppMeta": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string",
+                    "example": "{\"post_id\":\"7\"}"
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "123"
+                }
+            }
+        },
+        "channels.ValidPushMeta": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "options": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "platform": {
+                    "type": "string",
+                    "enum": [
+                        "android",
+                        "ios",
+                        "web"
+                    ],
+                    "example": "android"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "device_token_xyz123"
+                },
+                "user_id": {
+                    "type": "string",
+                    "example": "123"
+                }
+            }
+        },
+        "channels.ValidSMSMeta": {
+            "type": "object",
+            "properties": {
+                "carrier": {
+                    "type": "string",
+                    "example": "verizon"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "+14155552671"
+                },
+                "region": {
+                    "description": "Region overrides the default region for a phone number in national format",
+                    "type": "string",
+                    "example": "US"
+                }
+            }
+        },
+        "channels.ValidWebhookMeta": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "url": {
+                    "type": "string",
+                    "example": "https://example.com/hooks/notifications"
+                }
+            }
+        },
+        "controllers.CreateNotificationDTO": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string"
+                },
+                "channel_name": {
+                    "type": "string"
+                },
+                "content": {
+                    "type": "string"
+                },
+                "meta": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "priority": {
+                    "type": "string"
+                },
+                "recipient_user_id": {
+                    "type": "integer"
+                },
+                "scheduled_at": {
+                    "type": "string"
+                },
+                "strict_variables": {
+                    "type": "boolean"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "variables": {
+                    "type": "object",
+                    "additionalProperties": {}
+                }
+            }
+        },
+        "controllers.CreateTemplateDTO": {
+            "type": "object",
+            "properties": {
+                "bodies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controllers.TemplateBodyDTO"
+                    }
+                },
+                "name": {
+                    "type": "string",
+                    "example": "welcome"
+                }
+            }
+        },
+        "controllers.DeadLetterFilterDTO": {
+            "type": "object",
+            "properties": {
+                "all": {
+                    "description": "All must be set to discard with an empty filter",
+                    "type": "boolean",
+                    "example": false
+                },
+                "channel_name": {
+                    "type": "string",
+                    "example": "sms"
+                },
+                "error": {
+                    "description": "Error matches dead letters whose last error contains it",
+                    "type": "string",
+                    "example": "status 503"
+                },
+                "status": {
+                    "type": "string",
+                    "enum": [
+                        "DEAD_LETTER",
+                        "FAILED"
+                    ],
+                    "example": "DEAD_LETTER"
+                }
+            }
+        },
+        "controllers.PreferenceDTO": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string",
+                    "example": "marketing"
+                },
+                "channel_name": {
+                    "type": "string",
+                    "example": "sms"
+                },
+                "enabled": {
+                    "type": "boolean",
+                    "example": false
+                }
+            }
+        },
+        "controllers.RegisterDeviceDTO": {
+            "type": "object",
+            "properties": {
+                "app_version": {
+                    "type": "string",
+                    "example": "2.4.1"
+                },
+                "platform": {
+                    "type": "string",
+                    "enum": [
+                        "android",
+                        "ios",
+                        "web"
+                    ],
+                    "example": "android"
+                },
                 "token": {
                     "type": "string",
                     "example": "device_token_xyz123"
                 }
             }
         },
-        "channels.ValidSMSMeta": {
+        "controllers.TemplateBodyDTO": {
+            "type": "object",
+            "properties": {
+                "channel_name": {
+                    "type": "string",
+                    "example": "email"
+                },
+                "html": {
+                    "type": "string",
+                    "example": "\u003cp\u003eThanks for signing up.\u003c/p\u003e"
+                },
+                "subject": {
+                    "type": "string",
+                    "example": "Welcome aboard"
+                },
+                "text": {
+                    "type": "string",
+                    "example": "Thanks for signing up."
+                }
+            }
+        },
+        "controllers.UpdateNotificationDTO": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "meta": {
+                    "type": "object",
+                    "additionalProperties": {}
+                },
+                "scheduled_at": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string"
+                }
+            }
+        },
+        "controllers.UpdatePreferencesDTO": {
+            "type": "object",
+            "properties": {
+                "preferences": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controllers.PreferenceDTO"
+                    }
+                }
+            }
+        },
+        "controllers.UpdateProfileDTO": {
+            "type": "object",
+            "properties": {
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "locale": {
+                    "type": "string",
+                    "example": "en-US"
+                },
+                "phone": {
+                    "type": "string",
+                    "example": "(415) 555-2671"
+                },
+                "region": {
+                    "description": "Region is the ISO 3166-1 code a phone number in national format is read in",
+                    "type": "string",
+                    "example": "US"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "America/New_York"
+                }
+            }
+        },
+        "controllers.UpdateTemplateDTO": {
+            "type": "object",
+            "properties": {
+                "bodies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/controllers.TemplateBodyDTO"
+                    }
+                }
+            }
+        },
+        "controllers.VerifyProfileDTO": {
+            "type": "object",
+            "required": [
+                "code",
+                "field"
+            ],
+            "properties": {
+                "code": {
+                    "type": "string",
+                    "example": "123456"
+                },
+                "field": {
+                    "description": "Field is the address the code was sent to: email or phone",
+                    "type": "string",
+                    "example": "email"
+                }
+            }
+        },
+        "deadletters.DeadLetter": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "channel_name": {
+                    "type": "string",
+                    "example": "sms"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "errors": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.DeliveryError"
+                    }
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "last_error": {
+                    "type": "string",
+                    "example": "twilio: status 503: service unavailable"
+                },
+                "lease_expires_at": {
+                    "type": "string"
+                },
+                "lease_owner": {
+                    "description": "LeaseOwner is the worker that claimed a PROCESSING row. It keeps the row\nuntil LeaseExpiresAt, after which the row can be taken back",
+                    "type": "string"
+                },
+                "max_attempts": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "notification_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "payload_json": {
+                    "type": "string"
+                },
+                "priority": {
+                    "description": "Priority is the rank of the notification priority; higher ranks are claimed first",
+                    "type": "integer",
+                    "example": 1
+                },
+                "provider": {
+                    "description": "Provider and ProviderMessageID identify the message at the provider once sent",
+                    "type": "string"
+                },
+                "provider_message_id": {
+                    "type": "string"
+                },
+                "reschedules": {
+                    "description": "Reschedules counts the rate limits since the last attempt, which don't\nuse an attempt until there are too many in a row",
+                    "type": "integer",
+                    "example": 0
+                },
+                "scheduled_at": {
+                    "type": "string"
+                },
+                "segments": {
+                    "description": "Segments is the number of SMS parts sent",
+                    "type": "integer"
+                },
+                "skip_reason": {
+                    "description": "SkipReason explains why a SKIPPED delivery wasn't sent",
+                    "type": "string"
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Status"
+                        }
+                    ],
+                    "example": "DEAD_LETTER"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Category": {
+            "type": "object",
+            "properties": {
+                "default_channels": {
+                    "description": "DefaultChannels are the channels users receive the category on until they\nset a preference for it. Empty means every channel",
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    },
+                    "example": [
+                        "email",
+                        "inapp"
+                    ]
+                },
+                "default_priority": {
+                    "description": "DefaultPriority applies to notifications created without a priority",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Priority"
+                        }
+                    ],
+                    "example": "low"
+                },
+                "description": {
+                    "type": "string",
+                    "example": "Promotions and newsletters"
+                },
+                "mandatory": {
+                    "description": "Mandatory categories are always delivered; users can't opt out of them",
+                    "type": "boolean",
+                    "example": false
+                },
+                "name": {
+                    "type": "string",
+                    "example": "marketing"
+                }
+            }
+        },
+        "models.ChannelSchemasResponse": {
+            "type": "object",
+            "properties": {
+                "chat": {
+                    "$ref": "#/definitions/channels.ValidChatMeta"
+                },
+                "email": {
+                    "$ref": "#/definitions/channels.ValidEmailMeta"
+                },
+                "inapp": {
+                    "$ref": "#/definitions/channels.ValidInAppMeta"
+                },
+                "push": {
+                    "$ref": "#/definitions/channels.ValidPushMeta"
+                },
+                "sms": {
+                    "$ref": "#/definitions/channels.ValidSMSMeta"
+                },
+                "webhook": {
+                    "$ref": "#/definitions/channels.ValidWebhookMeta"
+                }
+            }
+        },
+        "models.ContactProfile": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
+                },
+                "devices": {
+                    "description": "Devices are the user's registered push devices",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Device"
+                    }
+                },
+                "email": {
+                    "type": "string",
+                    "example": "user@example.com"
+                },
+                "email_verified": {
+                    "description": "EmailVerified is set once the user enters the code sent to Email. The\naccount email counts as verified",
+                    "type": "boolean"
+                },
+                "locale": {
+                    "type": "string",
+                    "example": "en-US"
+                },
+                "phone": {
+                    "description": "Phone is stored in E.164 format",
+                    "type": "string",
+                    "example": "+14155552671"
+                },
+                "phone_verified": {
+                    "type": "boolean"
+                },
+                "timezone": {
+                    "type": "string",
+                    "example": "America/New_York"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 123
+                }
+            }
+        },
+        "models.DeadLetterBatchResponse": {
             "type": "object",
             "properties": {
-                "carrier": {
-                    "type": "string",
-                    "example": "verizon"
-                },
-                "phone": {
-                    "type": "string",
-                    "example": "+1234567890"
+                "count": {
+                    "type": "integer",
+                    "example": 12
                 }
             }
         },
-        "controllers.CreateNotificationDTO": {
+        "models.DeliveryError": {
             "type": "object",
             "properties": {
-                "channel_name": {
-                    "type": "string"
+                "attempt": {
+                    "description": "Attempt is the number of the attempt that failed, counted from 1 since the last replay",
+                    "type": "integer",
+                    "example": 3
                 },
-                "content": {
-                    "type": "string"
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
                 },
-                "meta": {
-                    "type": "object",
-                    "additionalProperties": {}
+                "error": {
+                    "type": "string",
+                    "example": "twilio: status 503: service unavailable"
                 },
-                "scheduled_at": {
-                    "type": "string"
+                "id": {
+                    "type": "integer",
+                    "example": 1
                 },
-                "title": {
-                    "type": "string"
+                "outbox_id": {
+                    "type": "integer",
+                    "example": 12
                 }
             }
         },
-        "controllers.UpdateNotificationDTO": {
+        "models.Device": {
             "type": "object",
             "properties": {
-                "content": {
-                    "type": "string"
+                "app_version": {
+                    "type": "string",
+                    "example": "2.4.1"
                 },
-                "meta": {
-                    "type": "object",
-                    "additionalProperties": {}
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
                 },
-                "scheduled_at": {
-                    "type": "string"
+                "id": {
+                    "type": "integer",
+                    "example": 1
                 },
-                "title": {
-                    "type": "string"
-                }
-            }
-        },
-        "models.ChannelSchemasResponse": {
-            "type": "object",
-            "properties": {
-                "email": {
-                    "$ref": "#/definitions/channels.ValidEmailMeta"
+                "invalid_reason": {
+                    "type": "string",
+                    "example": "UNREGISTERED"
                 },
-                "push": {
-                    "$ref": "#/definitions/channels.ValidPushMeta"
+                "invalidated_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
                 },
-                "sms": {
-                    "$ref": "#/definitions/channels.ValidSMSMeta"
+                "platform": {
+                    "type": "string",
+                    "example": "android"
+                },
+                "token": {
+                    "type": "string",
+                    "example": "device_token_xyz123"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 123
                 }
             }
         },
@@ -502,6 +2630,43 @@ const docTemplate = `{
                 }
             }
         },
+        "models.InboxMessage": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string",
+                    "example": "Ana replied to your post"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
+                },
+                "data": {
+                    "type": "string",
+                    "example": "{\"post_id\":\"7\"}"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "notification_id": {
+                    "description": "NotificationID is unique so a retried delivery doesn't duplicate the message",
+                    "type": "integer",
+                    "example": 42
+                },
+                "read_at": {
+                    "type": "string"
+                },
+                "title": {
+                    "type": "string",
+                    "example": "New comment"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 123
+                }
+            }
+        },
         "models.MessageResponse": {
             "type": "object",
             "properties": {
@@ -514,6 +2679,10 @@ const docTemplate = `{
         "models.NotificationResponse": {
             "type": "object",
             "properties": {
+                "category": {
+                    "type": "string",
+                    "example": "marketing"
+                },
                 "channel_name": {
                     "type": "string",
                     "example": "email"
@@ -526,6 +2695,10 @@ const docTemplate = `{
                     "type": "string",
                     "example": "2025-10-26T12:00:00Z"
                 },
+                "delivered_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:05Z"
+                },
                 "id": {
                     "type": "integer",
                     "example": 1
@@ -534,6 +2707,27 @@ const docTemplate = `{
                     "type": "string",
                     "example": "a1b2c3d4e5f6"
                 },
+                "priority": {
+                    "type": "string",
+                    "enum": [
+                        "low",
+                        "normal",
+                        "high"
+                    ],
+                    "example": "normal"
+                },
+                "read_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:03:00Z"
+                },
+                "recipient_user_id": {
+                    "type": "integer",
+                    "example": 42
+                },
+                "template_version": {
+                    "type": "integer",
+                    "example": 3
+                },
                 "title": {
                     "type": "string",
                     "example": "Welcome email"
@@ -548,6 +2742,199 @@ const docTemplate = `{
                 }
             }
         },
+        "models.Outbox": {
+            "type": "object",
+            "properties": {
+                "attempts": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "channel_name": {
+                    "type": "string",
+                    "example": "sms"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 12
+                },
+                "last_error": {
+                    "type": "string",
+                    "example": "twilio: status 503: service unavailable"
+                },
+                "lease_expires_at": {
+                    "type": "string"
+                },
+                "lease_owner": {
+                    "description": "LeaseOwner is the worker that claimed a PROCESSING row. It keeps the row\nuntil LeaseExpiresAt, after which the row can be taken back",
+                    "type": "string"
+                },
+                "max_attempts": {
+                    "type": "integer",
+                    "example": 5
+                },
+                "next_attempt_at": {
+                    "type": "string"
+                },
+                "notification_id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "payload_json": {
+                    "type": "string"
+                },
+                "priority": {
+                    "description": "Priority is the rank of the notification priority; higher ranks are claimed first",
+                    "type": "integer",
+                    "example": 1
+                },
+                "provider": {
+                    "description": "Provider and ProviderMessageID identify the message at the provider once sent",
+                    "type": "string"
+                },
+                "provider_message_id": {
+                    "type": "string"
+                },
+                "reschedules": {
+                    "description": "Reschedules counts the rate limits since the last attempt, which don't\nuse an attempt until there are too many in a row",
+                    "type": "integer",
+                    "example": 0
+                },
+                "scheduled_at": {
+                    "type": "string"
+                },
+                "segments": {
+                    "description": "Segments is the number of SMS parts sent",
+                    "type": "integer"
+                },
+                "skip_reason": {
+                    "description": "SkipReason explains why a SKIPPED delivery wasn't sent",
+                    "type": "string"
+                },
+                "status": {
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/models.Status"
+                        }
+                    ],
+                    "example": "DEAD_LETTER"
+                },
+                "updated_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "models.Preference": {
+            "type": "object",
+            "properties": {
+                "category": {
+                    "type": "string",
+                    "example": "marketing"
+                },
+                "channel_name": {
+                    "type": "string",
+                    "example": "sms"
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
+                },
+                "enabled": {
+                    "type": "boolean",
+                    "example": false
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
+                }
+            }
+        },
+        "models.Priority": {
+            "type": "string",
+            "enum": [
+                "low",
+                "normal",
+                "high"
+            ],
+            "x-enum-varnames": [
+                "PriorityLow",
+                "PriorityNormal",
+                "PriorityHigh"
+            ]
+        },
+        "models.Status": {
+            "type": "string",
+            "enum": [
+                "PENDING",
+                "PROCESSING",
+                "SENT",
+                "FAILED",
+                "SKIPPED",
+                "DEAD_LETTER"
+            ],
+            "x-enum-varnames": [
+                "PENDING",
+                "PROCESSING",
+                "SENT",
+                "FAILED",
+                "SKIPPED",
+                "DEAD_LETTER"
+            ]
+        },
+        "models.Template": {
+            "type": "object",
+            "properties": {
+                "bodies": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.TemplateBody"
+                    }
+                },
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
+                },
+                "id": {
+                    "type": "integer",
+                    "example": 1
+                },
+                "name": {
+                    "type": "string",
+                    "example": "welcome"
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
+                },
+                "version": {
+                    "type": "integer",
+                    "example": 1
+                }
+            }
+        },
+        "models.TemplateBody": {
+            "type": "object",
+            "properties": {
+                "channel_name": {
+                    "type": "string",
+                    "example": "email"
+                },
+                "html": {
+                    "type": "string",
+                    "example": "\u003cp\u003eThanks for signing up.\u003c/p\u003e"
+                },
+                "subject": {
+                    "type": "string",
+                    "example": "Welcome aboard"
+                },
+                "text": {
+                    "type": "string",
+                    "example": "Thanks for signing up."
+                }
+            }
+        },
         "models.TokenResponse": {
             "type": "object",
             "properties": {
@@ -557,6 +2944,36 @@ const docTemplate = `{
                 }
             }
         },
+        "models.UnreadCountResponse": {
+            "type": "object",
+            "properties": {
+                "unread": {
+                    "type": "integer",
+                    "example": 3
+                }
+            }
+        },
+        "models.WebhookSecret": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
+                },
+                "secret": {
+                    "type": "string",
+                    "example": "5f2b6c0e9a4d..."
+                },
+                "updated_at": {
+                    "type": "string",
+                    "example": "2025-10-26T12:00:00Z"
+                },
+                "user_id": {
+                    "type": "integer",
+                    "example": 123
+                }
+            }
+        },
         "user.LoginRequest": {
             "type": "object",
             "properties": {
@@ -600,7 +3017,7 @@ var SwaggerInfo = &swag.Spec{
 	BasePath:         "/",
 	Schemes:          []string{"http"},
 	Title:            "Notification API",
-	Description:      "API for managing notifications.\n\n**Channel Meta Requirements:**\n- Email: channels.ValidEmailMeta\n- SMS: channels.ValidSMSMeta\n- Push: channels.ValidPushMeta",
+	Description:      "API for managing notifications.\n\n**Channel Meta Requirements:**\n- Email: channels.ValidEmailMeta\n- SMS: channels.ValidSMSMeta\n- Push: channels.ValidPushMeta\n- Webhook: channels.ValidWebhookMeta\n- Chat: channels.ValidChatMeta\n- In-app: channels.ValidInAppMeta",
 	InfoInstanceName: "swagger",
 	SwaggerTemplate:  docTemplate,
 	LeftDelim:        "{{",
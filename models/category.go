@@ -0,0 +1,46 @@
+package models
+
+import "slices"
+
+// Priority is how urgently a notification is delivered
+type Priority string
+
+const (
+	PriorityLow    Priority = "low"
+	PriorityNormal Priority = "normal"
+	PriorityHigh   Priority = "high"
+)
+
+// Rank orders priorities from low (0) to high (2); unknown priorities rank as normal
+func (p Priority) Rank() int {
+	switch p {
+	case PriorityLow:
+		return 0
+	case PriorityHigh:
+		return 2
+	}
+	return 1
+}
+
+// Valid reports whether p is one of the known priorities
+func (p Priority) Valid() bool {
+	return p == PriorityLow || p == PriorityNormal || p == PriorityHigh
+}
+
+// Category is a kind of notification, such as security or marketing
+type Category struct {
+	Name        string `json:"name" example:"marketing"`
+	Description string `json:"description,omitempty" example:"Promotions and newsletters"`
+	// Mandatory categories are always delivered; users can't opt out of them
+	Mandatory bool `json:"mandatory" example:"false"`
+	// DefaultChannels are the channels users receive the category on until they
+	// set a preference for it. Empty means every channel
+	DefaultChannels []string `json:"default_channels,omitempty" example:"email,inapp"`
+	// DefaultPriority applies to notifications created without a priority
+	DefaultPriority Priority `json:"default_priority" example:"low"`
+}
+
+// OnChannel reports whether the category is sent on a channel by default
+func (c Category) OnChannel(channelName string) bool {
+	return len(c.DefaultChannels) == 0 || slices.Contains(c.DefaultChannels, channelName)
+}
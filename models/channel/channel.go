@@ -5,9 +5,21 @@ import (
 )
 
 type Message struct {
-	Title   string
-	Content string
-	Meta    map[string]string
+	Title     string            `json:"title"`
+	Content   string            `json:"content"`
+	Meta      map[string]string `json:"meta"`
+	Variables map[string]string `json:"variables,omitempty"`
+	// Category is the kind of notification, such as marketing
+	Category string `json:"category,omitempty"`
+	// Priority is low, normal or high
+	Priority string `json:"priority,omitempty"`
+	// UserID is the user who created the notification
+	UserID uint `json:"user_id,omitempty"`
+	// NotificationID is set when the message is dispatched from the outbox
+	NotificationID uint `json:"-"`
+	// Resolved holds the addresses Prepare looked up in the recipient's contact
+	// profile, which previews redact
+	Resolved []string `json:"-"`
 }
 type Channel interface {
 	Name() string
@@ -15,3 +27,10 @@ type Channel interface {
 	Validate(meta map[string]string) error
 	Prepare(ctx context.Context, msg *Message) error
 }
+
+// UserValidator is implemented by channels whose meta can reference things
+// only their owner may use, like stored attachment files. ValidateUser checks
+// meta for the user who creates the notification
+type UserValidator interface {
+	ValidateUser(userID uint, meta map[string]string) error
+}
@@ -0,0 +1,43 @@
+package channel
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// MetaRecipientUserID is the meta key holding the user a notification targets
+// when it was created with recipient_user_id instead of an address
+const MetaRecipientUserID = "recipient_user_id"
+
+// Contact is how a user can be reached, from their contact profile
+type Contact struct {
+	Email    string
+	Phone    string
+	Locale   string
+	Timezone string
+}
+
+// CreatorDefault is implemented by channels that deliver a notification that
+// doesn't address anyone to the user who created it, like the in-app inbox
+type CreatorDefault interface {
+	DeliversToCreator() bool
+}
+
+// ContactSource looks up the contact profile of a recipient
+type ContactSource interface {
+	Contact(ctx context.Context, userID uint) (Contact, error)
+}
+
+// RecipientUserID returns the recipient set in meta, or 0 when there is none
+func RecipientUserID(meta map[string]string) (uint, error) {
+	s := meta[MetaRecipientUserID]
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(s, 10, 64)
+	if err != nil || id == 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", MetaRecipientUserID)
+	}
+	return uint(id), nil
+}
@@ -0,0 +1,44 @@
+package channel
+
+import (
+	"fmt"
+	"time"
+)
+
+// RetryAfterError is returned by Send when the provider asked to be retried
+// later, e.g. an HTTP 429 with a Retry-After header. The delivery is
+// rescheduled instead of counted as a failure
+type RetryAfterError struct {
+	After time.Duration
+	Err   error
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("retry after %s: %v", e.After, e.Err)
+}
+
+func (e *RetryAfterError) Unwrap() error {
+	return e.Err
+}
+
+// PermanentError is returned by Send when sending again can't succeed, e.g.
+// the recipient no longer exists. The delivery is marked FAILED instead of retried
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Permanent marks err as a PermanentError
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
@@ -0,0 +1,26 @@
+package channel
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// Preview is a prepared message rendered the way a channel would deliver it
+type Preview struct {
+	Subject string `json:"subject,omitempty" example:"Welcome!"`
+	Text    string `json:"text" example:"Welcome to our platform!"`
+	HTML    string `json:"html,omitempty" example:"<p>Welcome to our platform!</p>"`
+	// Payload is the provider request body, for channels that send JSON
+	Payload json.RawMessage `json:"payload,omitempty" swaggertype:"object"`
+	// Segments is the number of SMS parts the text is split into, and Encoding
+	// the SMS encoding used (GSM-7 or UCS-2)
+	Segments int    `json:"segments,omitempty" example:"1"`
+	Encoding string `json:"encoding,omitempty" example:"GSM-7"`
+}
+
+// Previewer is implemented by channels that can render a prepared message
+// without sending it. Channels that don't implement it are previewed as
+// their title and content.
+type Previewer interface {
+	Preview(ctx context.Context, msg Message) (Preview, error)
+}
@@ -0,0 +1,26 @@
+package channel
+
+import "context"
+
+// Receipt collects what the provider reported about a delivery. Channels fill
+// in the receipt found in the Send context, if any
+type Receipt struct {
+	Provider          string
+	ProviderMessageID string
+	// Segments is the number of SMS parts billed, for cost tracking
+	Segments int
+}
+
+type receiptKey struct{}
+
+func WithReceipt(ctx context.Context, r *Receipt) context.Context {
+	return context.WithValue(ctx, receiptKey{}, r)
+}
+
+// ReceiptFrom returns the receipt of ctx, or a throwaway one when there is none
+func ReceiptFrom(ctx context.Context) *Receipt {
+	if r, ok := ctx.Value(receiptKey{}).(*Receipt); ok {
+		return r
+	}
+	return &Receipt{}
+}
@@ -0,0 +1,43 @@
+package channel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+var ErrTemplateNotFound = errors.New("template not found")
+
+// MetaTemplateVersion is the meta key holding the version of meta.template a
+// notification is sent with, recorded when it is created
+const MetaTemplateVersion = "template_version"
+
+// Template is the stored body of a template for a single channel
+type Template struct {
+	Name    string
+	Version int
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// TemplateSource resolves the template named in meta.template for a channel,
+// at the given version or the latest one when version is 0. It returns
+// ErrTemplateNotFound when there is no stored body for that channel
+type TemplateSource interface {
+	Lookup(ctx context.Context, name string, version int, channelName string) (Template, error)
+}
+
+// TemplateVersion returns the template version set in meta, or 0 when there is none
+func TemplateVersion(meta map[string]string) (int, error) {
+	s := meta[MetaTemplateVersion]
+	if s == "" {
+		return 0, nil
+	}
+	version, err := strconv.Atoi(s)
+	if err != nil || version <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer", MetaTemplateVersion)
+	}
+	return version, nil
+}
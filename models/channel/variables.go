@@ -0,0 +1,54 @@
+package channel
+
+import (
+	"strings"
+	"text/template"
+)
+
+// RenderVariables substitutes Variables into the title, content and subject
+// (e.g. "Hi {{.name}}"). In strict mode a reference to a missing variable or a
+// malformed expression is an error; otherwise missing variables render empty
+// and malformed text is left untouched
+func (m *Message) RenderVariables(strict bool) error {
+	fields := []*string{&m.Title, &m.Content}
+	if subject, ok := m.Meta["subject"]; ok {
+		if err := renderVariables(&subject, m.Variables, strict); err != nil {
+			return err
+		}
+		m.Meta["subject"] = subject
+	}
+	for _, field := range fields {
+		if err := renderVariables(field, m.Variables, strict); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func renderVariables(text *string, vars map[string]string, strict bool) error {
+	if !strings.Contains(*text, "{{") {
+		return nil
+	}
+
+	missingKey := "missingkey=zero"
+	if strict {
+		missingKey = "missingkey=error"
+	}
+	tmpl, err := template.New("").Option(missingKey).Parse(*text)
+	if err != nil {
+		if strict {
+			return err
+		}
+		return nil
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, vars); err != nil {
+		if strict {
+			return err
+		}
+		return nil
+	}
+	*text = b.String()
+	return nil
+}
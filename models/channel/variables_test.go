@@ -0,0 +1,48 @@
+package channel
+
+import "testing"
+
+func TestRenderVariables_OK(t *testing.T) {
+	m := Message{
+		Title:     "Hi {{.name}}",
+		Content:   "Order {{.order_id}} shipped",
+		Meta:      map[string]string{"subject": "Order {{.order_id}}", "to": "{{.name}}@example.com"},
+		Variables: map[string]string{"name": "Ana", "order_id": "42"},
+	}
+	if err := m.RenderVariables(true); err != nil {
+		t.Fatalf("RenderVariables: %v", err)
+	}
+	if m.Title != "Hi Ana" || m.Content != "Order 42 shipped" || m.Meta["subject"] != "Order 42" {
+		t.Fatalf("unexpected message: %+v", m)
+	}
+	if m.Meta["to"] != "{{.name}}@example.com" {
+		t.Fatalf("only the subject meta field should be rendered, got %q", m.Meta["to"])
+	}
+}
+
+func TestRenderVariables_MissingStrict(t *testing.T) {
+	m := Message{Title: "Hi {{.name}}", Variables: map[string]string{}}
+	if err := m.RenderVariables(true); err == nil {
+		t.Fatal("expected error for missing variable")
+	}
+}
+
+func TestRenderVariables_MissingLenient(t *testing.T) {
+	m := Message{Title: "Hi {{.name}}!", Content: "unbalanced {{.x}"}
+	if err := m.RenderVariables(false); err != nil {
+		t.Fatalf("RenderVariables: %v", err)
+	}
+	if m.Title != "Hi !" {
+		t.Fatalf("expected missing variable to render empty, got %q", m.Title)
+	}
+	if m.Content != "unbalanced {{.x}" {
+		t.Fatalf("expected malformed content to be left untouched, got %q", m.Content)
+	}
+}
+
+func TestRenderVariables_MalformedStrict(t *testing.T) {
+	m := Message{Content: "unbalanced {{.x}"}
+	if err := m.RenderVariables(true); err == nil {
+		t.Fatal("expected parse error in strict mode")
+	}
+}
@@ -4,7 +4,10 @@ import "notification/channels"
 
 // ChannelSchemasResponse represents the response for channel metadata schemas
 type ChannelSchemasResponse struct {
-	Email channels.ValidEmailMeta `json:"email"`
-	SMS   channels.ValidSMSMeta   `json:"sms"`
-	Push  channels.ValidPushMeta  `json:"push"`
+	Email   channels.ValidEmailMeta   `json:"email"`
+	SMS     channels.ValidSMSMeta     `json:"sms"`
+	Push    channels.ValidPushMeta    `json:"push"`
+	Webhook channels.ValidWebhookMeta `json:"webhook"`
+	Chat    channels.ValidChatMeta    `json:"chat"`
+	InApp   channels.ValidInAppMeta   `json:"inapp"`
 }
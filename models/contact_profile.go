@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// ContactProfile is how a user is reached by notifications created with
+// recipient_user_id. An empty Email falls back to the account email, and
+// addresses are only used once verified
+type ContactProfile struct {
+	ID     uint   `json:"-"`
+	UserID uint   `json:"user_id" example:"123" gorm:"not null;uniqueIndex"`
+	Email  string `json:"email" example:"user@example.com"`
+	// EmailVerified is set once the user enters the code sent to Email. The
+	// account email counts as verified
+	EmailVerified bool `json:"email_verified" gorm:"not null;default:false"`
+	// Phone is stored in E.164 format
+	Phone         string `json:"phone" example:"+14155552671"`
+	PhoneVerified bool   `json:"phone_verified" gorm:"not null;default:false"`
+	Locale        string `json:"locale" example:"en-US"`
+	Timezone      string `json:"timezone" example:"America/New_York"`
+	// EmailCode and PhoneCode are the SHA-256 of the pending verification codes
+	EmailCode     string     `json:"-"`
+	PhoneCode     string     `json:"-"`
+	CodeExpiresAt *time.Time `json:"-"`
+	// CodeAttempts counts wrong codes entered since the codes were sent
+	CodeAttempts int `json:"-" gorm:"not null;default:0"`
+	// Devices are the user's registered push devices
+	Devices   []Device  `json:"devices" gorm:"-"`
+	CreatedAt time.Time `json:"created_at" example:"2025-10-26T12:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2025-10-26T12:00:00Z"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// DeliveryError is one failed attempt to send an outbox row. Together they are
+// the error history of a delivery, kept across replays
+type DeliveryError struct {
+	ID       uint `json:"id" example:"1"`
+	OutboxID uint `json:"outbox_id" example:"12" gorm:"not null;index"`
+	// Attempt is the number of the attempt that failed, counted from 1 since the last replay
+	Attempt   int       `json:"attempt" example:"3"`
+	Error     string    `json:"error" example:"twilio: status 503: service unavailable" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at" example:"2025-10-26T12:00:00Z"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Device is a push token registered by a user, or reported dead by a push
+// provider before anyone registered it. Tokens the push provider
+// reported as unregistered keep their row with InvalidatedAt set, so later
+// notifications to them are skipped
+type Device struct {
+	ID     uint   `json:"id" example:"1"`
+	UserID uint   `json:"user_id" example:"123" gorm:"index"`
+	Token  string `json:"token" example:"device_token_xyz123" gorm:"not null;type:text"`
+	// TokenHash is the hex SHA-256 of Token; tokens are too long to index directly
+	TokenHash     string     `json:"-" gorm:"not null;size:64;uniqueIndex"`
+	Platform      string     `json:"platform" example:"android"`
+	AppVersion    string     `json:"app_version,omitempty" example:"2.4.1"`
+	InvalidatedAt *time.Time `json:"invalidated_at,omitempty" example:"2025-10-26T12:00:00Z"`
+	InvalidReason string     `json:"invalid_reason,omitempty" example:"UNREGISTERED"`
+	CreatedAt     time.Time  `json:"created_at" example:"2025-10-26T12:00:00Z"`
+	UpdatedAt     time.Time  `json:"updated_at" example:"2025-10-26T12:00:00Z"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// InboxMessage is a notification delivered to a user's in-app inbox
+type InboxMessage struct {
+	ID     uint `json:"id" example:"1"`
+	UserID uint `json:"user_id" example:"123" gorm:"not null;index:idx_inbox_user_read,priority:1"`
+	// NotificationID is unique so a retried delivery doesn't duplicate the message
+	NotificationID uint       `json:"notification_id" example:"42" gorm:"not null;uniqueIndex"`
+	Title          string     `json:"title" example:"New comment"`
+	Content        string     `json:"content" example:"Ana replied to your post" gorm:"type:text"`
+	Data           string     `json:"data,omitempty" example:"{\"post_id\":\"7\"}" gorm:"type:text"`
+	ReadAt         *time.Time `json:"read_at" gorm:"index:idx_inbox_user_read,priority:2"`
+	CreatedAt      time.Time  `json:"created_at" example:"2025-10-26T12:00:00Z"`
+}
+
+// UnreadCountResponse represents the number of unread inbox messages
+type UnreadCountResponse struct {
+	Unread int64 `json:"unread" example:"3"`
+}
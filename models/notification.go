@@ -1,14 +1,30 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
 type Notification struct {
 	gorm.Model
-	UserID         uint `gorm:"not null;index"`
-	Title          string
-	Content        string
-	ChannelName    string
+	UserID uint `gorm:"not null;index"`
+	// RecipientUserID is the user the notification is addressed to, from
+	// recipient_user_id or the meta.user_id of push and in-app; it's 0 for raw
+	// addresses. Channels look a missing address up in the contact profile, and
+	// delivered/read acks are accepted from this user
+	RecipientUserID uint `gorm:"index"`
+	Title           string
+	Content         string
+	ChannelName     string
+	// Category is the kind of notification, such as marketing, that users set preferences for
+	Category       string   `gorm:"size:64;index"`
+	Priority       Priority `gorm:"size:16"`
 	IdempotencyKey string
+	// TemplateVersion is the version of meta.template the notification is sent
+	// with, the latest one when it was created; 0 without a stored template
+	TemplateVersion int
+	// DeliveredAt and ReadAt are set from client acknowledgements
+	DeliveredAt *time.Time
+	ReadAt      *time.Time
 }
@@ -9,19 +9,39 @@ const (
 	PROCESSING Status = "PROCESSING"
 	SENT       Status = "SENT"
 	FAILED     Status = "FAILED"
+	// SKIPPED deliveries were suppressed by the recipient's preferences
+	SKIPPED Status = "SKIPPED"
+	// DEAD_LETTER deliveries failed every attempt and wait to be replayed or discarded
+	DEAD_LETTER Status = "DEAD_LETTER"
 )
 
 type Outbox struct {
-	ID             uint
-	NotificationID uint
-	ChannelName    string
-	PayloadJson    string
-	Status         Status `gorm:"index:idx_status_scheduled,priority:1"`
-	Attempts       int
-	LastError      string
-	NextAttemptAt  time.Time
-	ScheduledAt    time.Time `gorm:"index:idx_status_scheduled,priority:2"`
-	MaxAttempts    int
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	ID             uint      `json:"id" example:"12"`
+	NotificationID uint      `json:"notification_id" example:"1"`
+	ChannelName    string    `json:"channel_name" example:"sms"`
+	PayloadJson    string    `json:"payload_json"`
+	Status         Status    `json:"status" example:"DEAD_LETTER" gorm:"index:idx_status_scheduled,priority:1"`
+	Attempts       int       `json:"attempts" example:"5"`
+	LastError      string    `json:"last_error" example:"twilio: status 503: service unavailable"`
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	ScheduledAt    time.Time `json:"scheduled_at" gorm:"index:idx_status_scheduled,priority:2"`
+	MaxAttempts    int       `json:"max_attempts" example:"5"`
+	// Reschedules counts the rate limits since the last attempt, which don't
+	// use an attempt until there are too many in a row
+	Reschedules int `json:"reschedules" example:"0"`
+	// Priority is the rank of the notification priority; higher ranks are claimed first
+	Priority int `json:"priority" example:"1"`
+	// SkipReason explains why a SKIPPED delivery wasn't sent
+	SkipReason string `json:"skip_reason,omitempty"`
+	// Provider and ProviderMessageID identify the message at the provider once sent
+	Provider          string `json:"provider,omitempty"`
+	ProviderMessageID string `json:"provider_message_id,omitempty"`
+	// Segments is the number of SMS parts sent
+	Segments int `json:"segments,omitempty"`
+	// LeaseOwner is the worker that claimed a PROCESSING row. It keeps the row
+	// until LeaseExpiresAt, after which the row can be taken back
+	LeaseOwner     string     `json:"lease_owner,omitempty" gorm:"size:128;not null;default:''"`
+	LeaseExpiresAt *time.Time `json:"lease_expires_at,omitempty" gorm:"index"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PreferenceAny matches every channel or every category in a Preference
+const PreferenceAny = "*"
+
+// Preference records whether a user accepts notifications of a category on a
+// channel. The most specific preference matching a notification applies, and
+// notifications without a matching preference are sent
+type Preference struct {
+	ID          uint      `json:"-"`
+	UserID      uint      `json:"-" gorm:"not null;uniqueIndex:idx_preference"`
+	ChannelName string    `json:"channel_name" example:"sms" gorm:"not null;size:32;uniqueIndex:idx_preference"`
+	Category    string    `json:"category" example:"marketing" gorm:"not null;size:64;uniqueIndex:idx_preference"`
+	Enabled     bool      `json:"enabled" example:"false"`
+	CreatedAt   time.Time `json:"created_at" example:"2025-10-26T12:00:00Z"`
+	UpdatedAt   time.Time `json:"updated_at" example:"2025-10-26T12:00:00Z"`
+}
@@ -19,21 +19,38 @@ type TokenResponse struct {
 
 // CreateNotificationRequest represents the request body for creating a notification
 type CreateNotificationRequest struct {
-	Title       string            `json:"title" example:"Welcome email"`
-	Content     string            `json:"content" example:"Welcome to our platform!"`
-	ChannelName string            `json:"channel_name" example:"email" enums:"email,sms,push"`
-	Meta        map[string]string `json:"meta" swaggertype:"object,string"`
-	ScheduledAt *string           `json:"scheduled_at,omitempty" example:"2025-10-27T10:00:00Z"`
+	Title           string            `json:"title" example:"Welcome email"`
+	Content         string            `json:"content" example:"Welcome to our platform!"`
+	ChannelName     string            `json:"channel_name" example:"email" enums:"email,sms,push,webhook,chat,inapp"`
+	Meta            map[string]string `json:"meta" swaggertype:"object,string"`
+	Variables       map[string]string `json:"variables,omitempty" swaggertype:"object,string"`
+	StrictVariables bool              `json:"strict_variables,omitempty" example:"false"`
+	RecipientUserID uint              `json:"recipient_user_id,omitempty" example:"42"`
+	Category        string            `json:"category,omitempty" example:"marketing"`
+	// Priority defaults to the priority of the category
+	Priority    string  `json:"priority,omitempty" example:"high" enums:"low,normal,high"`
+	ScheduledAt *string `json:"scheduled_at,omitempty" example:"2025-10-27T10:00:00Z"`
 }
 
 // NotificationResponse represents a notification for API responses (without gorm.Model)
 type NotificationResponse struct {
-	ID             uint      `json:"id" example:"1"`
-	CreatedAt      time.Time `json:"created_at" example:"2025-10-26T12:00:00Z"`
-	UpdatedAt      time.Time `json:"updated_at" example:"2025-10-26T12:00:00Z"`
-	UserID         uint      `json:"user_id" example:"123"`
-	Title          string    `json:"title" example:"Welcome email"`
-	Content        string    `json:"content" example:"Welcome to our platform!"`
-	ChannelName    string    `json:"channel_name" example:"email"`
-	IdempotencyKey string    `json:"idempotency_key" example:"a1b2c3d4e5f6"`
+	ID              uint       `json:"id" example:"1"`
+	CreatedAt       time.Time  `json:"created_at" example:"2025-10-26T12:00:00Z"`
+	UpdatedAt       time.Time  `json:"updated_at" example:"2025-10-26T12:00:00Z"`
+	UserID          uint       `json:"user_id" example:"123"`
+	RecipientUserID uint       `json:"recipient_user_id,omitempty" example:"42"`
+	Title           string     `json:"title" example:"Welcome email"`
+	Content         string     `json:"content" example:"Welcome to our platform!"`
+	ChannelName     string     `json:"channel_name" example:"email"`
+	Category        string     `json:"category,omitempty" example:"marketing"`
+	Priority        string     `json:"priority" example:"normal" enums:"low,normal,high"`
+	IdempotencyKey  string     `json:"idempotency_key" example:"a1b2c3d4e5f6"`
+	TemplateVersion int        `json:"template_version,omitempty" example:"3"`
+	DeliveredAt     *time.Time `json:"delivered_at" example:"2025-10-26T12:00:05Z"`
+	ReadAt          *time.Time `json:"read_at" example:"2025-10-26T12:03:00Z"`
+}
+
+// DeadLetterBatchResponse reports how many dead letters a batch operation affected
+type DeadLetterBatchResponse struct {
+	Count int64 `json:"count" example:"12"`
 }
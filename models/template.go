@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Template is one version of a named template. Updating a template creates a
+// new version, so notifications can be traced back to the exact content used
+type Template struct {
+	ID        uint           `json:"id" example:"1"`
+	Name      string         `json:"name" example:"welcome" gorm:"not null;size:191;uniqueIndex:idx_template_name_version"`
+	Version   int            `json:"version" example:"1" gorm:"not null;uniqueIndex:idx_template_name_version"`
+	Bodies    []TemplateBody `json:"bodies"`
+	CreatedAt time.Time      `json:"created_at" example:"2025-10-26T12:00:00Z"`
+	UpdatedAt time.Time      `json:"updated_at" example:"2025-10-26T12:00:00Z"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index" swaggerignore:"true"`
+}
+
+// TemplateBody is the content of a template version for a single channel
+type TemplateBody struct {
+	ID          uint   `json:"-"`
+	TemplateID  uint   `json:"-" gorm:"not null;uniqueIndex:idx_template_body_channel"`
+	ChannelName string `json:"channel_name" example:"email" gorm:"not null;size:64;uniqueIndex:idx_template_body_channel"`
+	Subject     string `json:"subject,omitempty" example:"Welcome aboard"`
+	Text        string `json:"text" example:"Thanks for signing up." gorm:"type:text"`
+	HTML        string `json:"html,omitempty" example:"<p>Thanks for signing up.</p>" gorm:"type:text"`
+}
@@ -7,4 +7,6 @@ type User struct {
 	Name     string `gorm:"not null"`
 	Email    string `gorm:"not null;unique"`
 	Password string `gorm:"not null"`
+	// IsAdmin grants access to the /admin endpoints
+	IsAdmin bool `gorm:"not null;default:false"`
 }
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// WebhookSecret is the key a user's webhook notifications are signed with
+type WebhookSecret struct {
+	ID        uint      `json:"-"`
+	UserID    uint      `json:"user_id" example:"123" gorm:"not null;uniqueIndex"`
+	Secret    string    `json:"secret" example:"5f2b6c0e9a4d..." gorm:"not null;size:64"`
+	CreatedAt time.Time `json:"created_at" example:"2025-10-26T12:00:00Z"`
+	UpdatedAt time.Time `json:"updated_at" example:"2025-10-26T12:00:00Z"`
+}
@@ -0,0 +1,92 @@
+package categories
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"notification/models"
+	"os"
+	"regexp"
+	"slices"
+)
+
+// Default is the category of notifications created without one
+const Default = "general"
+
+var (
+	ErrUnknownCategory = errors.New("unknown category")
+
+	namePattern = regexp.MustCompile(`^[a-z0-9_-]{1,64}$`)
+)
+
+// Builtin are the categories used when CATEGORIES_FILE isn't set
+var Builtin = []models.Category{
+	{Name: "security", Description: "Sign-ins, password changes and other account security alerts", Mandatory: true, DefaultPriority: models.PriorityHigh},
+	{Name: "transactional", Description: "Receipts, order updates and other messages about something the user did", Mandatory: true, DefaultPriority: models.PriorityNormal},
+	{Name: Default, Description: "Notifications created without a category", DefaultPriority: models.PriorityNormal},
+	{Name: "product", Description: "Product updates, tips and announcements", DefaultChannels: []string{"email", "push", "inapp"}, DefaultPriority: models.PriorityNormal},
+	{Name: "marketing", Description: "Promotions and newsletters", DefaultChannels: []string{"email", "inapp"}, DefaultPriority: models.PriorityLow},
+}
+
+// Registry holds the categories notifications may be created with
+type Registry struct {
+	list   []models.Category
+	byName map[string]models.Category
+}
+
+// New validates the categories. They must include Default, and default
+// channels must be among channelNames
+func New(list []models.Category, channelNames []string) (*Registry, error) {
+	r := &Registry{list: list, byName: make(map[string]models.Category, len(list))}
+	for _, c := range list {
+		if !namePattern.MatchString(c.Name) {
+			return nil, fmt.Errorf("category %q: name must be lowercase letters, digits, - and _", c.Name)
+		}
+		if _, ok := r.byName[c.Name]; ok {
+			return nil, fmt.Errorf("category %q is defined twice", c.Name)
+		}
+		if !c.DefaultPriority.Valid() {
+			return nil, fmt.Errorf("category %q: invalid default priority %q", c.Name, c.DefaultPriority)
+		}
+		for _, name := range c.DefaultChannels {
+			if !slices.Contains(channelNames, name) {
+				return nil, fmt.Errorf("category %q: unknown default channel %q", c.Name, name)
+			}
+		}
+		r.byName[c.Name] = c
+	}
+	if _, ok := r.byName[Default]; !ok {
+		return nil, fmt.Errorf("categories must include %q", Default)
+	}
+	return r, nil
+}
+
+// ReadFile reads categories from a JSON array in the format of models.Category
+func ReadFile(path string) ([]models.Category, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading categories: %w", err)
+	}
+	var list []models.Category
+	if err := json.Unmarshal(b, &list); err != nil {
+		return nil, fmt.Errorf("invalid categories file: %w", err)
+	}
+	return list, nil
+}
+
+// Get returns a category by name; an empty name is the Default category
+func (r *Registry) Get(name string) (models.Category, error) {
+	if name == "" {
+		name = Default
+	}
+	c, ok := r.byName[name]
+	if !ok {
+		return models.Category{}, fmt.Errorf("%w: %q", ErrUnknownCategory, name)
+	}
+	return c, nil
+}
+
+// List returns the categories in the order they were defined
+func (r *Registry) List() []models.Category {
+	return r.list
+}
@@ -0,0 +1,74 @@
+package categories
+
+import (
+	"errors"
+	"notification/models"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var channels = []string{"email", "sms", "push", "webhook", "chat", "inapp"}
+
+func TestNew_Builtin(t *testing.T) {
+	r, err := New(Builtin, channels)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	c, err := r.Get("")
+	if err != nil || c.Name != Default {
+		t.Fatalf("expected the default category, got %+v, %v", c, err)
+	}
+	c, err = r.Get("security")
+	if err != nil || !c.Mandatory || c.DefaultPriority != models.PriorityHigh {
+		t.Fatalf("unexpected security category %+v, %v", c, err)
+	}
+	if _, err := r.Get("digest"); !errors.Is(err, ErrUnknownCategory) {
+		t.Fatalf("expected ErrUnknownCategory, got %v", err)
+	}
+	if len(r.List()) != len(Builtin) {
+		t.Fatalf("expected %d categories, got %d", len(Builtin), len(r.List()))
+	}
+}
+
+func TestNew_Invalid(t *testing.T) {
+	general := models.Category{Name: Default, DefaultPriority: models.PriorityNormal}
+	tests := []struct {
+		name string
+		list []models.Category
+	}{
+		{"missing default", []models.Category{{Name: "security", DefaultPriority: models.PriorityHigh}}},
+		{"bad name", []models.Category{general, {Name: "Security Alerts", DefaultPriority: models.PriorityHigh}}},
+		{"duplicate", []models.Category{general, general}},
+		{"priority", []models.Category{general, {Name: "news", DefaultPriority: "urgent"}}},
+		{"channel", []models.Category{general, {Name: "news", DefaultPriority: models.PriorityLow, DefaultChannels: []string{"fax"}}}},
+	}
+	for _, tt := range tests {
+		if _, err := New(tt.list, channels); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "categories.json")
+	data := `[
+		{"name": "general", "default_priority": "normal"},
+		{"name": "digest", "default_channels": ["email"], "default_priority": "low"}
+	]`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	list, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	r, err := New(list, channels)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	digest, err := r.Get("digest")
+	if err != nil || digest.OnChannel("sms") || !digest.OnChannel("email") {
+		t.Fatalf("unexpected digest category %+v, %v", digest, err)
+	}
+}
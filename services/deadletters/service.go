@@ -0,0 +1,183 @@
+package deadletters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+	ErrInvalidFilter      = errors.New("invalid filter")
+)
+
+// statuses are the outbox statuses of deliveries that won't be sent again on
+// their own: DEAD_LETTER after running out of attempts, FAILED after a
+// permanent error
+var statuses = []models.Status{models.DEAD_LETTER, models.FAILED}
+
+// Service inspects, replays and discards outbox rows that were never delivered
+type Service struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Service { return &Service{db: db} }
+
+// Filter selects dead letters; empty fields match everything
+type Filter struct {
+	ChannelName string
+	// Error matches dead letters whose last error contains it
+	Error string
+	// Status is DEAD_LETTER or FAILED
+	Status models.Status
+	// All confirms that an empty filter is meant to discard every dead letter
+	All bool
+}
+
+func (f Filter) empty() bool {
+	return f.ChannelName == "" && f.Error == "" && f.Status == ""
+}
+
+// DeadLetter is an undelivered outbox row with its error history
+type DeadLetter struct {
+	models.Outbox
+	Errors []models.DeliveryError `json:"errors"`
+}
+
+func (s *Service) query(ctx context.Context, filter Filter) (*gorm.DB, error) {
+	q := s.db.WithContext(ctx).Model(&models.Outbox{})
+	switch filter.Status {
+	case "":
+		q = q.Where("status IN ?", statuses)
+	case models.DEAD_LETTER, models.FAILED:
+		q = q.Where("status = ?", filter.Status)
+	default:
+		return nil, fmt.Errorf("%w: status must be DEAD_LETTER or FAILED", ErrInvalidFilter)
+	}
+	if filter.ChannelName != "" {
+		q = q.Where("channel_name = ?", filter.ChannelName)
+	}
+	if filter.Error != "" {
+		// ! rather than \ as the escape character works the same in MySQL and SQLite
+		escaped := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(filter.Error)
+		q = q.Where("last_error LIKE ? ESCAPE '!'", "%"+escaped+"%")
+	}
+	return q, nil
+}
+
+// List returns dead letters, most recently failed first
+func (s *Service) List(ctx context.Context, filter Filter, limit, offset int) ([]models.Outbox, error) {
+	q, err := s.query(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	list := []models.Outbox{}
+	q = q.Order("updated_at DESC, id DESC")
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if offset > 0 {
+		q = q.Offset(offset)
+	}
+	return list, q.Find(&list).Error
+}
+
+// Get returns a dead letter with every error it ran into
+func (s *Service) Get(ctx context.Context, id uint) (*DeadLetter, error) {
+	var dl DeadLetter
+	if err := s.db.WithContext(ctx).Where("id = ? AND status IN ?", id, statuses).First(&dl.Outbox).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrDeadLetterNotFound
+		}
+		return nil, err
+	}
+	dl.Errors = []models.DeliveryError{}
+	if err := s.db.WithContext(ctx).Where("outbox_id = ?", id).Order("id").Find(&dl.Errors).Error; err != nil {
+		return nil, err
+	}
+	return &dl, nil
+}
+
+// replayUpdates send a dead letter again right away with a fresh set of
+// attempts. The error history is kept
+func replayUpdates() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"status":          models.PENDING,
+		"attempts":        0,
+		"next_attempt_at": now,
+		"updated_at":      now,
+	}
+}
+
+// Replay returns a dead letter to PENDING
+func (s *Service) Replay(ctx context.Context, id uint) error {
+	res := s.db.WithContext(ctx).Model(&models.Outbox{}).
+		Where("id = ? AND status IN ?", id, statuses).
+		Updates(replayUpdates())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDeadLetterNotFound
+	}
+	return nil
+}
+
+// ReplayAll returns the dead letters matching filter to PENDING and reports how many
+func (s *Service) ReplayAll(ctx context.Context, filter Filter) (int64, error) {
+	q, err := s.query(ctx, filter)
+	if err != nil {
+		return 0, err
+	}
+	res := q.Updates(replayUpdates())
+	return res.RowsAffected, res.Error
+}
+
+// Discard deletes a dead letter and its error history. The notification is kept
+func (s *Service) Discard(ctx context.Context, id uint) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := tx.Where("id = ? AND status IN ?", id, statuses).Delete(&models.Outbox{})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrDeadLetterNotFound
+		}
+		return tx.Where("outbox_id = ?", id).Delete(&models.DeliveryError{}).Error
+	})
+}
+
+// DiscardAll deletes the dead letters matching filter and reports how many. An
+// empty filter is rejected unless All is set
+func (s *Service) DiscardAll(ctx context.Context, filter Filter) (int64, error) {
+	if filter.empty() && !filter.All {
+		return 0, fmt.Errorf("%w: set all to discard every dead letter", ErrInvalidFilter)
+	}
+	var discarded int64
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		q, err := (&Service{db: tx}).query(ctx, filter)
+		if err != nil {
+			return err
+		}
+		var ids []uint
+		if err := q.Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		res := tx.Where("id IN ? AND status IN ?", ids, statuses).Delete(&models.Outbox{})
+		if res.Error != nil {
+			return res.Error
+		}
+		discarded = res.RowsAffected
+		return tx.Where("outbox_id IN ?", ids).Delete(&models.DeliveryError{}).Error
+	})
+	return discarded, err
+}
@@ -0,0 +1,181 @@
+package deadletters
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"notification/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	db, err := gorm.Open(dsn, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Outbox{}, &models.DeliveryError{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// seed creates outbox rows for the channel, status and last error of each entry
+func seed(t *testing.T, db *gorm.DB, rows ...models.Outbox) []models.Outbox {
+	t.Helper()
+	for i := range rows {
+		rows[i].NotificationID = uint(i + 1)
+		rows[i].Attempts = 5
+		rows[i].MaxAttempts = 5
+		if err := db.Create(&rows[i]).Error; err != nil {
+			t.Fatalf("seed outbox: %v", err)
+		}
+		for attempt := 1; attempt <= 2; attempt++ {
+			db.Create(&models.DeliveryError{OutboxID: rows[i].ID, Attempt: attempt, Error: rows[i].LastError})
+		}
+	}
+	return rows
+}
+
+func TestList(t *testing.T) {
+	db := newTestDB(t)
+	svc := New(db)
+	ctx := context.Background()
+	seed(t, db,
+		models.Outbox{ChannelName: "sms", Status: models.DEAD_LETTER, LastError: "twilio: status 503: unavailable"},
+		models.Outbox{ChannelName: "sms", Status: models.FAILED, LastError: "twilio: status 400: invalid number"},
+		models.Outbox{ChannelName: "webhook", Status: models.DEAD_LETTER, LastError: "status 503"},
+		models.Outbox{ChannelName: "sms", Status: models.SENT},
+		models.Outbox{ChannelName: "sms", Status: models.PENDING, LastError: "status 503"},
+	)
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"all", Filter{}, 3},
+		{"channel", Filter{ChannelName: "sms"}, 2},
+		{"error", Filter{Error: "503"}, 2},
+		{"channel and error", Filter{ChannelName: "sms", Error: "503"}, 1},
+		{"status", Filter{Status: models.FAILED}, 1},
+		{"wildcards are literal", Filter{Error: "status 5%"}, 0},
+	}
+	for _, tt := range tests {
+		list, err := svc.List(ctx, tt.filter, 50, 0)
+		if err != nil {
+			t.Fatalf("%s: List: %v", tt.name, err)
+		}
+		if len(list) != tt.want {
+			t.Errorf("%s: expected %d dead letters, got %d", tt.name, tt.want, len(list))
+		}
+	}
+
+	if _, err := svc.List(ctx, Filter{Status: models.SENT}, 50, 0); !errors.Is(err, ErrInvalidFilter) {
+		t.Fatalf("expected ErrInvalidFilter, got %v", err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	db := newTestDB(t)
+	svc := New(db)
+	rows := seed(t, db,
+		models.Outbox{ChannelName: "sms", Status: models.DEAD_LETTER, LastError: "timeout"},
+		models.Outbox{ChannelName: "sms", Status: models.SENT},
+	)
+
+	dl, err := svc.Get(context.Background(), rows[0].ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(dl.Errors) != 2 || dl.Errors[1].Attempt != 2 || dl.Errors[1].Error != "timeout" {
+		t.Fatalf("expected the error history, got %+v", dl.Errors)
+	}
+	if _, err := svc.Get(context.Background(), rows[1].ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Fatalf("sent rows aren't dead letters, got %v", err)
+	}
+}
+
+func TestReplay(t *testing.T) {
+	db := newTestDB(t)
+	svc := New(db)
+	ctx := context.Background()
+	rows := seed(t, db,
+		models.Outbox{ChannelName: "sms", Status: models.DEAD_LETTER, LastError: "status 503"},
+		models.Outbox{ChannelName: "sms", Status: models.DEAD_LETTER, LastError: "status 503"},
+		models.Outbox{ChannelName: "email", Status: models.DEAD_LETTER, LastError: "status 503"},
+		models.Outbox{ChannelName: "sms", Status: models.SENT},
+	)
+
+	if err := svc.Replay(ctx, rows[0].ID); err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+	var o models.Outbox
+	db.First(&o, rows[0].ID)
+	if o.Status != models.PENDING || o.Attempts != 0 || time.Until(o.NextAttemptAt) > 0 {
+		t.Fatalf("expected a fresh PENDING row, got %+v", o)
+	}
+	var history int64
+	db.Model(&models.DeliveryError{}).Where("outbox_id = ?", o.ID).Count(&history)
+	if history != 2 {
+		t.Fatalf("expected the error history to be kept, got %d", history)
+	}
+	if err := svc.Replay(ctx, rows[3].ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Fatalf("expected sent rows not to be replayed, got %v", err)
+	}
+
+	count, err := svc.ReplayAll(ctx, Filter{ChannelName: "sms"})
+	if err != nil || count != 1 {
+		t.Fatalf("expected 1 replayed row, got %d, %v", count, err)
+	}
+	var other models.Outbox
+	db.First(&other, rows[2].ID)
+	if other.Status != models.DEAD_LETTER {
+		t.Fatalf("expected other channels to be left alone, got %s", other.Status)
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	db := newTestDB(t)
+	svc := New(db)
+	ctx := context.Background()
+	rows := seed(t, db,
+		models.Outbox{ChannelName: "sms", Status: models.DEAD_LETTER, LastError: "a"},
+		models.Outbox{ChannelName: "sms", Status: models.FAILED, LastError: "b"},
+		models.Outbox{ChannelName: "email", Status: models.FAILED, LastError: "b"},
+		models.Outbox{ChannelName: "sms", Status: models.PENDING},
+	)
+
+	if err := svc.Discard(ctx, rows[0].ID); err != nil {
+		t.Fatalf("Discard: %v", err)
+	}
+	if err := svc.Discard(ctx, rows[0].ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Fatalf("expected ErrDeadLetterNotFound, got %v", err)
+	}
+	if err := svc.Discard(ctx, rows[3].ID); !errors.Is(err, ErrDeadLetterNotFound) {
+		t.Fatalf("expected pending rows not to be discarded, got %v", err)
+	}
+
+	if _, err := svc.DiscardAll(ctx, Filter{}); !errors.Is(err, ErrInvalidFilter) {
+		t.Fatalf("expected an empty filter to be rejected, got %v", err)
+	}
+	count, err := svc.DiscardAll(ctx, Filter{Status: models.FAILED, Error: "b"})
+	if err != nil || count != 2 {
+		t.Fatalf("expected 2 discarded rows, got %d, %v", count, err)
+	}
+	var remaining, history int64
+	db.Model(&models.Outbox{}).Count(&remaining)
+	db.Model(&models.DeliveryError{}).Where("outbox_id <> ?", rows[3].ID).Count(&history)
+	if remaining != 1 || history != 0 {
+		t.Fatalf("expected only the pending row and its history to remain, got %d rows and %d errors", remaining, history)
+	}
+	if count, err := svc.DiscardAll(ctx, Filter{All: true}); err != nil || count != 0 {
+		t.Fatalf("expected all to discard only dead letters, got %d, %v", count, err)
+	}
+}
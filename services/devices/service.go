@@ -0,0 +1,135 @@
+package devices
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"notification/channels"
+	"notification/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrDeviceNotFound = errors.New("device not found")
+	ErrInvalidDevice  = errors.New("invalid device")
+	ErrDeviceConflict = errors.New("device token is registered to another user")
+)
+
+// Service is the registry of push device tokens
+type Service struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Service { return &Service{db: db} }
+
+func tokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Register adds a device for a user. Registering a known token of the user
+// updates it and makes it active again. A token registered to another user is
+// only moved once the push provider reported it invalid
+func (s *Service) Register(ctx context.Context, userID uint, token, platform, appVersion string) (*models.Device, error) {
+	platform = strings.ToLower(platform)
+	if len(token) < 10 || len(token) > 4096 {
+		return nil, fmt.Errorf("%w: token must be 10 to 4096 characters", ErrInvalidDevice)
+	}
+	switch platform {
+	case channels.PushPlatformAndroid, channels.PushPlatformIOS, channels.PushPlatformWeb:
+	default:
+		return nil, fmt.Errorf("%w: platform must be android, ios or web", ErrInvalidDevice)
+	}
+
+	device := models.Device{UserID: userID, Token: token, TokenHash: tokenHash(token), Platform: platform, AppVersion: appVersion}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var existing models.Device
+		err := tx.Where("token_hash = ?", device.TokenHash).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return tx.Create(&device).Error
+		}
+		if err != nil {
+			return err
+		}
+		// otherwise anyone who learns a token could take over pushes to another user's device
+		if existing.UserID != userID && existing.InvalidatedAt == nil {
+			return ErrDeviceConflict
+		}
+		return tx.Model(&existing).Updates(map[string]any{
+			"user_id":        userID,
+			"platform":       platform,
+			"app_version":    appVersion,
+			"invalidated_at": nil,
+			"invalid_reason": "",
+			"updated_at":     time.Now(),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var stored models.Device
+	if err := s.db.WithContext(ctx).Where("token_hash = ?", device.TokenHash).First(&stored).Error; err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// List returns the devices of a user, including invalidated ones
+func (s *Service) List(ctx context.Context, userID uint) ([]models.Device, error) {
+	var list []models.Device
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&list).Error
+	return list, err
+}
+
+// Delete removes a device of a user
+func (s *Service) Delete(ctx context.Context, userID, id uint) error {
+	res := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).Delete(&models.Device{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+// ActiveDevices implements channels.PushDevices
+func (s *Service) ActiveDevices(ctx context.Context, userID uint) ([]channels.PushDevice, error) {
+	var list []models.Device
+	if err := s.db.WithContext(ctx).Where("user_id = ? AND invalidated_at IS NULL", userID).Order("id").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	devices := make([]channels.PushDevice, 0, len(list))
+	for _, d := range list {
+		devices = append(devices, channels.PushDevice{Token: d.Token, Platform: d.Platform})
+	}
+	return devices, nil
+}
+
+// Invalid implements channels.PushTokens
+func (s *Service) Invalid(ctx context.Context, token string) (bool, error) {
+	var device models.Device
+	err := s.db.WithContext(ctx).Where("token_hash = ?", tokenHash(token)).First(&device).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return device.InvalidatedAt != nil, nil
+}
+
+// Invalidate implements channels.PushTokens. Only registered devices are
+// marked; a token nobody registered isn't recorded
+func (s *Service) Invalidate(ctx context.Context, token, platform, reason string) error {
+	now := time.Now()
+	return s.db.WithContext(ctx).Model(&models.Device{}).
+		Where("token_hash = ?", tokenHash(token)).
+		Updates(map[string]any{"invalidated_at": now, "invalid_reason": reason, "updated_at": now}).Error
+}
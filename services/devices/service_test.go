@@ -0,0 +1,178 @@
+package devices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"notification/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	db, err := gorm.Open(dsn, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Device{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestInvalidate(t *testing.T) {
+	db := newTestDB(t)
+	svc := New(db)
+	ctx := context.Background()
+
+	if invalid, err := svc.Invalid(ctx, "token-a"); err != nil || invalid {
+		t.Fatalf("unknown token should be valid, got %v, %v", invalid, err)
+	}
+	if err := svc.Invalidate(ctx, "token-a", "android", "UNREGISTERED"); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+	// reported again by another notification
+	if err := svc.Invalidate(ctx, "token-a", "android", "UNREGISTERED"); err != nil {
+		t.Fatalf("Invalidate again: %v", err)
+	}
+	if invalid, err := svc.Invalid(ctx, "token-a"); err != nil || invalid {
+		t.Fatalf("unregistered token should not be recorded, got %v, %v", invalid, err)
+	}
+
+	var count int64
+	db.Model(&models.Device{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no orphan device rows, got %d", count)
+	}
+}
+
+func TestInvalidate_RegisteredDevice(t *testing.T) {
+	db := newTestDB(t)
+	svc := New(db)
+	ctx := context.Background()
+
+	device := models.Device{UserID: 3, Token: "token-a", TokenHash: tokenHash("token-a"), Platform: "ios"}
+	if err := db.Create(&device).Error; err != nil {
+		t.Fatalf("create device: %v", err)
+	}
+	if err := svc.Invalidate(ctx, "token-a", "ios", "BadDeviceToken"); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+
+	var got models.Device
+	db.First(&got, device.ID)
+	if got.UserID != 3 || got.InvalidatedAt == nil || got.InvalidReason != "BadDeviceToken" {
+		t.Fatalf("unexpected device: %+v", got)
+	}
+	if invalid, _ := svc.Invalid(ctx, "token-b"); invalid {
+		t.Fatal("other tokens should stay valid")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	device, err := svc.Register(ctx, 1, "device_token_aaa", "Android", "2.4.1")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if device.ID == 0 || device.UserID != 1 || device.Platform != "android" || device.AppVersion != "2.4.1" {
+		t.Fatalf("unexpected device: %+v", device)
+	}
+
+	for _, tt := range []struct{ token, platform string }{
+		{"short", "ios"},
+		{"device_token_bbb", "windows"},
+	} {
+		if _, err := svc.Register(ctx, 1, tt.token, tt.platform, ""); !errors.Is(err, ErrInvalidDevice) {
+			t.Errorf("%s/%s: expected ErrInvalidDevice, got %v", tt.token, tt.platform, err)
+		}
+	}
+}
+
+func TestRegister_ReactivatesAndMovesToken(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	first, err := svc.Register(ctx, 1, "device_token_aaa", "ios", "1.0")
+	if err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := svc.Invalidate(ctx, "device_token_aaa", "ios", "Unregistered"); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+
+	again, err := svc.Register(ctx, 2, "device_token_aaa", "ios", "1.1")
+	if err != nil {
+		t.Fatalf("Register again: %v", err)
+	}
+	if again.ID != first.ID || again.UserID != 2 || again.AppVersion != "1.1" || again.InvalidatedAt != nil || again.InvalidReason != "" {
+		t.Fatalf("unexpected device after re-registering: %+v", again)
+	}
+	if list, _ := svc.List(ctx, 1); len(list) != 0 {
+		t.Fatalf("expected the token to move to user 2, user 1 still has %+v", list)
+	}
+}
+
+func TestRegister_ActiveTokenOfAnotherUser(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	if _, err := svc.Register(ctx, 1, "device_token_aaa", "ios", "1.0"); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := svc.Register(ctx, 2, "device_token_aaa", "ios", "1.0"); !errors.Is(err, ErrDeviceConflict) {
+		t.Fatalf("expected ErrDeviceConflict, got %v", err)
+	}
+	if list, _ := svc.List(ctx, 1); len(list) != 1 {
+		t.Fatalf("expected the token to stay with user 1, got %+v", list)
+	}
+
+	// the owner can register it again
+	if _, err := svc.Register(ctx, 1, "device_token_aaa", "ios", "1.1"); err != nil {
+		t.Fatalf("Register again: %v", err)
+	}
+}
+
+func TestActiveDevicesAndDelete(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	android, _ := svc.Register(ctx, 1, "device_token_aaa", "android", "")
+	if _, err := svc.Register(ctx, 1, "device_token_bbb", "ios", ""); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if _, err := svc.Register(ctx, 2, "device_token_ccc", "web", ""); err != nil {
+		t.Fatalf("Register: %v", err)
+	}
+	if err := svc.Invalidate(ctx, "device_token_bbb", "ios", "BadDeviceToken"); err != nil {
+		t.Fatalf("Invalidate: %v", err)
+	}
+
+	active, err := svc.ActiveDevices(ctx, 1)
+	if err != nil {
+		t.Fatalf("ActiveDevices: %v", err)
+	}
+	if len(active) != 1 || active[0].Token != "device_token_aaa" || active[0].Platform != "android" {
+		t.Fatalf("unexpected active devices: %+v", active)
+	}
+	if list, _ := svc.List(ctx, 1); len(list) != 2 {
+		t.Fatalf("expected List to include invalidated devices, got %+v", list)
+	}
+
+	if err := svc.Delete(ctx, 2, android.ID); !errors.Is(err, ErrDeviceNotFound) {
+		t.Fatalf("expected ErrDeviceNotFound deleting another user's device, got %v", err)
+	}
+	if err := svc.Delete(ctx, 1, android.ID); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if active, _ := svc.ActiveDevices(ctx, 1); len(active) != 0 {
+		t.Fatalf("expected no active devices, got %+v", active)
+	}
+}
@@ -0,0 +1,149 @@
+package events
+
+import (
+	"sync"
+)
+
+const (
+	TypeInbox        = "inbox"
+	TypeNotification = "notification"
+
+	defaultBuffer = 64
+)
+
+// Event is pushed to the connected clients of a user. ID is only set for
+// events that can be replayed from storage, such as inbox messages
+type Event struct {
+	ID   string
+	Type string
+	Data any
+}
+
+// NotificationEvent is the data of a TypeNotification event
+type NotificationEvent struct {
+	NotificationID uint   `json:"notification_id"`
+	ChannelName    string `json:"channel_name"`
+	Status         string `json:"status"`
+}
+
+// Publisher delivers events to the connected clients of a user
+type Publisher interface {
+	Publish(userID uint, e Event)
+}
+
+// Hub fans events out to in-process subscribers. Publishing never blocks: a
+// subscriber whose buffer is full is closed, and its client is expected to
+// reconnect and resume from the last event ID it received.
+//
+// Events don't cross processes: with several API instances, a client only gets
+// the events published by the instance it is connected to. Inbox messages are
+// replayed from storage on reconnect; notification events are lost
+type Hub struct {
+	mu     sync.RWMutex
+	subs   map[uint]map[*Subscription]struct{}
+	buffer int
+}
+
+// NewHub returns a hub whose subscriptions buffer up to buffer events, 64 when zero
+func NewHub(buffer int) *Hub {
+	if buffer <= 0 {
+		buffer = defaultBuffer
+	}
+	return &Hub{subs: make(map[uint]map[*Subscription]struct{}), buffer: buffer}
+}
+
+func (h *Hub) Subscribe(userID uint) *Subscription {
+	sub := &Subscription{hub: h, userID: userID, events: make(chan Event, h.buffer)}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subs[userID] == nil {
+		h.subs[userID] = make(map[*Subscription]struct{})
+	}
+	h.subs[userID][sub] = struct{}{}
+	return sub
+}
+
+func (h *Hub) Publish(userID uint, e Event) {
+	h.mu.RLock()
+	subs := make([]*Subscription, 0, len(h.subs[userID]))
+	for sub := range h.subs[userID] {
+		subs = append(subs, sub)
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range subs {
+		if !sub.send(e) {
+			sub.Close()
+		}
+	}
+}
+
+// Close ends every open subscription, e.g. when the server shuts down
+func (h *Hub) Close() {
+	h.mu.RLock()
+	var subs []*Subscription
+	for _, userSubs := range h.subs {
+		for sub := range userSubs {
+			subs = append(subs, sub)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, sub := range subs {
+		sub.Close()
+	}
+}
+
+// Subscribers returns the number of open subscriptions of a user
+func (h *Hub) Subscribers(userID uint) int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs[userID])
+}
+
+func (h *Hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.subs[sub.userID], sub)
+	if len(h.subs[sub.userID]) == 0 {
+		delete(h.subs, sub.userID)
+	}
+}
+
+type Subscription struct {
+	hub    *Hub
+	userID uint
+	mu     sync.Mutex
+	closed bool
+	events chan Event
+}
+
+// Events is closed when the subscription is closed or falls behind
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// send reports false if the buffer is full
+func (s *Subscription) send(e Event) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return true
+	}
+	select {
+	case s.events <- e:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Subscription) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.mu.Unlock()
+	s.hub.remove(s)
+}
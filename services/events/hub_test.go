@@ -0,0 +1,71 @@
+package events
+
+import "testing"
+
+func TestHub_PublishToUser(t *testing.T) {
+	h := NewHub(4)
+	a1 := h.Subscribe(1)
+	a2 := h.Subscribe(1)
+	b := h.Subscribe(2)
+	defer b.Close()
+
+	h.Publish(1, Event{ID: "7", Type: TypeInbox})
+
+	for _, sub := range []*Subscription{a1, a2} {
+		select {
+		case e := <-sub.Events():
+			if e.ID != "7" || e.Type != TypeInbox {
+				t.Fatalf("unexpected event: %+v", e)
+			}
+		default:
+			t.Fatal("expected an event for every subscription of the user")
+		}
+	}
+	select {
+	case e := <-b.Events():
+		t.Fatalf("other users should not receive events, got %+v", e)
+	default:
+	}
+
+	a1.Close()
+	a1.Close()
+	if n := h.Subscribers(1); n != 1 {
+		t.Fatalf("expected 1 subscriber after close, got %d", n)
+	}
+	a2.Close()
+	if n := h.Subscribers(1); n != 0 {
+		t.Fatalf("expected no subscribers, got %d", n)
+	}
+	h.Publish(1, Event{Type: TypeNotification})
+}
+
+func TestHub_SlowSubscriberIsClosed(t *testing.T) {
+	h := NewHub(2)
+	sub := h.Subscribe(1)
+
+	for i := 0; i < 3; i++ {
+		h.Publish(1, Event{Type: TypeNotification})
+	}
+
+	received := 0
+	for range sub.Events() {
+		received++
+	}
+	if received != 2 {
+		t.Fatalf("expected the 2 buffered events before close, got %d", received)
+	}
+	if n := h.Subscribers(1); n != 0 {
+		t.Fatalf("slow subscriber should be removed, got %d", n)
+	}
+}
+
+func TestHub_Close(t *testing.T) {
+	h := NewHub(1)
+	subs := []*Subscription{h.Subscribe(1), h.Subscribe(2)}
+	h.Close()
+	for _, sub := range subs {
+		if _, ok := <-sub.Events(); ok {
+			t.Fatal("expected subscription to be closed")
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"notification/models"
+	"notification/models/channel"
+	"notification/services/events"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrMessageNotFound = errors.New("inbox message not found")
+
+type Service struct {
+	db     *gorm.DB
+	events events.Publisher
+}
+
+type Option func(*Service)
+
+// WithEvents publishes an inbox event to the recipient of each new message
+func WithEvents(p events.Publisher) Option {
+	return func(s *Service) { s.events = p }
+}
+
+func New(db *gorm.DB, opts ...Option) *Service {
+	s := &Service{db: db}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Deliver stores msg in the user's inbox. Delivering the same notification
+// twice keeps the first message, so outbox retries are safe
+func (s *Service) Deliver(ctx context.Context, userID uint, msg channel.Message) error {
+	m := models.InboxMessage{
+		UserID:         userID,
+		NotificationID: msg.NotificationID,
+		Title:          msg.Title,
+		Content:        msg.Content,
+		Data:           msg.Meta["data"],
+	}
+	res := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&m)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected > 0 && s.events != nil {
+		s.events.Publish(userID, Event(m))
+	}
+	return nil
+}
+
+// Event returns the stream event of an inbox message. Its ID is the message
+// ID, so clients can resume with Since
+func Event(m models.InboxMessage) events.Event {
+	return events.Event{ID: strconv.FormatUint(uint64(m.ID), 10), Type: events.TypeInbox, Data: m}
+}
+
+// Since returns up to limit of the user's messages created after the message afterID, oldest first
+func (s *Service) Since(ctx context.Context, userID, afterID uint, limit int) ([]models.InboxMessage, error) {
+	list := []models.InboxMessage{}
+	err := s.db.WithContext(ctx).
+		Where("user_id = ? AND id > ?", userID, afterID).
+		Order("id ASC").
+		Limit(limit).
+		Find(&list).Error
+	return list, err
+}
+
+// List returns the user's messages, newest first
+func (s *Service) List(ctx context.Context, userID uint, unreadOnly bool, limit, offset int) ([]models.InboxMessage, error) {
+	list := []models.InboxMessage{}
+	q := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC, id DESC")
+	if unreadOnly {
+		q = q.Where("read_at IS NULL")
+	}
+	if limit > 0 {
+		q = q.Limit(limit)
+	}
+	if offset > 0 {
+		q = q.Offset(offset)
+	}
+	if err := q.Find(&list).Error; err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+func (s *Service) UnreadCount(ctx context.Context, userID uint) (int64, error) {
+	var count int64
+	err := s.db.WithContext(ctx).Model(&models.InboxMessage{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+// MarkRead marks one of the user's messages as read. Marking a read message again keeps its original read time
+func (s *Service) MarkRead(ctx context.Context, userID, id uint) error {
+	var m models.InboxMessage
+	if err := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&m).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrMessageNotFound
+		}
+		return err
+	}
+	if m.ReadAt != nil {
+		return nil
+	}
+	return s.db.WithContext(ctx).Model(&m).Update("read_at", time.Now()).Error
+}
+
+// MarkReadByNotification marks the user's message for a notification as read, if there is one
+func (s *Service) MarkReadByNotification(ctx context.Context, userID, notificationID uint) error {
+	return s.db.WithContext(ctx).Model(&models.InboxMessage{}).
+		Where("user_id = ? AND notification_id = ? AND read_at IS NULL", userID, notificationID).
+		Update("read_at", time.Now()).Error
+}
+
+// MarkAllRead marks every unread message of the user as read and returns how many were updated
+func (s *Service) MarkAllRead(ctx context.Context, userID uint) (int64, error) {
+	res := s.db.WithContext(ctx).Model(&models.InboxMessage{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", time.Now())
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,161 @@
+package inbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"notification/models"
+	"notification/models/channel"
+	"notification/services/events"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	db, err := gorm.Open(dsn, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.InboxMessage{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func deliver(t *testing.T, svc *Service, userID, notificationID uint, title string) {
+	t.Helper()
+	msg := channel.Message{Title: title, Content: "c", NotificationID: notificationID, Meta: map[string]string{"data": `{"k":"v"}`}}
+	if err := svc.Deliver(context.Background(), userID, msg); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+}
+
+func TestDeliver_Idempotent(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	deliver(t, svc, 1, 10, "first")
+	deliver(t, svc, 1, 10, "retry")
+
+	list, err := svc.List(ctx, 1, false, 0, 0)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 1 || list[0].Title != "first" || list[0].Data != `{"k":"v"}` {
+		t.Fatalf("unexpected inbox: %+v", list)
+	}
+}
+
+func TestReadState(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	deliver(t, svc, 1, 10, "a")
+	deliver(t, svc, 1, 11, "b")
+	deliver(t, svc, 1, 12, "c")
+	deliver(t, svc, 2, 13, "other user")
+
+	if n, _ := svc.UnreadCount(ctx, 1); n != 3 {
+		t.Fatalf("expected 3 unread, got %d", n)
+	}
+
+	list, _ := svc.List(ctx, 1, false, 0, 0)
+	if err := svc.MarkRead(ctx, 1, list[0].ID); err != nil {
+		t.Fatalf("MarkRead: %v", err)
+	}
+	if err := svc.MarkRead(ctx, 1, list[0].ID); err != nil {
+		t.Fatalf("MarkRead again: %v", err)
+	}
+	if n, _ := svc.UnreadCount(ctx, 1); n != 2 {
+		t.Fatalf("expected 2 unread, got %d", n)
+	}
+	unread, _ := svc.List(ctx, 1, true, 0, 0)
+	if len(unread) != 2 {
+		t.Fatalf("expected 2 unread messages, got %d", len(unread))
+	}
+
+	others, _ := svc.List(ctx, 2, false, 0, 0)
+	if err := svc.MarkRead(ctx, 1, others[0].ID); !errors.Is(err, ErrMessageNotFound) {
+		t.Fatalf("expected ErrMessageNotFound for another user's message, got %v", err)
+	}
+
+	n, err := svc.MarkAllRead(ctx, 1)
+	if err != nil || n != 2 {
+		t.Fatalf("MarkAllRead = %d, %v", n, err)
+	}
+	if n, _ := svc.UnreadCount(ctx, 1); n != 0 {
+		t.Fatalf("expected no unread, got %d", n)
+	}
+	if n, _ := svc.UnreadCount(ctx, 2); n != 1 {
+		t.Fatalf("other users should be unaffected, got %d unread", n)
+	}
+}
+
+type recordingPublisher struct {
+	userIDs []uint
+	events  []events.Event
+}
+
+func (r *recordingPublisher) Publish(userID uint, e events.Event) {
+	r.userIDs = append(r.userIDs, userID)
+	r.events = append(r.events, e)
+}
+
+func TestDeliver_PublishesNewMessages(t *testing.T) {
+	pub := &recordingPublisher{}
+	svc := New(newTestDB(t), WithEvents(pub))
+
+	deliver(t, svc, 1, 10, "a")
+	deliver(t, svc, 1, 10, "a")
+
+	if len(pub.events) != 1 || pub.userIDs[0] != 1 {
+		t.Fatalf("expected a single event for user 1, got %+v", pub.events)
+	}
+	m, ok := pub.events[0].Data.(models.InboxMessage)
+	if !ok || pub.events[0].Type != events.TypeInbox || pub.events[0].ID != fmt.Sprint(m.ID) {
+		t.Fatalf("unexpected event: %+v", pub.events[0])
+	}
+}
+
+func TestSince(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	deliver(t, svc, 1, 10, "a")
+	deliver(t, svc, 2, 11, "other user")
+	deliver(t, svc, 1, 12, "b")
+	deliver(t, svc, 1, 13, "c")
+
+	all, _ := svc.Since(ctx, 1, 0, 10)
+	if len(all) != 3 || all[0].Title != "a" || all[2].Title != "c" {
+		t.Fatalf("unexpected messages: %+v", all)
+	}
+	rest, err := svc.Since(ctx, 1, all[0].ID, 1)
+	if err != nil || len(rest) != 1 || rest[0].Title != "b" {
+		t.Fatalf("unexpected messages after %d: %+v, %v", all[0].ID, rest, err)
+	}
+}
+
+func TestMarkReadByNotification(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	deliver(t, svc, 1, 10, "a")
+	deliver(t, svc, 1, 11, "b")
+
+	if err := svc.MarkReadByNotification(ctx, 1, 10); err != nil {
+		t.Fatalf("MarkReadByNotification: %v", err)
+	}
+	if err := svc.MarkReadByNotification(ctx, 2, 11); err != nil {
+		t.Fatalf("MarkReadByNotification for another user: %v", err)
+	}
+	unread, _ := svc.List(ctx, 1, true, 0, 0)
+	if len(unread) != 1 || unread[0].NotificationID != 11 {
+		t.Fatalf("unexpected unread messages: %+v", unread)
+	}
+}
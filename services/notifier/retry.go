@@ -0,0 +1,78 @@
+package notifier
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RetryPolicy decides how often and how quickly a failed delivery is retried
+type RetryPolicy struct {
+	// MaxAttempts is the number of sends before the delivery is moved to DEAD_LETTER
+	MaxAttempts int
+	// BaseDelay is the delay after the first failure; it doubles with every
+	// further failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// maxReschedules rate limits in a row count as a failed attempt, so a provider
+// that keeps answering 429 doesn't hold a delivery forever
+const maxReschedules = 10
+
+// DefaultRetryPolicy applies to channels without a policy of their own
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: time.Hour}
+
+// Backoff returns the delay before retrying after the given failed attempt,
+// counted from 1. Half of the exponential delay is fixed and the other half
+// random, so deliveries that failed together don't all retry at once
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	delay = min(delay, p.MaxDelay)
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+func (p RetryPolicy) validate() error {
+	if p.MaxAttempts < 1 {
+		return fmt.Errorf("max attempts must be at least 1")
+	}
+	if p.BaseDelay <= 0 || p.MaxDelay < p.BaseDelay {
+		return fmt.Errorf("delays must be positive with the base delay not above the max delay")
+	}
+	return nil
+}
+
+// ParseRetryPolicies reads comma-separated channel:max_attempts:base_delay:max_delay
+// entries, e.g. "webhook:8:30s:6h,sms:3:1m:30m". The channel * sets the default policy
+func ParseRetryPolicies(s string) (map[string]RetryPolicy, error) {
+	policies := make(map[string]RetryPolicy)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) != 4 {
+			return nil, fmt.Errorf("retry policy %q: expected channel:max_attempts:base_delay:max_delay", entry)
+		}
+		var p RetryPolicy
+		var errAttempts, errBase, errMax error
+		p.MaxAttempts, errAttempts = strconv.Atoi(parts[1])
+		p.BaseDelay, errBase = time.ParseDuration(parts[2])
+		p.MaxDelay, errMax = time.ParseDuration(parts[3])
+		if errAttempts != nil || errBase != nil || errMax != nil {
+			return nil, fmt.Errorf("retry policy %q: invalid number or duration", entry)
+		}
+		if err := p.validate(); err != nil {
+			return nil, fmt.Errorf("retry policy %q: %v", entry, err)
+		}
+		policies[parts[0]] = p
+	}
+	return policies, nil
+}
@@ -0,0 +1,54 @@
+package notifier
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 10, BaseDelay: 10 * time.Second, MaxDelay: time.Minute}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, 10 * time.Second},
+		{2, 20 * time.Second},
+		{3, 40 * time.Second},
+		{4, time.Minute},
+		{60, time.Minute},
+	}
+	for _, tt := range tests {
+		for range 20 {
+			got := p.Backoff(tt.attempt)
+			if got < tt.want/2 || got > tt.want {
+				t.Fatalf("attempt %d: expected a delay between %s and %s, got %s", tt.attempt, tt.want/2, tt.want, got)
+			}
+		}
+	}
+}
+
+func TestParseRetryPolicies(t *testing.T) {
+	policies, err := ParseRetryPolicies("webhook:8:30s:6h, *:3:1m:10m")
+	if err != nil {
+		t.Fatalf("ParseRetryPolicies: %v", err)
+	}
+	if got := policies["webhook"]; got != (RetryPolicy{MaxAttempts: 8, BaseDelay: 30 * time.Second, MaxDelay: 6 * time.Hour}) {
+		t.Fatalf("unexpected webhook policy %+v", got)
+	}
+	svc := NewNotifierService(nil, nil, WithRetryPolicies(policies))
+	if got := svc.retryPolicy("sms"); got.MaxAttempts != 3 {
+		t.Fatalf("expected the * policy for other channels, got %+v", got)
+	}
+	if got := NewNotifierService(nil, nil).retryPolicy("sms"); got != DefaultRetryPolicy {
+		t.Fatalf("expected the default policy, got %+v", got)
+	}
+
+	if policies, err := ParseRetryPolicies(""); err != nil || len(policies) != 0 {
+		t.Fatalf("expected no policies, got %v, %v", policies, err)
+	}
+	for _, s := range []string{"webhook:8:30s", "webhook:0:30s:1h", "webhook:3:1h:1m", "webhook:x:1s:1m", "webhook:3:soon:1m"} {
+		if _, err := ParseRetryPolicies(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
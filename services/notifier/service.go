@@ -9,6 +9,10 @@ import (
 	"fmt"
 	"notification/models"
 	"notification/models/channel"
+	"notification/services/categories"
+	"notification/services/events"
+	"strconv"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -21,6 +25,13 @@ var (
 	ErrNotificationNotFound       = errors.New("notification not found")
 	ErrFailedToUpdateNotification = errors.New("failed to update notification")
 	ErrFailedToUpdateOutbox       = errors.New("failed to update outbox")
+	ErrInvalidVariables           = errors.New("invalid template variables")
+	ErrRenderFailed               = errors.New("failed to render notification")
+	ErrInvalidAck                 = errors.New("invalid acknowledgement")
+	ErrInvalidRecipient           = errors.New("recipient user not found")
+	ErrInvalidCategory            = errors.New("invalid category")
+	ErrInvalidPriority            = errors.New("invalid priority")
+	ErrRecipientNotAllowed        = errors.New("not allowed to notify this user")
 )
 
 type NotificationRequest struct {
@@ -28,11 +39,28 @@ type NotificationRequest struct {
 	Content     string            `json:"content"`
 	ChannelName string            `json:"channel_name"`
 	Meta        map[string]string `json:"meta"`
-	UserID      uint              `json:"user_id"`
-	ScheduledAt *time.Time        `json:"scheduled_at,omitempty"`
+	Variables   map[string]string `json:"variables,omitempty"`
+	// StrictVariables rejects the request if title, content or subject reference a missing variable
+	StrictVariables bool `json:"strict_variables,omitempty"`
+	UserID          uint `json:"user_id"`
+	// RecipientUserID targets a user whose contact profile supplies the address
+	RecipientUserID uint `json:"recipient_user_id,omitempty"`
+	// Category is the kind of notification recipients set preferences for
+	Category string `json:"category,omitempty"`
+	// Priority defaults to the priority of the category
+	Priority    models.Priority `json:"priority,omitempty"`
+	ScheduledAt *time.Time      `json:"scheduled_at,omitempty"`
+}
+
+// ListFilter narrows ListNotifications; empty fields match everything
+type ListFilter struct {
+	Category string
+	Priority models.Priority
 }
 
 type UpdateNotificationRequest struct {
+	// UserID is the user making the update, who must be allowed to address the user in meta
+	UserID      uint              `json:"-"`
 	Title       string            `json:"title"`
 	Content     string            `json:"content"`
 	Meta        map[string]string `json:"meta"`
@@ -52,13 +80,61 @@ type outboxUpdates struct {
 	ScheduledAt   time.Time
 }
 
+// Preferences decides whether a user opted out of notifications of a category
+// on a channel. An empty reason means the notification may be sent
+type Preferences interface {
+	Suppressed(ctx context.Context, userID uint, channelName, category string) (reason string, err error)
+}
+
 type NotifierService struct {
 	db          *gorm.DB
 	channelList map[string]channel.Channel
+	events      events.Publisher
+	preferences Preferences
+	categories  *categories.Registry
+	templates   channel.TemplateSource
+	// retryPolicies are keyed by channel name, "*" holds the default
+	retryPolicies map[string]RetryPolicy
+}
+
+type Option func(*NotifierService)
+
+// WithEvents publishes a notification event to the creator of each notification once it is sent
+func WithEvents(p events.Publisher) Option {
+	return func(s *NotifierService) { s.events = p }
+}
+
+// WithPreferences skips notifications the recipient opted out of, when they are
+// created and again when they are dispatched
+func WithPreferences(p Preferences) Option {
+	return func(s *NotifierService) { s.preferences = p }
+}
+
+// WithCategories restricts notifications to the categories of a registry and
+// fills in their default priority. Without it any category is accepted
+func WithCategories(r *categories.Registry) Option {
+	return func(s *NotifierService) { s.categories = r }
+}
+
+// WithTemplates records the current version of the template named in
+// meta.template when a notification is created, so it's sent with that version
+// even if the template changes before dispatch
+func WithTemplates(src channel.TemplateSource) Option {
+	return func(s *NotifierService) { s.templates = src }
 }
 
-func NewNotifierService(db *gorm.DB, channelList map[string]channel.Channel) *NotifierService {
-	return &NotifierService{db: db, channelList: channelList}
+// WithRetryPolicies sets the retry policy of each channel; the "*" policy applies
+// to the others and defaults to DefaultRetryPolicy
+func WithRetryPolicies(policies map[string]RetryPolicy) Option {
+	return func(s *NotifierService) { s.retryPolicies = policies }
+}
+
+func NewNotifierService(db *gorm.DB, channelList map[string]channel.Channel, opts ...Option) *NotifierService {
+	s := &NotifierService{db: db, channelList: channelList}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 func generateIdempotencyKey(notificationRequest NotificationRequest) (string, error) {
@@ -69,7 +145,9 @@ func generateIdempotencyKey(notificationRequest NotificationRequest) (string, er
 		Title       string            `json:"title"`
 		Content     string            `json:"content"`
 		Meta        map[string]string `json:"meta"`
-	}{notificationRequest.UserID, notificationRequest.ChannelName, notificationRequest.Title, notificationRequest.Content, notificationRequest.Meta}
+		Variables   map[string]string `json:"variables,omitempty"`
+		Category    string            `json:"category,omitempty"`
+	}{notificationRequest.UserID, notificationRequest.ChannelName, notificationRequest.Title, notificationRequest.Content, notificationRequest.Meta, notificationRequest.Variables, notificationRequest.Category}
 
 	b, err := json.Marshal(payload)
 	if err != nil {
@@ -81,30 +159,61 @@ func generateIdempotencyKey(notificationRequest NotificationRequest) (string, er
 }
 
 func (s *NotifierService) CreateAndEnqueue(ctx context.Context, notificationRequest NotificationRequest) error {
+	if err := s.checkMetaUser(ctx, notificationRequest.UserID, notificationRequest.Meta); err != nil {
+		return err
+	}
+	if err := s.addRecipient(ctx, &notificationRequest); err != nil {
+		return err
+	}
 	idempotencyKey, err := generateIdempotencyKey(notificationRequest)
 	if err != nil {
 		return err
 	}
+	if err := s.applyCategory(&notificationRequest); err != nil {
+		return err
+	}
+	templateVersion, err := s.pinTemplate(ctx, notificationRequest.ChannelName, &notificationRequest.Meta)
+	if err != nil {
+		return err
+	}
+	skipReason, err := s.suppressed(ctx, notificationRequest.ChannelName, newMessage(notificationRequest))
+	if err != nil {
+		return err
+	}
+	recipientUserID, err := s.recipientUser(notificationRequest.ChannelName, notificationRequest.Meta, notificationRequest.UserID)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMetadata, err)
+	}
 	notification := models.Notification{
-		Title:          notificationRequest.Title,
-		Content:        notificationRequest.Content,
-		ChannelName:    notificationRequest.ChannelName,
-		IdempotencyKey: idempotencyKey,
-		UserID:         notificationRequest.UserID,
+		Title:           notificationRequest.Title,
+		Content:         notificationRequest.Content,
+		ChannelName:     notificationRequest.ChannelName,
+		Category:        notificationRequest.Category,
+		Priority:        notificationRequest.Priority,
+		IdempotencyKey:  idempotencyKey,
+		UserID:          notificationRequest.UserID,
+		RecipientUserID: recipientUserID,
+		TemplateVersion: templateVersion,
 	}
 
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		// Validate channel before creating the notification
-		channel, ok := s.channelList[notificationRequest.ChannelName]
+		ch, ok := s.channelList[notificationRequest.ChannelName]
 		if !ok {
 			return fmt.Errorf("%w: %s", ErrInvalidChannel, notificationRequest.ChannelName)
 		}
 
 		// Validate channel metadata
-		if err := channel.Validate(notificationRequest.Meta); err != nil {
+		if err := validateMeta(ch, notificationRequest.UserID, notificationRequest.Meta); err != nil {
 			return fmt.Errorf("%w: %v", ErrInvalidMetadata, err)
 		}
 
+		if notificationRequest.StrictVariables {
+			if err := checkVariables(notificationRequest); err != nil {
+				return fmt.Errorf("%w: %v", ErrInvalidVariables, err)
+			}
+		}
+
 		// Create notification
 		err := tx.Create(&notification).Error
 		if err != nil {
@@ -112,14 +221,14 @@ func (s *NotifierService) CreateAndEnqueue(ctx context.Context, notificationRequ
 			return err
 		}
 
-		payloadBody := struct {
-			Title   string            `json:"title"`
-			Content string            `json:"content"`
-			Meta    map[string]string `json:"meta"`
-		}{
-			Title:   notification.Title,
-			Content: notification.Content,
-			Meta:    notificationRequest.Meta,
+		payloadBody := channel.Message{
+			Title:     notification.Title,
+			Content:   notification.Content,
+			Meta:      notificationRequest.Meta,
+			Variables: notificationRequest.Variables,
+			Category:  notification.Category,
+			Priority:  string(notification.Priority),
+			UserID:    notification.UserID,
 		}
 
 		payload, err := json.Marshal(payloadBody)
@@ -141,7 +250,13 @@ func (s *NotifierService) CreateAndEnqueue(ctx context.Context, notificationRequ
 			LastError:      "",
 			NextAttemptAt:  scheduledAt,
 			ScheduledAt:    scheduledAt,
-			MaxAttempts:    3,
+			MaxAttempts:    s.retryPolicy(notificationRequest.ChannelName).MaxAttempts,
+			Priority:       notification.Priority.Rank(),
+		}
+		if skipReason != "" {
+			// recorded rather than rejected, so the sender can see why it wasn't delivered
+			outbox.Status = models.SKIPPED
+			outbox.SkipReason = skipReason
 		}
 
 		if err := tx.Create(&outbox).Error; err != nil {
@@ -152,40 +267,446 @@ func (s *NotifierService) CreateAndEnqueue(ctx context.Context, notificationRequ
 	})
 }
 
+// addRecipient checks that the caller may address the recipient and that it
+// exists, and passes it to the channel in meta, so the channel accepts a request
+// without an address and resolves it from the contact profile
+func (s *NotifierService) addRecipient(ctx context.Context, notificationRequest *NotificationRequest) error {
+	if notificationRequest.RecipientUserID == 0 {
+		return nil
+	}
+	if err := s.checkAddressable(ctx, notificationRequest.UserID, notificationRequest.RecipientUserID); err != nil {
+		return err
+	}
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ?", notificationRequest.RecipientUserID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidRecipient, notificationRequest.RecipientUserID)
+	}
+	notificationRequest.Meta = withRecipient(notificationRequest.Meta, notificationRequest.RecipientUserID)
+	return nil
+}
+
+// pinTemplate records in meta the version of the template named in
+// meta.template that is current now, unless meta already names a version, and
+// returns it. It's 0 when there is no stored template for the channel
+func (s *NotifierService) pinTemplate(ctx context.Context, channelName string, meta *map[string]string) (int, error) {
+	name := (*meta)["template"]
+	if s.templates == nil || name == "" {
+		return 0, nil
+	}
+	version, err := channel.TemplateVersion(*meta)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidMetadata, err)
+	}
+	tmpl, err := s.templates.Lookup(ctx, name, version, channelName)
+	if errors.Is(err, channel.ErrTemplateNotFound) {
+		if version > 0 {
+			return 0, fmt.Errorf("%w: template %s has no version %d for %s", ErrInvalidMetadata, name, version, channelName)
+		}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	pinned := make(map[string]string, len(*meta)+1)
+	for k, v := range *meta {
+		pinned[k] = v
+	}
+	pinned[channel.MetaTemplateVersion] = strconv.Itoa(tmpl.Version)
+	*meta = pinned
+	return tmpl.Version, nil
+}
+
+// checkMetaUser checks that the user making a request may address the user in
+// meta.user_id, which push and in-app deliver to directly
+func (s *NotifierService) checkMetaUser(ctx context.Context, callerID uint, meta map[string]string) error {
+	id, err := strconv.ParseUint(meta["user_id"], 10, 64)
+	if err != nil {
+		// missing or malformed, which the channel rejects
+		return nil
+	}
+	return s.checkAddressable(ctx, callerID, uint(id))
+}
+
+// checkAddressable lets users notify themselves, and admins notify anyone
+func (s *NotifierService) checkAddressable(ctx context.Context, callerID, userID uint) error {
+	if userID == callerID {
+		return nil
+	}
+	var admins int64
+	if err := s.db.WithContext(ctx).Model(&models.User{}).Where("id = ? AND is_admin = ?", callerID, true).Count(&admins).Error; err != nil {
+		return err
+	}
+	if admins == 0 {
+		return fmt.Errorf("%w: %d", ErrRecipientNotAllowed, userID)
+	}
+	return nil
+}
+
+// applyCategory checks the category and priority of a request against the
+// registry, defaulting them to the Default category and its priority
+func (s *NotifierService) applyCategory(notificationRequest *NotificationRequest) error {
+	if notificationRequest.Priority != "" && !notificationRequest.Priority.Valid() {
+		return fmt.Errorf("%w: %q, expected low, normal or high", ErrInvalidPriority, notificationRequest.Priority)
+	}
+	if s.categories == nil {
+		if notificationRequest.Priority == "" {
+			notificationRequest.Priority = models.PriorityNormal
+		}
+		return nil
+	}
+	category, err := s.categories.Get(notificationRequest.Category)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidCategory, err)
+	}
+	notificationRequest.Category = category.Name
+	if notificationRequest.Priority == "" {
+		notificationRequest.Priority = category.DefaultPriority
+	}
+	return nil
+}
+
+// suppressed returns why the user a message is for opted out of it, or "" when
+// it may be sent. That user is the recipient or the user addressed in meta; a
+// message to a raw address isn't for a known user, so the creator's own
+// preferences don't apply to it
+func (s *NotifierService) suppressed(ctx context.Context, channelName string, msg channel.Message) (string, error) {
+	if s.preferences == nil {
+		return "", nil
+	}
+	userID, err := recipientOf(msg.Meta)
+	if err != nil || userID == 0 {
+		return "", err
+	}
+	return s.preferences.Suppressed(ctx, userID, channelName, msg.Category)
+}
+
+// recipientOf returns the user a message is addressed to: the recipient_user_id,
+// else the user_id push and in-app deliver to, or 0 for a raw address
+func recipientOf(meta map[string]string) (uint, error) {
+	userID, err := channel.RecipientUserID(meta)
+	if err != nil || userID != 0 {
+		return userID, err
+	}
+	if id, err := strconv.ParseUint(meta["user_id"], 10, 64); err == nil {
+		return uint(id), nil
+	}
+	return 0, nil
+}
+
+// recipientUser returns the user a notification is delivered to, who gets its
+// events and acknowledges it: the recipient in meta, else the creator on a
+// channel that delivers to its creator, or 0 for a raw address
+func (s *NotifierService) recipientUser(channelName string, meta map[string]string, creatorID uint) (uint, error) {
+	userID, err := recipientOf(meta)
+	if err != nil || userID != 0 {
+		return userID, err
+	}
+	if ch, ok := s.channelList[channelName].(channel.CreatorDefault); ok && ch.DeliversToCreator() {
+		return creatorID, nil
+	}
+	return 0, nil
+}
+
+// withRecipient returns a copy of meta with the recipient set
+func withRecipient(meta map[string]string, recipientUserID uint) map[string]string {
+	copied := make(map[string]string, len(meta)+1)
+	for k, v := range meta {
+		copied[k] = v
+	}
+	copied[channel.MetaRecipientUserID] = strconv.FormatUint(uint64(recipientUserID), 10)
+	return copied
+}
+
+// checkVariables renders the request in strict mode without modifying it
+func checkVariables(notificationRequest NotificationRequest) error {
+	msg := newMessage(notificationRequest)
+	return msg.RenderVariables(true)
+}
+
+// newMessage builds the message a request is dispatched as, with its own copy of meta
+func newMessage(notificationRequest NotificationRequest) channel.Message {
+	meta := make(map[string]string, len(notificationRequest.Meta))
+	for k, v := range notificationRequest.Meta {
+		meta[k] = v
+	}
+	return channel.Message{
+		Title:     notificationRequest.Title,
+		Content:   notificationRequest.Content,
+		Meta:      meta,
+		Variables: notificationRequest.Variables,
+		Category:  notificationRequest.Category,
+		Priority:  string(notificationRequest.Priority),
+		UserID:    notificationRequest.UserID,
+	}
+}
+
+// Preview validates and prepares a request like CreateAndEnqueue and DispatchOutbox
+// would, and returns what the channel would send. Nothing is persisted.
+func (s *NotifierService) Preview(ctx context.Context, notificationRequest NotificationRequest) (channel.Preview, error) {
+	if err := s.checkMetaUser(ctx, notificationRequest.UserID, notificationRequest.Meta); err != nil {
+		return channel.Preview{}, err
+	}
+	if err := s.addRecipient(ctx, &notificationRequest); err != nil {
+		return channel.Preview{}, err
+	}
+	if err := s.applyCategory(&notificationRequest); err != nil {
+		return channel.Preview{}, err
+	}
+	ch, ok := s.channelList[notificationRequest.ChannelName]
+	if !ok {
+		return channel.Preview{}, fmt.Errorf("%w: %s", ErrInvalidChannel, notificationRequest.ChannelName)
+	}
+	if err := validateMeta(ch, notificationRequest.UserID, notificationRequest.Meta); err != nil {
+		return channel.Preview{}, fmt.Errorf("%w: %v", ErrInvalidMetadata, err)
+	}
+	if notificationRequest.StrictVariables {
+		if err := checkVariables(notificationRequest); err != nil {
+			return channel.Preview{}, fmt.Errorf("%w: %v", ErrInvalidVariables, err)
+		}
+	}
+
+	msg := newMessage(notificationRequest)
+	if err := ch.Prepare(ctx, &msg); err != nil {
+		return channel.Preview{}, fmt.Errorf("%w: %v", ErrRenderFailed, err)
+	}
+
+	previewer, ok := ch.(channel.Previewer)
+	if !ok {
+		return redact(channel.Preview{Subject: msg.Title, Text: msg.Content}, msg.Resolved), nil
+	}
+	preview, err := previewer.Preview(ctx, msg)
+	if err != nil {
+		return channel.Preview{}, fmt.Errorf("%w: %v", ErrRenderFailed, err)
+	}
+	return redact(preview, msg.Resolved), nil
+}
+
+// redact hides the addresses resolved from a contact profile wherever a
+// template rendered them into a preview
+func redact(preview channel.Preview, addresses []string) channel.Preview {
+	if len(addresses) == 0 {
+		return preview
+	}
+	pairs := make([]string, 0, 2*len(addresses))
+	for _, address := range addresses {
+		pairs = append(pairs, address, "[redacted]")
+	}
+	r := strings.NewReplacer(pairs...)
+	preview.Subject = r.Replace(preview.Subject)
+	preview.Text = r.Replace(preview.Text)
+	preview.HTML = r.Replace(preview.HTML)
+	if preview.Payload != nil {
+		preview.Payload = []byte(r.Replace(string(preview.Payload)))
+	}
+	return preview
+}
+
 func (s *NotifierService) DispatchOutbox(ctx context.Context, outbox models.Outbox) error {
 	var message channel.Message
 	err := json.Unmarshal([]byte(outbox.PayloadJson), &message)
 	if err != nil {
-		return fmt.Errorf("internal error")
+		return s.fail(ctx, outbox, 0, fmt.Errorf("invalid payload: %w", err))
 	}
+	message.NotificationID = outbox.NotificationID
 
-	channel, ok := s.channelList[outbox.ChannelName]
+	// events go to the user who can acknowledge the notification; the meta was
+	// checked when the notification was created
+	recipientUserID, _ := s.recipientUser(outbox.ChannelName, message.Meta, message.UserID)
+
+	ch, ok := s.channelList[outbox.ChannelName]
 	if !ok {
-		return fmt.Errorf("channel %s not found", outbox.ChannelName)
+		return s.fail(ctx, outbox, recipientUserID, fmt.Errorf("channel %s not found", outbox.ChannelName))
 	}
 
-	err = channel.Send(ctx, message)
+	// preferences may have changed since the notification was created
+	skipReason, err := s.suppressed(ctx, outbox.ChannelName, message)
 	if err != nil {
-		return err
+		return s.retry(ctx, outbox, recipientUserID, err)
+	}
+	if skipReason != "" {
+		return s.skip(ctx, outbox, recipientUserID, skipReason)
 	}
 
-	err = s.db.Transaction(func(tx *gorm.DB) error {
-		res := tx.Model(&models.Outbox{}).
-			Where("id=? AND status=?", outbox.ID, models.PROCESSING).
-			Update("status", models.SENT).
-			Update("updated_at", time.Now())
+	err = ch.Prepare(ctx, &message)
+	var permanent *channel.PermanentError
+	if errors.As(err, &permanent) {
+		return s.fail(ctx, outbox, recipientUserID, permanent)
+	}
+	if err != nil {
+		return s.retry(ctx, outbox, recipientUserID, err)
+	}
+
+	receipt := &channel.Receipt{}
+	err = ch.Send(channel.WithReceipt(ctx, receipt), message)
+	var retryAfter *channel.RetryAfterError
+	if errors.As(err, &retryAfter) {
+		return s.reschedule(ctx, outbox, recipientUserID, retryAfter)
+	}
+	if errors.As(err, &permanent) {
+		return s.fail(ctx, outbox, recipientUserID, permanent)
+	}
+	if err != nil {
+		return s.retry(ctx, outbox, recipientUserID, err)
+	}
+
+	res := claimed(s.db.WithContext(ctx), outbox).
+		Updates(released(map[string]any{
+			"status":              models.SENT,
+			"provider":            receipt.Provider,
+			"provider_message_id": receipt.ProviderMessageID,
+			"segments":            receipt.Segments,
+			"updated_at":          time.Now(),
+		}))
+	if res.Error != nil {
+		return res.Error
+	}
+	// another worker took the row back and owns its outcome
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("record sent outbox %d: %w", outbox.ID, errLeaseLost)
+	}
+	if s.events != nil && recipientUserID != 0 {
+		s.events.Publish(recipientUserID, events.Event{
+			Type: events.TypeNotification,
+			Data: events.NotificationEvent{NotificationID: outbox.NotificationID, ChannelName: outbox.ChannelName, Status: string(models.SENT)},
+		})
+	}
+	return nil
+}
+
+// reschedule returns a claimed row to PENDING when the provider asked to be
+// retried later, waiting at least the base delay of the channel. It doesn't
+// count as a failed attempt until maxReschedules rate limits in a row
+func (s *NotifierService) reschedule(ctx context.Context, outbox models.Outbox, userID uint, retryAfter *channel.RetryAfterError) error {
+	if outbox.Reschedules+1 >= maxReschedules {
+		return s.retry(ctx, outbox, userID, retryAfter)
+	}
+	delay := max(retryAfter.After, s.retryPolicy(outbox.ChannelName).BaseDelay)
+	res := claimed(s.db.WithContext(ctx), outbox).
+		Updates(released(map[string]any{
+			"status":          models.PENDING,
+			"reschedules":     outbox.Reschedules + 1,
+			"next_attempt_at": time.Now().Add(delay),
+			"last_error":      retryAfter.Error(),
+			"updated_at":      time.Now(),
+		}))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("reschedule outbox %d: %w", outbox.ID, errLeaseLost)
+	}
+	return nil
+}
+
+// retryPolicy returns the retry policy of a channel
+func (s *NotifierService) retryPolicy(channelName string) RetryPolicy {
+	if p, ok := s.retryPolicies[channelName]; ok {
+		return p
+	}
+	if p, ok := s.retryPolicies["*"]; ok {
+		return p
+	}
+	return DefaultRetryPolicy
+}
+
+// retry returns a failed delivery to PENDING after an exponential backoff, or
+// moves it to DEAD_LETTER once it used up its attempts
+func (s *NotifierService) retry(ctx context.Context, outbox models.Outbox, userID uint, cause error) error {
+	policy := s.retryPolicy(outbox.ChannelName)
+	maxAttempts := outbox.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = policy.MaxAttempts
+	}
+	attempts := outbox.Attempts + 1
+	if attempts >= maxAttempts {
+		return s.finish(ctx, outbox, userID, models.DEAD_LETTER, cause, map[string]any{"attempts": attempts, "reschedules": 0})
+	}
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := claimed(tx, outbox).
+			Updates(released(map[string]any{
+				"status":          models.PENDING,
+				"attempts":        attempts,
+				"reschedules":     0,
+				"next_attempt_at": time.Now().Add(policy.Backoff(attempts)),
+				"last_error":      cause.Error(),
+				"updated_at":      time.Now(),
+			}))
 		if res.Error != nil {
-			err = tx.Model(&models.Outbox{}).
-				Where("id = ?", outbox.ID).
-				Update("attempts", outbox.Attempts+1).
-				Update("next_attempt_at", time.Now().Add(time.Minute)).
-				Update("last_error", res.Error.Error()).
-				Error
 			return res.Error
 		}
-		return nil
+		// another worker took the row back and owns its outcome
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("retry outbox %d: %w", outbox.ID, errLeaseLost)
+		}
+		return recordError(tx, outbox.ID, attempts, cause)
+	})
+}
+
+// fail marks a delivery that can never succeed as FAILED
+func (s *NotifierService) fail(ctx context.Context, outbox models.Outbox, userID uint, cause error) error {
+	return s.finish(ctx, outbox, userID, models.FAILED, cause, map[string]any{"attempts": outbox.Attempts + 1})
+}
+
+// skip marks a delivery the recipient opted out of as SKIPPED
+func (s *NotifierService) skip(ctx context.Context, outbox models.Outbox, userID uint, reason string) error {
+	return s.finish(ctx, outbox, userID, models.SKIPPED, nil, map[string]any{"skip_reason": reason})
+}
+
+// finish moves a claimed row to a final status, adds cause to its error history
+// and tells the recipient
+func (s *NotifierService) finish(ctx context.Context, outbox models.Outbox, userID uint, status models.Status, cause error, updates map[string]any) error {
+	updates["status"] = status
+	updates["updated_at"] = time.Now()
+	if cause != nil {
+		updates["last_error"] = cause.Error()
+	}
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		res := claimed(tx, outbox).Updates(released(updates))
+		if res.Error != nil {
+			return res.Error
+		}
+		// another worker took the row back and owns its outcome
+		if res.RowsAffected == 0 {
+			return fmt.Errorf("finish outbox %d: %w", outbox.ID, errLeaseLost)
+		}
+		if cause == nil {
+			return nil
+		}
+		return recordError(tx, outbox.ID, outbox.Attempts+1, cause)
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	if s.events != nil && userID != 0 {
+		s.events.Publish(userID, events.Event{
+			Type: events.TypeNotification,
+			Data: events.NotificationEvent{NotificationID: outbox.NotificationID, ChannelName: outbox.ChannelName, Status: string(status)},
+		})
+	}
+	return nil
+}
+
+// claimed selects an outbox row as long as it's still PROCESSING under the lease
+// it was dispatched with, so a worker whose lease was taken back doesn't
+// overwrite the outcome of the worker that took it
+func claimed(tx *gorm.DB, outbox models.Outbox) *gorm.DB {
+	return tx.Model(&models.Outbox{}).
+		Where("id = ? AND status = ? AND lease_owner = ?", outbox.ID, models.PROCESSING, outbox.LeaseOwner)
+}
+
+// released adds clearing the lease to the updates of a row leaving PROCESSING
+func released(updates map[string]any) map[string]any {
+	updates["lease_owner"] = ""
+	updates["lease_expires_at"] = nil
+	return updates
+}
+
+// recordError adds a failed attempt to the error history of an outbox row
+func recordError(tx *gorm.DB, outboxID uint, attempt int, cause error) error {
+	return tx.Create(&models.DeliveryError{OutboxID: outboxID, Attempt: attempt, Error: cause.Error()}).Error
 }
 
 func (s *NotifierService) GetNotification(ctx context.Context, id int) (*models.Notification, error) {
@@ -199,11 +720,15 @@ func (s *NotifierService) GetNotification(ctx context.Context, id int) (*models.
 	return &n, nil
 }
 
-func (s *NotifierService) ListNotifications(ctx context.Context, userID int, limit, offset int) ([]models.Notification, error) {
+// ListNotifications returns the notifications a user created, newest first
+func (s *NotifierService) ListNotifications(ctx context.Context, userID uint, filter ListFilter, limit, offset int) ([]models.Notification, error) {
 	var list []models.Notification
-	q := s.db.WithContext(ctx).Order("created_at DESC")
-	if userID > 0 {
-		q = q.Where("user_id = ?", userID)
+	q := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC")
+	if filter.Category != "" {
+		q = q.Where("category = ?", filter.Category)
+	}
+	if filter.Priority != "" {
+		q = q.Where("priority = ?", filter.Priority)
 	}
 	if limit > 0 {
 		q = q.Limit(limit)
@@ -235,11 +760,27 @@ func (s *NotifierService) UpdateNotification(ctx context.Context, id int, patch
 		newContent = patch.Content
 	}
 
-	// Validate meta (if provided) against channel
+	// Validate meta (if provided) against channel, keeping the recipient unless
+	// the patch addresses another user
+	recipientUserID, templateVersion := notification.RecipientUserID, notification.TemplateVersion
+	if patch.Meta != nil && recipientUserID != 0 && patch.Meta["user_id"] == "" {
+		patch.Meta = withRecipient(patch.Meta, recipientUserID)
+	}
 	if patch.Meta != nil {
-		if !s.hasValidMeta(notification.ChannelName, patch.Meta) {
+		if err := s.checkMetaUser(ctx, patch.UserID, patch.Meta); err != nil {
+			return err
+		}
+		// attachments are read as the creator's when the notification is sent
+		if !s.hasValidMeta(notification.ChannelName, notification.UserID, patch.Meta) {
 			return fmt.Errorf("%w: %s", ErrInvalidMetadata, notification.ChannelName)
 		}
+		if recipientUserID, err = s.recipientUser(notification.ChannelName, patch.Meta, notification.UserID); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidMetadata, err)
+		}
+		// new meta is sent with the template as it is now
+		if templateVersion, err = s.pinTemplate(ctx, notification.ChannelName, &patch.Meta); err != nil {
+			return err
+		}
 	}
 
 	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
@@ -250,10 +791,34 @@ func (s *NotifierService) UpdateNotification(ctx context.Context, id int, patch
 				return ErrFailedToUpdateNotification
 			}
 		}
+		if recipientUserID != notification.RecipientUserID || templateVersion != notification.TemplateVersion {
+			if err := tx.Model(&models.Notification{}).Where("id = ?", id).Updates(map[string]any{
+				"recipient_user_id": recipientUserID,
+				"template_version":  templateVersion,
+			}).Error; err != nil {
+				return ErrFailedToUpdateNotification
+			}
+		}
 
 		// If meta or scheduledAt provided, refresh Outbox snapshot for PENDING jobs
 		if patch.Meta != nil || patch.ScheduledAt != nil {
-			msg := channel.Message{Title: newTitle, Content: newContent, Meta: patch.Meta}
+			// Start from the current snapshot so fields outside the patch (meta, variables) are kept
+			var current models.Outbox
+			if err := tx.Where("notification_id = ? AND status = ?", notification.ID, models.PENDING).First(&current).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil
+				}
+				return ErrFailedToUpdateOutbox
+			}
+			var msg channel.Message
+			if err := json.Unmarshal([]byte(current.PayloadJson), &msg); err != nil {
+				return ErrFailedToUpdateOutbox
+			}
+			msg.Title, msg.Content = newTitle, newContent
+			if patch.Meta != nil {
+				msg.Meta = patch.Meta
+			}
+
 			payload, err := json.Marshal(msg)
 			if err != nil {
 				return err
@@ -283,6 +848,37 @@ func (s *NotifierService) UpdateNotification(ctx context.Context, id int, patch
 	})
 }
 
+// Acknowledgement statuses sent by clients
+const (
+	AckDelivered = "delivered"
+	AckRead      = "read"
+)
+
+// Acknowledge records that a notification addressed to the user reached one of
+// their clients or was read there. Reading implies delivery; repeated acks keep the first time
+func (s *NotifierService) Acknowledge(ctx context.Context, userID, notificationID uint, status string) error {
+	now := time.Now()
+	updates := map[string]any{"delivered_at": gorm.Expr("COALESCE(delivered_at, ?)", now)}
+	switch status {
+	case AckDelivered:
+	case AckRead:
+		updates["read_at"] = gorm.Expr("COALESCE(read_at, ?)", now)
+	default:
+		return fmt.Errorf("%w: unknown status %q", ErrInvalidAck, status)
+	}
+
+	res := s.db.WithContext(ctx).Model(&models.Notification{}).
+		Where("id = ? AND recipient_user_id = ?", notificationID, userID).
+		Updates(updates)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNotificationNotFound
+	}
+	return nil
+}
+
 func (s *NotifierService) DeleteNotification(ctx context.Context, id int) error {
 	result := s.db.WithContext(ctx).Model(&models.Notification{}).
 		Where("id = ?", id).
@@ -305,6 +901,18 @@ func (s *NotifierService) getChannel(channelName string) channel.Channel {
 	return channel
 }
 
-func (s *NotifierService) hasValidMeta(channelName string, meta map[string]string) bool {
-	return s.getChannel(channelName).Validate(meta) == nil
+func (s *NotifierService) hasValidMeta(channelName string, userID uint, meta map[string]string) bool {
+	return validateMeta(s.getChannel(channelName), userID, meta) == nil
+}
+
+// validateMeta checks meta against the channel, including what only userID
+// may reference
+func validateMeta(ch channel.Channel, userID uint, meta map[string]string) error {
+	if err := ch.Validate(meta); err != nil {
+		return err
+	}
+	if v, ok := ch.(channel.UserValidator); ok {
+		return v.ValidateUser(userID, meta)
+	}
+	return nil
 }
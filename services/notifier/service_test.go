@@ -2,14 +2,20 @@ package notifier
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"notification/models"
 	"notification/models/channel"
+	"notification/services/categories"
+	"notification/services/events"
 
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
@@ -19,12 +25,27 @@ type fakeChannel struct {
 	validateErr error
 	sendErr     error
 	prepareErr  error
+	sent        []channel.Message
+	// receipt is reported to the dispatcher on every send
+	receipt channel.Receipt
+	// resolved is the address Prepare looks up for the recipient
+	resolved string
 }
 
-func (f *fakeChannel) Name() string                                            { return f.name }
-func (f *fakeChannel) Validate(meta map[string]string) error                   { return f.validateErr }
-func (f *fakeChannel) Send(ctx context.Context, msg channel.Message) error     { return f.sendErr }
-func (f *fakeChannel) Prepare(ctx context.Context, msg *channel.Message) error { return f.prepareErr }
+func (f *fakeChannel) Name() string                          { return f.name }
+func (f *fakeChannel) Validate(meta map[string]string) error { return f.validateErr }
+func (f *fakeChannel) Send(ctx context.Context, msg channel.Message) error {
+	f.sent = append(f.sent, msg)
+	*channel.ReceiptFrom(ctx) = f.receipt
+	return f.sendErr
+}
+func (f *fakeChannel) DeliversToCreator() bool { return f.name == "inapp" }
+func (f *fakeChannel) Prepare(ctx context.Context, msg *channel.Message) error {
+	if f.resolved != "" {
+		msg.Resolved = append(msg.Resolved, f.resolved)
+	}
+	return f.prepareErr
+}
 
 func newTestDB(t *testing.T) *gorm.DB {
 	t.Helper()
@@ -33,12 +54,32 @@ func newTestDB(t *testing.T) *gorm.DB {
 	if err != nil {
 		t.Fatalf("open db: %v", err)
 	}
-	if err := db.AutoMigrate(&models.Notification{}, &models.Outbox{}); err != nil {
+	if err := db.AutoMigrate(&models.Notification{}, &models.Outbox{}, &models.User{}, &models.DeliveryError{}); err != nil {
 		t.Fatalf("migrate: %v", err)
 	}
 	return db
 }
 
+// seedUser creates a user, an admin when admin is set
+func seedUser(t *testing.T, db *gorm.DB, id uint, admin bool) {
+	t.Helper()
+	u := models.User{Name: fmt.Sprintf("user %d", id), Email: fmt.Sprintf("user%d@example.com", id), Password: "x", IsAdmin: admin}
+	u.ID = id
+	if err := db.Create(&u).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+}
+
+// optOuts suppresses the channel/category pairs it holds for every user
+type optOuts map[string]bool
+
+func (o optOuts) Suppressed(ctx context.Context, userID uint, channelName, category string) (string, error) {
+	if o[channelName+"/"+category] {
+		return fmt.Sprintf("user %d opted out", userID), nil
+	}
+	return "", nil
+}
+
 func TestCreateAndEnqueue_OK(t *testing.T) {
 	db := newTestDB(t)
 	svc := NewNotifierService(db, map[string]channel.Channel{
@@ -87,8 +128,9 @@ func TestCreateAndEnqueue_InvalidMeta(t *testing.T) {
 
 func TestDispatchOutbox_OK(t *testing.T) {
 	db := newTestDB(t)
+	email := &fakeChannel{name: "email"}
 	svc := NewNotifierService(db, map[string]channel.Channel{
-		"email": &fakeChannel{name: "email"},
+		"email": email,
 	})
 	// seed
 	n := models.Notification{Title: "t", Content: "c", ChannelName: "email"}
@@ -103,4 +145,1220 @@ func TestDispatchOutbox_OK(t *testing.T) {
 	if err := svc.DispatchOutbox(context.Background(), o); err != nil {
 		t.Fatalf("DispatchOutbox: %v", err)
 	}
+	if len(email.sent) != 1 || email.sent[0].NotificationID != n.ID {
+		t.Fatalf("expected message for notification %d, got %+v", n.ID, email.sent)
+	}
+}
+
+func TestCreateAndEnqueue_StrictVariables(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"email": &fakeChannel{name: "email"},
+	})
+	ctx := context.Background()
+
+	req := NotificationRequest{Title: "Hi {{.name}}", Content: "Order {{.order_id}}", ChannelName: "email", Variables: map[string]string{"name": "Ana"}, StrictVariables: true, UserID: 9}
+	if err := svc.CreateAndEnqueue(ctx, req); !errors.Is(err, ErrInvalidVariables) {
+		t.Fatalf("expected ErrInvalidVariables, got %v", err)
+	}
+
+	req.Variables["order_id"] = "42"
+	if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+		t.Fatalf("CreateAndEnqueue: %v", err)
+	}
+	var o models.Outbox
+	if err := db.First(&o).Error; err != nil {
+		t.Fatalf("find outbox: %v", err)
+	}
+	var msg channel.Message
+	if err := json.Unmarshal([]byte(o.PayloadJson), &msg); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	if msg.Title != "Hi {{.name}}" || msg.Variables["order_id"] != "42" || msg.UserID != 9 {
+		t.Fatalf("payload should keep the raw title and the variables: %+v", msg)
+	}
+}
+
+func TestCreateAndEnqueue_RecipientUserID(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"email": &fakeChannel{name: "email"},
+	})
+	ctx := context.Background()
+	seedUser(t, db, 1, true)
+
+	req := NotificationRequest{Title: "t", Content: "c", ChannelName: "email", UserID: 1, RecipientUserID: 5}
+	if err := svc.CreateAndEnqueue(ctx, req); !errors.Is(err, ErrInvalidRecipient) {
+		t.Fatalf("expected ErrInvalidRecipient, got %v", err)
+	}
+
+	user := models.User{Name: "Ana", Email: "ana@example.com", Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	req.RecipientUserID = user.ID
+	if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+		t.Fatalf("CreateAndEnqueue: %v", err)
+	}
+	var n models.Notification
+	if err := db.First(&n).Error; err != nil {
+		t.Fatalf("find notification: %v", err)
+	}
+	if n.RecipientUserID != user.ID {
+		t.Fatalf("expected recipient %d, got %d", user.ID, n.RecipientUserID)
+	}
+	var o models.Outbox
+	if err := db.First(&o).Error; err != nil {
+		t.Fatalf("find outbox: %v", err)
+	}
+	var msg channel.Message
+	if err := json.Unmarshal([]byte(o.PayloadJson), &msg); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	if msg.Meta[channel.MetaRecipientUserID] != fmt.Sprint(user.ID) {
+		t.Fatalf("expected the recipient in meta, got %v", msg.Meta)
+	}
+
+	other := NotificationRequest{Title: "t", Content: "c", ChannelName: "email", UserID: user.ID, RecipientUserID: 1}
+	if err := svc.CreateAndEnqueue(ctx, other); !errors.Is(err, ErrRecipientNotAllowed) {
+		t.Fatalf("expected ErrRecipientNotAllowed, got %v", err)
+	}
+	if _, err := svc.Preview(ctx, other); !errors.Is(err, ErrRecipientNotAllowed) {
+		t.Fatalf("expected ErrRecipientNotAllowed from preview, got %v", err)
+	}
+	other.RecipientUserID = user.ID
+	if _, err := svc.Preview(ctx, other); err != nil {
+		t.Fatalf("users may preview messages to themselves: %v", err)
+	}
+}
+
+func TestPreview_RedactsResolvedAddress(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"email": &fakeChannel{name: "email", resolved: "user2@example.com"},
+	})
+	seedUser(t, db, 1, true)
+	seedUser(t, db, 2, false)
+
+	req := NotificationRequest{Title: "Sent to user2@example.com", Content: "Reply to user2@example.com", ChannelName: "email", UserID: 1, RecipientUserID: 2}
+	preview, err := svc.Preview(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if preview.Subject != "Sent to [redacted]" || preview.Text != "Reply to [redacted]" {
+		t.Fatalf("expected the address to be redacted, got %+v", preview)
+	}
+}
+
+// latestTemplates serves every template at a fixed latest version
+type latestTemplates int
+
+func (v latestTemplates) Lookup(ctx context.Context, name string, version int, channelName string) (channel.Template, error) {
+	if name != "welcome" || version > int(v) {
+		return channel.Template{}, channel.ErrTemplateNotFound
+	}
+	if version == 0 {
+		version = int(v)
+	}
+	return channel.Template{Name: name, Version: version}, nil
+}
+
+func TestCreateAndEnqueue_PinsTemplateVersion(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"email": &fakeChannel{name: "email"},
+	}, WithTemplates(latestTemplates(3)))
+	ctx := context.Background()
+
+	req := NotificationRequest{Title: "t", Content: "c", ChannelName: "email", Meta: map[string]string{"to": "a@example.com", "template": "welcome"}}
+	if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+		t.Fatalf("CreateAndEnqueue: %v", err)
+	}
+	if _, ok := req.Meta[channel.MetaTemplateVersion]; ok {
+		t.Fatal("request meta should not be modified")
+	}
+	var n models.Notification
+	db.First(&n)
+	if n.TemplateVersion != 3 {
+		t.Fatalf("expected template version 3, got %d", n.TemplateVersion)
+	}
+	var o models.Outbox
+	db.First(&o)
+	var msg channel.Message
+	if err := json.Unmarshal([]byte(o.PayloadJson), &msg); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	if msg.Meta[channel.MetaTemplateVersion] != "3" {
+		t.Fatalf("expected the version to be recorded in meta, got %v", msg.Meta)
+	}
+
+	req.Title = "other"
+	req.Meta = map[string]string{"to": "a@example.com", "template": "welcome", channel.MetaTemplateVersion: "7"}
+	if err := svc.CreateAndEnqueue(ctx, req); !errors.Is(err, ErrInvalidMetadata) {
+		t.Fatalf("expected ErrInvalidMetadata for a missing version, got %v", err)
+	}
+}
+
+func TestUpdateNotification_KeepsVariables(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"email": &fakeChannel{name: "email"},
+	})
+	ctx := context.Background()
+
+	req := NotificationRequest{Title: "Hi {{.name}}", Content: "c", ChannelName: "email", Meta: map[string]string{"to": "a@example.com"}, Variables: map[string]string{"name": "Ana"}}
+	if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+		t.Fatalf("CreateAndEnqueue: %v", err)
+	}
+	var n models.Notification
+	db.First(&n)
+
+	later := time.Now().Add(time.Hour)
+	if err := svc.UpdateNotification(ctx, int(n.ID), UpdateNotificationRequest{Content: "new", ScheduledAt: &later}); err != nil {
+		t.Fatalf("UpdateNotification: %v", err)
+	}
+
+	var o models.Outbox
+	db.First(&o)
+	var msg channel.Message
+	if err := json.Unmarshal([]byte(o.PayloadJson), &msg); err != nil {
+		t.Fatalf("unmarshal payload: %v", err)
+	}
+	if msg.Content != "new" || msg.Variables["name"] != "Ana" || msg.Meta["to"] != "a@example.com" {
+		t.Fatalf("unexpected payload after update: %+v", msg)
+	}
+}
+
+func TestPreview_DoesNotPersist(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"email": &fakeChannel{name: "email"},
+	})
+	ctx := context.Background()
+
+	meta := map[string]string{"subject": "Order {{.order_id}}"}
+	req := NotificationRequest{Title: "Hi {{.name}}", Content: "c", ChannelName: "email", Meta: meta, Variables: map[string]string{"name": "Ana"}}
+	preview, err := svc.Preview(ctx, req)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if preview.Subject != "Hi {{.name}}" || preview.Text != "c" {
+		t.Fatalf("unexpected preview: %+v", preview)
+	}
+	if meta["subject"] != "Order {{.order_id}}" {
+		t.Fatalf("request meta should not be modified, got %q", meta["subject"])
+	}
+
+	var count int64
+	db.Model(&models.Notification{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no notifications, got %d", count)
+	}
+	db.Model(&models.Outbox{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no outbox rows, got %d", count)
+	}
+}
+
+func TestPreview_Errors(t *testing.T) {
+	svc := NewNotifierService(newTestDB(t), map[string]channel.Channel{
+		"email": &fakeChannel{name: "email", validateErr: errors.New("bad meta")},
+		"sms":   &fakeChannel{name: "sms", prepareErr: errors.New("template error")},
+	})
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		req  NotificationRequest
+		want error
+	}{
+		{"unknown channel", NotificationRequest{ChannelName: "fax"}, ErrInvalidChannel},
+		{"invalid meta", NotificationRequest{ChannelName: "email"}, ErrInvalidMetadata},
+		{"missing variable", NotificationRequest{ChannelName: "sms", Title: "{{.name}}", StrictVariables: true}, ErrInvalidVariables},
+		{"prepare failure", NotificationRequest{ChannelName: "sms"}, ErrRenderFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.Preview(ctx, tt.req); !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestDispatchOutbox_RetryAfterReschedules(t *testing.T) {
+	db := newTestDB(t)
+	rateLimited := &channel.RetryAfterError{After: time.Hour, Err: errors.New("rate limited")}
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"chat": &fakeChannel{name: "chat", sendErr: rateLimited},
+	})
+	ctx := context.Background()
+
+	o := models.Outbox{NotificationID: 1, ChannelName: "chat", PayloadJson: `{"title":"t"}`, Status: models.PROCESSING, Attempts: 1, MaxAttempts: 3}
+	if err := db.Create(&o).Error; err != nil {
+		t.Fatalf("create outbox: %v", err)
+	}
+	if err := svc.DispatchOutbox(ctx, o); err != nil {
+		t.Fatalf("DispatchOutbox: %v", err)
+	}
+
+	var got models.Outbox
+	db.First(&got, o.ID)
+	if got.Status != models.PENDING || got.Attempts != 1 || got.LastError == "" {
+		t.Fatalf("unexpected outbox after rate limit: %+v", got)
+	}
+	if time.Until(got.NextAttemptAt) < 59*time.Minute {
+		t.Fatalf("expected next attempt in about an hour, got %s", got.NextAttemptAt)
+	}
+}
+
+func TestDispatchOutbox_RetryAfterClampedAndCapped(t *testing.T) {
+	db := newTestDB(t)
+	rateLimited := &channel.RetryAfterError{After: 0, Err: errors.New("rate limited")}
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"chat": &fakeChannel{name: "chat", sendErr: rateLimited},
+	}, WithRetryPolicies(map[string]RetryPolicy{"chat": {MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}}))
+	ctx := context.Background()
+
+	o := models.Outbox{NotificationID: 1, ChannelName: "chat", PayloadJson: `{"title":"t"}`, Status: models.PROCESSING, MaxAttempts: 3}
+	if err := db.Create(&o).Error; err != nil {
+		t.Fatalf("create outbox: %v", err)
+	}
+	for i := 1; i < maxReschedules; i++ {
+		if err := svc.DispatchOutbox(ctx, o); err != nil {
+			t.Fatalf("DispatchOutbox: %v", err)
+		}
+		db.First(&o, o.ID)
+		if o.Status != models.PENDING || o.Attempts != 0 || o.Reschedules != i {
+			t.Fatalf("reschedule %d: unexpected outbox %+v", i, o)
+		}
+		// a Retry-After of 0 still waits the base delay
+		if time.Until(o.NextAttemptAt) < 59*time.Second {
+			t.Fatalf("reschedule %d: expected the base delay, got %s", i, o.NextAttemptAt)
+		}
+		db.Model(&o).Update("status", models.PROCESSING)
+		db.First(&o, o.ID)
+	}
+
+	// too many rate limits in a row use an attempt
+	if err := svc.DispatchOutbox(ctx, o); err != nil {
+		t.Fatalf("DispatchOutbox: %v", err)
+	}
+	db.First(&o, o.ID)
+	if o.Status != models.PENDING || o.Attempts != 1 || o.Reschedules != 0 {
+		t.Fatalf("expected the rate limit to count as an attempt, got %+v", o)
+	}
+}
+
+func TestDispatchOutbox_SendErrorRetries(t *testing.T) {
+	db := newTestDB(t)
+	pub := &recordingPublisher{}
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"sms": &fakeChannel{name: "sms", sendErr: errors.New("provider unavailable")},
+	}, WithEvents(pub), WithRetryPolicies(map[string]RetryPolicy{"sms": {MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: time.Hour}}))
+	ctx := context.Background()
+	seedUser(t, db, 7, false)
+
+	if err := svc.CreateAndEnqueue(ctx, NotificationRequest{Title: "t", ChannelName: "sms", UserID: 7, Meta: map[string]string{"recipient_user_id": "7"}}); err != nil {
+		t.Fatalf("CreateAndEnqueue: %v", err)
+	}
+	var o models.Outbox
+	db.First(&o)
+	if o.MaxAttempts != 3 {
+		t.Fatalf("expected max attempts from the channel policy, got %d", o.MaxAttempts)
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		db.Model(&o).Update("status", models.PROCESSING)
+		db.First(&o, o.ID)
+		if err := svc.DispatchOutbox(ctx, o); err != nil {
+			t.Fatalf("DispatchOutbox: %v", err)
+		}
+		db.First(&o, o.ID)
+		if o.Status != models.PENDING || o.Attempts != attempt || o.LastError != "provider unavailable" {
+			t.Fatalf("attempt %d: expected a retry, got %+v", attempt, o)
+		}
+		// 1m, then 2m, with up to half of it jittered away
+		delay := time.Duration(attempt) * time.Minute
+		if wait := time.Until(o.NextAttemptAt); wait < delay/2-time.Second || wait > delay {
+			t.Fatalf("attempt %d: unexpected backoff %s", attempt, wait)
+		}
+	}
+
+	db.Model(&o).Update("status", models.PROCESSING)
+	db.First(&o, o.ID)
+	if err := svc.DispatchOutbox(ctx, o); err != nil {
+		t.Fatalf("DispatchOutbox: %v", err)
+	}
+	db.First(&o, o.ID)
+	if o.Status != models.DEAD_LETTER || o.Attempts != 3 {
+		t.Fatalf("expected DEAD_LETTER after the last attempt, got %+v", o)
+	}
+	if len(pub.events) != 1 || pub.userIDs[0] != 7 || pub.events[0].Data.(events.NotificationEvent).Status != string(models.DEAD_LETTER) {
+		t.Fatalf("expected one DEAD_LETTER event, got %+v", pub.events)
+	}
+	var history []models.DeliveryError
+	db.Where("outbox_id = ?", o.ID).Order("attempt").Find(&history)
+	if len(history) != 3 || history[2].Attempt != 3 || history[2].Error != "provider unavailable" {
+		t.Fatalf("expected an error per attempt, got %+v", history)
+	}
+}
+
+func TestDispatchOutbox_PrepareErrorRetries(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"email": &fakeChannel{name: "email", prepareErr: errors.New("template store unavailable")},
+	})
+	o := models.Outbox{NotificationID: 1, ChannelName: "email", PayloadJson: `{"title":"t"}`, Status: models.PROCESSING, MaxAttempts: 3}
+	db.Create(&o)
+	if err := svc.DispatchOutbox(context.Background(), o); err != nil {
+		t.Fatalf("DispatchOutbox: %v", err)
+	}
+	db.First(&o, o.ID)
+	if o.Status != models.PENDING || o.Attempts != 1 || o.LastError != "template store unavailable" {
+		t.Fatalf("expected a retry, got %+v", o)
+	}
+}
+
+func TestDispatchOutbox_UnknownChannelFails(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{})
+	o := models.Outbox{NotificationID: 1, ChannelName: "fax", PayloadJson: `{"title":"t"}`, Status: models.PROCESSING, MaxAttempts: 3}
+	db.Create(&o)
+	if err := svc.DispatchOutbox(context.Background(), o); err != nil {
+		t.Fatalf("DispatchOutbox: %v", err)
+	}
+	db.First(&o, o.ID)
+	if o.Status != models.FAILED {
+		t.Fatalf("expected FAILED, got %+v", o)
+	}
+}
+
+func TestDispatchOutbox_PermanentErrorFails(t *testing.T) {
+	db := newTestDB(t)
+	pub := &recordingPublisher{}
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"push": &fakeChannel{name: "push", sendErr: channel.Permanent(errors.New("token unregistered"))},
+	}, WithEvents(pub))
+	ctx := context.Background()
+
+	o := models.Outbox{NotificationID: 1, ChannelName: "push", PayloadJson: `{"title":"t","meta":{"user_id":"7"},"user_id":1}`, Status: models.PROCESSING, Attempts: 0, MaxAttempts: 3}
+	if err := db.Create(&o).Error; err != nil {
+		t.Fatalf("create outbox: %v", err)
+	}
+	if err := svc.DispatchOutbox(ctx, o); err != nil {
+		t.Fatalf("DispatchOutbox: %v", err)
+	}
+
+	var got models.Outbox
+	db.First(&got, o.ID)
+	if got.Status != models.FAILED || got.Attempts != 1 || got.LastError != "token unregistered" {
+		t.Fatalf("unexpected outbox after permanent error: %+v", got)
+	}
+	if len(pub.events) != 1 || pub.userIDs[0] != 7 || pub.events[0].Data.(events.NotificationEvent).Status != string(models.FAILED) {
+		t.Fatalf("expected a FAILED event for the device owner, got %+v", pub.events)
+	}
+}
+
+func TestDispatchOutbox_LostLeaseAfterSend(t *testing.T) {
+	db := newTestDB(t)
+	pub := &recordingPublisher{}
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"email": &fakeChannel{name: "email"},
+	}, WithEvents(pub))
+
+	o := models.Outbox{NotificationID: 4, ChannelName: "email", PayloadJson: `{"title":"t","user_id":7}`, Status: models.PROCESSING, LeaseOwner: "b"}
+	if err := db.Create(&o).Error; err != nil {
+		t.Fatalf("seed outbox: %v", err)
+	}
+	// the row was taken back by worker b while worker a was sending
+	o.LeaseOwner = "a"
+	if err := svc.DispatchOutbox(context.Background(), o); !errors.Is(err, errLeaseLost) {
+		t.Fatalf("expected errLeaseLost, got %v", err)
+	}
+
+	var got models.Outbox
+	db.First(&got, o.ID)
+	if got.Status != models.PROCESSING || got.LeaseOwner != "b" {
+		t.Fatalf("the new owner's row should be untouched, got %+v", got)
+	}
+	if len(pub.events) != 0 {
+		t.Fatalf("expected no event, got %+v", pub.events)
+	}
+}
+
+func TestDispatchOutbox_LostLeaseOnFailure(t *testing.T) {
+	db := newTestDB(t)
+	pub := &recordingPublisher{}
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"sms":  &fakeChannel{name: "sms", sendErr: errors.New("provider unavailable")},
+		"push": &fakeChannel{name: "push", sendErr: channel.Permanent(errors.New("token unregistered"))},
+	}, WithEvents(pub))
+
+	for _, channelName := range []string{"sms", "push"} {
+		o := models.Outbox{NotificationID: 4, ChannelName: channelName, PayloadJson: `{"title":"t","meta":{"user_id":"7"}}`, Status: models.PROCESSING, LeaseOwner: "b", MaxAttempts: 3}
+		if err := db.Create(&o).Error; err != nil {
+			t.Fatalf("seed outbox: %v", err)
+		}
+		// the row was taken back by worker b while worker a was sending
+		o.LeaseOwner = "a"
+		if err := svc.DispatchOutbox(context.Background(), o); !errors.Is(err, errLeaseLost) {
+			t.Fatalf("%s: expected errLeaseLost, got %v", channelName, err)
+		}
+
+		var got models.Outbox
+		db.First(&got, o.ID)
+		if got.Status != models.PROCESSING || got.LeaseOwner != "b" || got.Attempts != 0 {
+			t.Fatalf("%s: the new owner's row should be untouched, got %+v", channelName, got)
+		}
+	}
+	var history int64
+	db.Model(&models.DeliveryError{}).Count(&history)
+	if history != 0 || len(pub.events) != 0 {
+		t.Fatalf("expected no error history and no events, got %d errors and %+v", history, pub.events)
+	}
+}
+
+type recordingPublisher struct {
+	userIDs []uint
+	events  []events.Event
+}
+
+func (r *recordingPublisher) Publish(userID uint, e events.Event) {
+	r.userIDs = append(r.userIDs, userID)
+	r.events = append(r.events, e)
+}
+
+func TestDispatchOutbox_PublishesEvent(t *testing.T) {
+	db := newTestDB(t)
+	pub := &recordingPublisher{}
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"email": &fakeChannel{name: "email"},
+	}, WithEvents(pub))
+
+	// created by user 1 for user 7
+	o := models.Outbox{NotificationID: 4, ChannelName: "email", PayloadJson: `{"title":"t","meta":{"recipient_user_id":"7"},"user_id":1}`, Status: models.PROCESSING}
+	if err := db.Create(&o).Error; err != nil {
+		t.Fatalf("seed outbox: %v", err)
+	}
+	if err := svc.DispatchOutbox(context.Background(), o); err != nil {
+		t.Fatalf("DispatchOutbox: %v", err)
+	}
+
+	if len(pub.events) != 1 || pub.userIDs[0] != 7 {
+		t.Fatalf("expected one event for the recipient, got %+v", pub.userIDs)
+	}
+	data, ok := pub.events[0].Data.(events.NotificationEvent)
+	if !ok || data.NotificationID != 4 || data.Status != string(models.SENT) {
+		t.Fatalf("unexpected event: %+v", pub.events[0])
+	}
+}
+
+func TestDispatchOutbox_EventCanBeAcknowledged(t *testing.T) {
+	db := newTestDB(t)
+	pub := &recordingPublisher{}
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"email": &fakeChannel{name: "email"},
+		"inapp": &fakeChannel{name: "inapp"},
+	}, WithEvents(pub))
+	ctx := context.Background()
+	seedUser(t, db, 1, true)
+	seedUser(t, db, 2, false)
+
+	dispatch := func(req NotificationRequest) {
+		t.Helper()
+		if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+			t.Fatalf("CreateAndEnqueue: %v", err)
+		}
+		var o models.Outbox
+		db.Order("id DESC").First(&o)
+		db.Model(&o).Update("status", models.PROCESSING)
+		db.First(&o, o.ID)
+		if err := svc.DispatchOutbox(ctx, o); err != nil {
+			t.Fatalf("DispatchOutbox: %v", err)
+		}
+	}
+
+	// admin 1 emails user 2, who is the one to hear about it and ack it
+	dispatch(NotificationRequest{Title: "for 2", ChannelName: "email", UserID: 1, Meta: map[string]string{"recipient_user_id": "2"}})
+	if len(pub.events) != 1 || pub.userIDs[0] != 2 {
+		t.Fatalf("expected one event for the recipient, got %v", pub.userIDs)
+	}
+	data := pub.events[0].Data.(events.NotificationEvent)
+	if err := svc.Acknowledge(ctx, pub.userIDs[0], data.NotificationID, AckRead); err != nil {
+		t.Fatalf("expected the recipient to ack the event's notification, got %v", err)
+	}
+
+	// the creator's own inbox
+	dispatch(NotificationRequest{Title: "mine", ChannelName: "inapp", UserID: 1})
+	if len(pub.events) != 2 || pub.userIDs[1] != 1 {
+		t.Fatalf("expected an event for the creator, got %v", pub.userIDs)
+	}
+	data = pub.events[1].Data.(events.NotificationEvent)
+	if err := svc.Acknowledge(ctx, pub.userIDs[1], data.NotificationID, AckRead); err != nil {
+		t.Fatalf("expected the creator to ack the event's notification, got %v", err)
+	}
+
+	// nobody can ack a raw address, so nobody is told about it
+	dispatch(NotificationRequest{Title: "raw", ChannelName: "email", UserID: 1, Meta: map[string]string{"to": "ops@example.com"}})
+	if len(pub.events) != 2 {
+		t.Fatalf("expected no event for a raw address, got %v", pub.userIDs)
+	}
+}
+
+func TestAcknowledge(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{"inapp": &fakeChannel{name: "inapp"}})
+	ctx := context.Background()
+
+	// created by admin 1 for the inbox of user 2
+	seedUser(t, db, 1, true)
+	if err := svc.CreateAndEnqueue(ctx, NotificationRequest{Title: "t", ChannelName: "inapp", UserID: 1, Meta: map[string]string{"user_id": "2"}}); err != nil {
+		t.Fatalf("CreateAndEnqueue: %v", err)
+	}
+	var n models.Notification
+	db.First(&n)
+	if n.RecipientUserID != 2 {
+		t.Fatalf("expected the addressed user to be the recipient, got %d", n.RecipientUserID)
+	}
+
+	if err := svc.Acknowledge(ctx, 1, n.ID, AckDelivered); !errors.Is(err, ErrNotificationNotFound) {
+		t.Fatalf("expected ErrNotificationNotFound for the creator, got %v", err)
+	}
+	if err := svc.Acknowledge(ctx, 2, n.ID, AckDelivered); err != nil {
+		t.Fatalf("Acknowledge delivered: %v", err)
+	}
+	var got models.Notification
+	db.First(&got, n.ID)
+	if got.DeliveredAt == nil || got.ReadAt != nil {
+		t.Fatalf("expected only delivered_at, got %+v", got)
+	}
+	delivered := *got.DeliveredAt
+
+	if err := svc.Acknowledge(ctx, 2, n.ID, AckRead); err != nil {
+		t.Fatalf("Acknowledge read: %v", err)
+	}
+	db.First(&got, n.ID)
+	if got.ReadAt == nil || !got.DeliveredAt.Equal(delivered) {
+		t.Fatalf("expected read_at and the original delivered_at, got %+v", got)
+	}
+
+	if err := svc.Acknowledge(ctx, 3, n.ID, AckRead); !errors.Is(err, ErrNotificationNotFound) {
+		t.Fatalf("expected ErrNotificationNotFound for another user, got %v", err)
+	}
+	if err := svc.Acknowledge(ctx, 2, n.ID, "seen"); !errors.Is(err, ErrInvalidAck) {
+		t.Fatalf("expected ErrInvalidAck, got %v", err)
+	}
+
+	// in-app notifications without a user_id go to the creator's own inbox
+	if err := svc.CreateAndEnqueue(ctx, NotificationRequest{Title: "mine", ChannelName: "inapp", UserID: 1}); err != nil {
+		t.Fatalf("CreateAndEnqueue: %v", err)
+	}
+	var own models.Notification
+	db.Where("title = ?", "mine").First(&own)
+	if err := svc.Acknowledge(ctx, 1, own.ID, AckRead); err != nil {
+		t.Fatalf("expected the creator to ack their own inbox message, got %v", err)
+	}
+}
+
+func TestCreateAndEnqueue_MetaUserNeedsAdmin(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{"inapp": &fakeChannel{name: "inapp"}})
+	ctx := context.Background()
+	seedUser(t, db, 1, false)
+	seedUser(t, db, 2, false)
+	seedUser(t, db, 3, true)
+
+	req := NotificationRequest{Title: "t", ChannelName: "inapp", UserID: 1, Meta: map[string]string{"user_id": "2"}}
+	if err := svc.CreateAndEnqueue(ctx, req); !errors.Is(err, ErrRecipientNotAllowed) {
+		t.Fatalf("expected ErrRecipientNotAllowed for another user's inbox, got %v", err)
+	}
+	if _, err := svc.Preview(ctx, req); !errors.Is(err, ErrRecipientNotAllowed) {
+		t.Fatalf("expected ErrRecipientNotAllowed on preview, got %v", err)
+	}
+
+	req.Meta["user_id"] = "1"
+	if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+		t.Fatalf("expected users to notify themselves, got %v", err)
+	}
+	var n models.Notification
+	db.First(&n)
+	patch := UpdateNotificationRequest{UserID: 1, Meta: map[string]string{"user_id": "2"}}
+	if err := svc.UpdateNotification(ctx, int(n.ID), patch); !errors.Is(err, ErrRecipientNotAllowed) {
+		t.Fatalf("expected ErrRecipientNotAllowed when patching meta, got %v", err)
+	}
+
+	req.UserID, req.Meta["user_id"] = 3, "2"
+	if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+		t.Fatalf("expected admins to notify anyone, got %v", err)
+	}
+}
+
+func TestCreateAndEnqueue_PushToAnotherUsersDevices(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{"push": &fakeChannel{name: "push"}})
+	ctx := context.Background()
+	seedUser(t, db, 1, false)
+	seedUser(t, db, 2, false)
+
+	req := NotificationRequest{Title: "t", ChannelName: "push", UserID: 1, Meta: map[string]string{"user_id": "2"}}
+	if err := svc.CreateAndEnqueue(ctx, req); !errors.Is(err, ErrRecipientNotAllowed) {
+		t.Fatalf("expected ErrRecipientNotAllowed for another user's devices, got %v", err)
+	}
+	var count int64
+	db.Model(&models.Outbox{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected nothing to be enqueued, got %d rows", count)
+	}
+}
+
+func TestDispatchOutbox_StoresReceipt(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"sms": &fakeChannel{name: "sms", receipt: channel.Receipt{Provider: "twilio", ProviderMessageID: "SM123", Segments: 2}},
+	})
+
+	o := models.Outbox{NotificationID: 1, ChannelName: "sms", PayloadJson: `{"content":"c"}`, Status: models.PROCESSING}
+	if err := db.Create(&o).Error; err != nil {
+		t.Fatalf("seed outbox: %v", err)
+	}
+	if err := svc.DispatchOutbox(context.Background(), o); err != nil {
+		t.Fatalf("DispatchOutbox: %v", err)
+	}
+
+	var got models.Outbox
+	db.First(&got, o.ID)
+	if got.Status != models.SENT || got.Provider != "twilio" || got.ProviderMessageID != "SM123" || got.Segments != 2 {
+		t.Fatalf("unexpected outbox: %+v", got)
+	}
+}
+
+func TestCreateAndEnqueue_OptedOutIsSkipped(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"sms": &fakeChannel{name: "sms"},
+	}, WithPreferences(optOuts{"sms/marketing": true}))
+	ctx := context.Background()
+
+	req := NotificationRequest{Title: "t", Content: "sale", ChannelName: "sms", Category: "marketing", UserID: 3, Meta: map[string]string{"user_id": "3"}}
+	if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+		t.Fatalf("CreateAndEnqueue: %v", err)
+	}
+	var o models.Outbox
+	if err := db.First(&o).Error; err != nil {
+		t.Fatalf("find outbox: %v", err)
+	}
+	if o.Status != models.SKIPPED || o.SkipReason != "user 3 opted out" {
+		t.Fatalf("expected SKIPPED with a reason, got %s %q", o.Status, o.SkipReason)
+	}
+	var n models.Notification
+	if err := db.First(&n).Error; err != nil || n.Category != "marketing" {
+		t.Fatalf("expected the notification to be recorded with its category, got %+v, %v", n, err)
+	}
+
+	req.Category = "security"
+	if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+		t.Fatalf("CreateAndEnqueue: %v", err)
+	}
+	var pending int64
+	db.Model(&models.Outbox{}).Where("status = ?", models.PENDING).Count(&pending)
+	if pending != 1 {
+		t.Fatalf("expected other categories to be enqueued, got %d pending", pending)
+	}
+}
+
+func TestDispatchOutbox_OptedOutAfterCreate(t *testing.T) {
+	db := newTestDB(t)
+	sms := &fakeChannel{name: "sms"}
+	prefs := optOuts{}
+	svc := NewNotifierService(db, map[string]channel.Channel{"sms": sms}, WithPreferences(prefs))
+	ctx := context.Background()
+
+	seedUser(t, db, 3, true)
+	req := NotificationRequest{Title: "t", Content: "sale", ChannelName: "sms", Category: "marketing", UserID: 3, Meta: map[string]string{"user_id": "5"}}
+	if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+		t.Fatalf("CreateAndEnqueue: %v", err)
+	}
+	prefs["sms/marketing"] = true
+
+	var o models.Outbox
+	db.First(&o)
+	db.Model(&o).Update("status", models.PROCESSING)
+	if err := svc.DispatchOutbox(ctx, o); err != nil {
+		t.Fatalf("DispatchOutbox: %v", err)
+	}
+	if len(sms.sent) != 0 {
+		t.Fatal("expected nothing to be sent")
+	}
+	db.First(&o, o.ID)
+	if o.Status != models.SKIPPED || o.SkipReason != "user 5 opted out" {
+		t.Fatalf("expected SKIPPED for the addressed user, got %s %q", o.Status, o.SkipReason)
+	}
+}
+
+func TestDispatchOutbox_CreatorOptOutDoesNotApplyToRawAddress(t *testing.T) {
+	db := newTestDB(t)
+	email := &fakeChannel{name: "email"}
+	svc := NewNotifierService(db, map[string]channel.Channel{"email": email}, WithPreferences(optOuts{"email/marketing": true}))
+	ctx := context.Background()
+
+	// the creator opted out of marketing email but sends it to an outside address
+	req := NotificationRequest{Title: "t", Content: "sale", ChannelName: "email", Category: "marketing", UserID: 3, Meta: map[string]string{"to": "customer@example.com"}}
+	if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+		t.Fatalf("CreateAndEnqueue: %v", err)
+	}
+	var o models.Outbox
+	db.First(&o)
+	if o.Status != models.PENDING {
+		t.Fatalf("expected PENDING, got %s %q", o.Status, o.SkipReason)
+	}
+	db.Model(&o).Update("status", models.PROCESSING)
+	o.Status = models.PROCESSING
+	if err := svc.DispatchOutbox(ctx, o); err != nil {
+		t.Fatalf("DispatchOutbox: %v", err)
+	}
+	db.First(&o, o.ID)
+	if o.Status != models.SENT || len(email.sent) != 1 {
+		t.Fatalf("expected the email to be sent, got %s", o.Status)
+	}
+}
+
+func TestCreateAndEnqueue_Categories(t *testing.T) {
+	db := newTestDB(t)
+	registry, err := categories.New(categories.Builtin, []string{"email", "push", "inapp"})
+	if err != nil {
+		t.Fatalf("registry: %v", err)
+	}
+	svc := NewNotifierService(db, map[string]channel.Channel{
+		"email": &fakeChannel{name: "email"},
+	}, WithCategories(registry))
+	ctx := context.Background()
+
+	if err := svc.CreateAndEnqueue(ctx, NotificationRequest{Title: "t", ChannelName: "email", Category: "digest"}); !errors.Is(err, ErrInvalidCategory) {
+		t.Fatalf("expected ErrInvalidCategory, got %v", err)
+	}
+	if err := svc.CreateAndEnqueue(ctx, NotificationRequest{Title: "t", ChannelName: "email", Priority: "urgent"}); !errors.Is(err, ErrInvalidPriority) {
+		t.Fatalf("expected ErrInvalidPriority, got %v", err)
+	}
+
+	for _, req := range []NotificationRequest{
+		{Title: "a", ChannelName: "email", UserID: 1},
+		{Title: "b", ChannelName: "email", UserID: 1, Category: "security"},
+		{Title: "c", ChannelName: "email", UserID: 1, Category: "marketing", Priority: models.PriorityHigh},
+	} {
+		if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+			t.Fatalf("CreateAndEnqueue %s: %v", req.Title, err)
+		}
+	}
+	want := map[string]models.Notification{
+		"a": {Category: "general", Priority: models.PriorityNormal},
+		"b": {Category: "security", Priority: models.PriorityHigh},
+		"c": {Category: "marketing", Priority: models.PriorityHigh},
+	}
+	var list []models.Notification
+	db.Find(&list)
+	for _, n := range list {
+		if n.Category != want[n.Title].Category || n.Priority != want[n.Title].Priority {
+			t.Errorf("%s: expected %s/%s, got %s/%s", n.Title, want[n.Title].Category, want[n.Title].Priority, n.Category, n.Priority)
+		}
+	}
+
+	high, err := svc.ListNotifications(ctx, 1, ListFilter{Priority: models.PriorityHigh}, 50, 0)
+	if err != nil || len(high) != 2 {
+		t.Fatalf("expected 2 high priority notifications, got %d, %v", len(high), err)
+	}
+	security, err := svc.ListNotifications(ctx, 1, ListFilter{Category: "security"}, 50, 0)
+	if err != nil || len(security) != 1 || security[0].Title != "b" {
+		t.Fatalf("expected the security notification, got %+v, %v", security, err)
+	}
+}
+
+func TestListNotifications_OnlyTheUsers(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{"email": &fakeChannel{name: "email"}})
+	ctx := context.Background()
+	for _, req := range []NotificationRequest{
+		{Title: "mine", ChannelName: "email", UserID: 1, Category: "billing", Priority: models.PriorityHigh},
+		{Title: "theirs", ChannelName: "email", UserID: 2, Category: "billing", Priority: models.PriorityHigh},
+	} {
+		if err := svc.CreateAndEnqueue(ctx, req); err != nil {
+			t.Fatalf("CreateAndEnqueue %s: %v", req.Title, err)
+		}
+	}
+
+	for _, filter := range []ListFilter{{}, {Priority: models.PriorityHigh}, {Category: "billing"}} {
+		list, err := svc.ListNotifications(ctx, 1, filter, 50, 0)
+		if err != nil || len(list) != 1 || list[0].Title != "mine" {
+			t.Fatalf("%+v: expected only user 1's notification, got %+v, %v", filter, list, err)
+		}
+	}
+	if list, _ := svc.ListNotifications(ctx, 0, ListFilter{}, 50, 0); len(list) != 0 {
+		t.Fatalf("expected no notifications without a user, got %+v", list)
+	}
+}
+
+func TestClaimBatch_HighPriorityFirst(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{"email": &fakeChannel{name: "email"}})
+	past := time.Now().Add(-time.Minute)
+	for i, priority := range []models.Priority{models.PriorityLow, models.PriorityNormal, models.PriorityHigh} {
+		o := models.Outbox{NotificationID: uint(i + 1), ChannelName: "email", Status: models.PENDING, Priority: priority.Rank(),
+			NextAttemptAt: past, ScheduledAt: past.Add(time.Duration(i) * time.Second)}
+		if err := db.Create(&o).Error; err != nil {
+			t.Fatalf("seed outbox: %v", err)
+		}
+	}
+
+	w := NewWorker(db, svc, time.Minute, 1)
+	jobs, err := w.claimBatch(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("claimBatch: %v", err)
+	}
+	if len(jobs) != 1 || jobs[0].NotificationID != 3 {
+		t.Fatalf("expected the high priority row first, got %+v", jobs)
+	}
+}
+
+// slowChannel takes delay to send, or until the send is cancelled
+type slowChannel struct {
+	fakeChannel
+	delay  time.Duration
+	onSend func()
+}
+
+func (c *slowChannel) Send(ctx context.Context, msg channel.Message) error {
+	select {
+	case <-time.After(c.delay):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	if c.onSend != nil {
+		c.onSend()
+	}
+	return c.fakeChannel.Send(ctx, msg)
+}
+
+// serialize makes the connections of db take turns, since SQLite locks shared
+// tables when a send runs concurrently with its heartbeat
+func serialize(t *testing.T, db *gorm.DB) {
+	t.Helper()
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("db: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(1)
+}
+
+func seedPending(t *testing.T, db *gorm.DB, channelName string) models.Outbox {
+	t.Helper()
+	past := time.Now().Add(-time.Minute)
+	o := models.Outbox{NotificationID: 1, ChannelName: channelName, PayloadJson: `{"title":"t"}`, Status: models.PENDING,
+		MaxAttempts: 5, NextAttemptAt: past, ScheduledAt: past}
+	if err := db.Create(&o).Error; err != nil {
+		t.Fatalf("seed outbox: %v", err)
+	}
+	return o
+}
+
+func TestClaimBatch_TakesLease(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{"email": &fakeChannel{name: "email"}})
+	seedPending(t, db, "email")
+
+	w := NewWorker(db, svc, time.Minute, 1, WithLease("worker-a", time.Minute))
+	jobs, err := w.claimBatch(context.Background(), 1)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d, %v", len(jobs), err)
+	}
+	if jobs[0].LeaseOwner != "worker-a" || jobs[0].LeaseExpiresAt == nil || time.Until(*jobs[0].LeaseExpiresAt) < 50*time.Second {
+		t.Fatalf("expected a one minute lease for worker-a, got %q until %v", jobs[0].LeaseOwner, jobs[0].LeaseExpiresAt)
+	}
+
+	if err := w.process(context.Background(), jobs[0]); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	var o models.Outbox
+	db.First(&o, jobs[0].ID)
+	if o.Status != models.SENT || o.LeaseOwner != "" || o.LeaseExpiresAt != nil {
+		t.Fatalf("expected a SENT row without a lease, got %s owned by %q", o.Status, o.LeaseOwner)
+	}
+}
+
+func TestReap_ExpiredLease(t *testing.T) {
+	db := newTestDB(t)
+	svc := NewNotifierService(db, map[string]channel.Channel{"email": &fakeChannel{name: "email"}})
+	expired, live := time.Now().Add(-time.Second), time.Now().Add(time.Minute)
+	crashed := seedPending(t, db, "email")
+	running := seedPending(t, db, "email")
+	db.Model(&crashed).Updates(map[string]any{"status": models.PROCESSING, "lease_owner": "crashed", "lease_expires_at": expired})
+	db.Model(&running).Updates(map[string]any{"status": models.PROCESSING, "lease_owner": "running", "lease_expires_at": live})
+
+	w := NewWorker(db, svc, time.Minute, 1, WithLease("worker-a", time.Minute))
+	n, err := w.reap(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 recovered row, got %d, %v", n, err)
+	}
+
+	var o models.Outbox
+	db.First(&o, crashed.ID)
+	if o.Status != models.PENDING || o.Attempts != 1 || o.LastError != ErrLeaseExpired.Error() || o.LeaseOwner != "" || o.LeaseExpiresAt != nil {
+		t.Fatalf("expected the crashed row back in PENDING, got %+v", o)
+	}
+	var history int64
+	db.Model(&models.DeliveryError{}).Where("outbox_id = ?", crashed.ID).Count(&history)
+	if history != 1 {
+		t.Fatalf("expected the expiry in the error history, got %d entries", history)
+	}
+	var other models.Outbox
+	db.First(&other, running.ID)
+	if other.Status != models.PROCESSING || other.LeaseOwner != "running" {
+		t.Fatalf("expected the live lease to be kept, got %s owned by %q", other.Status, other.LeaseOwner)
+	}
+}
+
+func TestProcess_HeartbeatExtendsLease(t *testing.T) {
+	db := newTestDB(t)
+	serialize(t, db)
+	ch := &slowChannel{fakeChannel: fakeChannel{name: "email"}, delay: 500 * time.Millisecond}
+	svc := NewNotifierService(db, map[string]channel.Channel{"email": ch})
+	seedPending(t, db, "email")
+
+	w := NewWorker(db, svc, time.Minute, 1, WithLease("worker-a", 150*time.Millisecond))
+	reaper := NewWorker(db, svc, time.Minute, 1, WithLease("worker-b", 150*time.Millisecond))
+	ch.onSend = func() {
+		if n, err := reaper.reap(context.Background()); err != nil || n != 0 {
+			t.Errorf("expected the lease to be extended during the send, reaped %d, %v", n, err)
+		}
+	}
+	jobs, err := w.claimBatch(context.Background(), 1)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d, %v", len(jobs), err)
+	}
+	if err := w.process(context.Background(), jobs[0]); err != nil {
+		t.Fatalf("process: %v", err)
+	}
+	var o models.Outbox
+	db.First(&o, jobs[0].ID)
+	if o.Status != models.SENT {
+		t.Fatalf("expected SENT, got %s", o.Status)
+	}
+}
+
+func TestProcess_LostLeaseCancelsSend(t *testing.T) {
+	db := newTestDB(t)
+	serialize(t, db)
+	ch := &slowChannel{fakeChannel: fakeChannel{name: "email"}, delay: 10 * time.Second}
+	svc := NewNotifierService(db, map[string]channel.Channel{"email": ch})
+	seedPending(t, db, "email")
+
+	w := NewWorker(db, svc, time.Minute, 1, WithLease("worker-a", 60*time.Millisecond))
+	jobs, err := w.claimBatch(context.Background(), 1)
+	if err != nil || len(jobs) != 1 {
+		t.Fatalf("expected 1 job, got %d, %v", len(jobs), err)
+	}
+	// another worker takes the row over, as if this one had stalled past its lease
+	db.Model(&models.Outbox{}).Where("id = ?", jobs[0].ID).Update("lease_owner", "worker-b")
+
+	start := time.Now()
+	w.process(context.Background(), jobs[0])
+	if time.Since(start) > 5*time.Second || len(ch.sent) != 0 {
+		t.Fatalf("expected the send to be cancelled")
+	}
+	var o models.Outbox
+	db.First(&o, jobs[0].ID)
+	if o.Status != models.PROCESSING || o.LeaseOwner != "worker-b" || o.Attempts != 0 {
+		t.Fatalf("expected the row to be left to worker-b, got %s owned by %q after %d attempts", o.Status, o.LeaseOwner, o.Attempts)
+	}
+}
+
+// sendCounter tracks the sends running at once
+type sendCounter struct {
+	mu      sync.Mutex
+	running int
+	peak    int
+	sent    int
+}
+
+func (c *sendCounter) begin() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running++
+	c.peak = max(c.peak, c.running)
+}
+
+func (c *sendCounter) end(sent bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running--
+	if sent {
+		c.sent++
+	}
+}
+
+func (c *sendCounter) get() (running, peak, sent int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.running, c.peak, c.sent
+}
+
+// countingChannel takes delay to send and counts its sends in total and own
+type countingChannel struct {
+	fakeChannel
+	delay time.Duration
+	total *sendCounter
+	own   sendCounter
+}
+
+func (c *countingChannel) Send(ctx context.Context, msg channel.Message) error {
+	c.total.begin()
+	c.own.begin()
+	var err error
+	select {
+	case <-time.After(c.delay):
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	c.own.end(err == nil)
+	c.total.end(err == nil)
+	return err
+}
+
+// waitFor polls cond until it holds or the test runs out of patience
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+	}
+}
+
+func TestParseChannelConcurrency(t *testing.T) {
+	caps, err := ParseChannelConcurrency("sms:2, webhook:8")
+	if err != nil {
+		t.Fatalf("ParseChannelConcurrency: %v", err)
+	}
+	if len(caps) != 2 || caps["sms"] != 2 || caps["webhook"] != 8 {
+		t.Fatalf("unexpected caps %v", caps)
+	}
+	if caps, err := ParseChannelConcurrency(""); err != nil || len(caps) != 0 {
+		t.Fatalf("expected no caps, got %v, %v", caps, err)
+	}
+	for _, s := range []string{"sms", "sms:0", "sms:x", "sms:-1"} {
+		if _, err := ParseChannelConcurrency(s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
+
+func TestStart_ConcurrentDispatch(t *testing.T) {
+	db := newTestDB(t)
+	serialize(t, db)
+	total := &sendCounter{}
+	email := &countingChannel{fakeChannel: fakeChannel{name: "email"}, delay: 50 * time.Millisecond, total: total}
+	sms := &countingChannel{fakeChannel: fakeChannel{name: "sms"}, delay: 50 * time.Millisecond, total: total}
+	svc := NewNotifierService(db, map[string]channel.Channel{"email": email, "sms": sms})
+	for range 4 {
+		seedPending(t, db, "email")
+		seedPending(t, db, "sms")
+	}
+
+	w := NewWorker(db, svc, 20*time.Millisecond, 10, WithConcurrency(3), WithChannelConcurrency(map[string]int{"sms": 1}))
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		w.Start(ctx)
+		close(stopped)
+	}()
+	waitFor(t, "every row to be sent", func() bool {
+		var sent int64
+		db.Model(&models.Outbox{}).Where("status = ?", models.SENT).Count(&sent)
+		return sent == 8
+	})
+	cancel()
+	<-stopped
+
+	if _, peak, sent := total.get(); peak < 2 || peak > 3 || sent != 8 {
+		t.Fatalf("expected 8 sends with 2 or 3 at once, got %d with %d at once", sent, peak)
+	}
+	if _, peak, _ := sms.own.get(); peak != 1 {
+		t.Fatalf("expected one sms send at a time, got %d", peak)
+	}
+}
+
+func TestStart_DrainsInFlightSends(t *testing.T) {
+	db := newTestDB(t)
+	serialize(t, db)
+	ch := &countingChannel{fakeChannel: fakeChannel{name: "email"}, delay: 200 * time.Millisecond, total: &sendCounter{}}
+	svc := NewNotifierService(db, map[string]channel.Channel{"email": ch})
+	o := seedPending(t, db, "email")
+
+	w := NewWorker(db, svc, 10*time.Millisecond, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		w.Start(ctx)
+		close(stopped)
+	}()
+	waitFor(t, "the send to start", func() bool {
+		running, _, _ := ch.total.get()
+		return running == 1
+	})
+	cancel()
+	<-stopped
+
+	db.First(&o, o.ID)
+	if o.Status != models.SENT {
+		t.Fatalf("expected the in-flight send to finish, got %s", o.Status)
+	}
+}
+
+func TestStart_DrainTimeoutCancelsSends(t *testing.T) {
+	db := newTestDB(t)
+	serialize(t, db)
+	ch := &countingChannel{fakeChannel: fakeChannel{name: "email"}, delay: 10 * time.Second, total: &sendCounter{}}
+	svc := NewNotifierService(db, map[string]channel.Channel{"email": ch})
+	o := seedPending(t, db, "email")
+
+	w := NewWorker(db, svc, 10*time.Millisecond, 10, WithDrainTimeout(50*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() {
+		w.Start(ctx)
+		close(stopped)
+	}()
+	waitFor(t, "the send to start", func() bool {
+		running, _, _ := ch.total.get()
+		return running == 1
+	})
+	start := time.Now()
+	cancel()
+	<-stopped
+	if time.Since(start) > 5*time.Second {
+		t.Fatalf("expected the drain timeout to cancel the send")
+	}
+
+	db.First(&o, o.ID)
+	if o.Status != models.PROCESSING || o.LeaseExpiresAt == nil {
+		t.Fatalf("expected the interrupted row to keep its lease for the reaper, got %s", o.Status)
+	}
+}
+
+func TestDueQuery_SkipLockedOnMySQL(t *testing.T) {
+	// dry run against an unreachable server only builds the SQL
+	mysqlDB, err := gorm.Open(mysql.New(mysql.Config{DSN: "app:app@tcp(127.0.0.1:1)/notification", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatalf("open mysql: %v", err)
+	}
+	stmt := dueQuery(mysqlDB, 10, []string{"sms"}).Find(&[]models.Outbox{}).Statement
+	if sql := stmt.SQL.String(); !strings.HasSuffix(sql, "LIMIT ? FOR UPDATE SKIP LOCKED") || !strings.Contains(sql, "channel_name NOT IN") {
+		t.Fatalf("expected a locking claim query, got %s", sql)
+	}
+
+	sqliteDB := newTestDB(t).Session(&gorm.Session{DryRun: true})
+	stmt = dueQuery(sqliteDB, 10, nil).Find(&[]models.Outbox{}).Statement
+	if sql := stmt.SQL.String(); strings.Contains(sql, "FOR UPDATE") || !strings.HasPrefix(sql, "SELECT `id`,`channel_name`") {
+		t.Fatalf("expected the plain claim query on SQLite, got %s", sql)
+	}
 }
@@ -2,69 +2,299 @@ package notifier
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"notification/models"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+const (
+	// DefaultLeaseDuration is how long a worker keeps a claimed row before another
+	// worker may take it back. Long sends extend it while they run
+	DefaultLeaseDuration = 2 * time.Minute
+	// DefaultDrainTimeout is how long Start waits for in-flight sends on shutdown
+	DefaultDrainTimeout = 30 * time.Second
+)
+
+// ErrLeaseExpired is recorded on rows taken back from a worker that stopped
+// extending its lease, e.g. because its process crashed
+var ErrLeaseExpired = errors.New("lease expired")
+
+// errLeaseLost cancels a dispatch whose row was taken back by another worker
+var errLeaseLost = errors.New("lease lost")
+
+// Worker claims due outbox rows and dispatches them on a bounded pool of
+// goroutines. Claims never exceed the free slots of the pool or of a channel,
+// so claimed rows don't wait for a slot while their lease runs
 type Worker struct {
-	db       *gorm.DB
-	svc      *NotifierService
-	interval time.Duration
-	parallel int
+	db           *gorm.DB
+	svc          *NotifierService
+	interval     time.Duration
+	batchSize    int
+	concurrency  int
+	channelCaps  map[string]int
+	drainTimeout time.Duration
+	owner        string
+	lease        time.Duration
+
+	mu        sync.Mutex
+	running   int
+	byChannel map[string]int
+}
+
+type WorkerOption func(*Worker)
+
+// WithLease sets the name the worker claims rows under, which must be unique
+// among the instances sharing the outbox table, and how long a claim lasts
+// without being extended. Empty values keep the defaults: the host name and
+// process ID, and DefaultLeaseDuration
+func WithLease(owner string, duration time.Duration) WorkerOption {
+	return func(w *Worker) {
+		if owner != "" {
+			w.owner = owner
+		}
+		if duration > 0 {
+			w.lease = duration
+		}
+	}
 }
 
-func NewWorker(db *gorm.DB, svc *NotifierService, interval time.Duration, parallel int) *Worker {
-	return &Worker{db: db, svc: svc, interval: interval, parallel: parallel}
+// WithConcurrency sets how many sends run at once, 1 by default
+func WithConcurrency(n int) WorkerOption {
+	return func(w *Worker) {
+		if n > 0 {
+			w.concurrency = n
+		}
+	}
+}
+
+// WithChannelConcurrency caps the sends running at once per channel, e.g. to
+// stay under a provider's rate limit. Channels without a cap share the pool freely
+func WithChannelConcurrency(caps map[string]int) WorkerOption {
+	return func(w *Worker) { w.channelCaps = caps }
+}
+
+// WithDrainTimeout sets how long Start waits for in-flight sends once its
+// context is cancelled before cancelling them too
+func WithDrainTimeout(d time.Duration) WorkerOption {
+	return func(w *Worker) {
+		if d > 0 {
+			w.drainTimeout = d
+		}
+	}
+}
+
+// NewWorker creates a worker that looks for due rows every interval, claiming
+// at most batchSize rows per query
+func NewWorker(db *gorm.DB, svc *NotifierService, interval time.Duration, batchSize int, opts ...WorkerOption) *Worker {
+	host, _ := os.Hostname()
+	w := &Worker{
+		db:           db,
+		svc:          svc,
+		interval:     interval,
+		batchSize:    batchSize,
+		concurrency:  1,
+		drainTimeout: DefaultDrainTimeout,
+		owner:        fmt.Sprintf("%s-%d", host, os.Getpid()),
+		lease:        DefaultLeaseDuration,
+		byChannel:    make(map[string]int),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	return w
 }
 
+// ParseChannelConcurrency reads comma-separated channel:max_sends entries, e.g. "sms:2,webhook:8"
+func ParseChannelConcurrency(s string) (map[string]int, error) {
+	caps := make(map[string]int)
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, ":")
+		n, err := strconv.Atoi(value)
+		if !ok || err != nil || n < 1 {
+			return nil, fmt.Errorf("channel concurrency %q: expected channel:max_sends with max_sends at least 1", entry)
+		}
+		caps[name] = n
+	}
+	return caps, nil
+}
+
+// Start dispatches due rows until ctx is cancelled, then stops claiming and
+// waits for the sends in flight. They run on a context of their own so
+// shutting down doesn't interrupt them, unless they outlast the drain timeout;
+// rows whose send was interrupted keep their lease and are taken back once it expires
 func (w *Worker) Start(ctx context.Context) {
+	sendCtx, cancelSends := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelSends()
+	var wg sync.WaitGroup
+	// finished wakes the loop up when a slot frees, so a backlog doesn't wait for the next tick
+	finished := make(chan struct{}, 1)
+
 	ticker := time.NewTicker(w.interval)
 	defer ticker.Stop()
 	for {
 		select {
 		case <-ctx.Done():
+			drain := time.AfterFunc(w.drainTimeout, cancelSends)
+			wg.Wait()
+			drain.Stop()
 			return
 		case <-ticker.C:
-			jobs, err := w.claimBatch(ctx, w.parallel)
+			if n, err := w.reap(ctx); err != nil {
+				log.Printf("Error recovering expired leases: %v", err)
+			} else if n > 0 {
+				log.Printf("Recovered %d outbox rows with expired leases", n)
+			}
+		case <-finished:
+		}
+
+		// claim until the pool is full or nothing is due
+		for ctx.Err() == nil {
+			jobs, err := w.claimBatch(ctx, min(w.batchSize, w.free()))
 			if err != nil {
 				log.Printf("Error fetching pending notifications: %v", err)
-				continue
+				break
+			}
+			if len(jobs) == 0 {
+				break
 			}
 			for _, job := range jobs {
-				w.process(ctx, job)
+				w.acquire(job.ChannelName)
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := w.process(sendCtx, job); err != nil {
+						log.Printf("Error dispatching outbox %d: %v", job.ID, err)
+					}
+					w.release(job.ChannelName)
+					select {
+					case finished <- struct{}{}:
+					default:
+					}
+				}()
 			}
 		}
 	}
 }
 
+// free returns the number of idle slots in the pool
+func (w *Worker) free() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.concurrency - w.running
+}
+
+// channelsFree returns the idle slots of the channels with a cap
+func (w *Worker) channelsFree() map[string]int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	free := make(map[string]int, len(w.channelCaps))
+	for name, limit := range w.channelCaps {
+		free[name] = limit - w.byChannel[name]
+	}
+	return free
+}
+
+func (w *Worker) acquire(channelName string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running++
+	w.byChannel[channelName]++
+}
+
+func (w *Worker) release(channelName string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.running--
+	w.byChannel[channelName]--
+}
+
+// skipLocked reports whether the database can lock the rows of a claim and skip
+// the rows other workers are claiming, as MySQL 8 does with FOR UPDATE SKIP LOCKED.
+// SQLite can't, so claims there select the rows first and update the ones still
+// PENDING
+func skipLocked(db *gorm.DB) bool {
+	return db.Dialector.Name() == "mysql"
+}
+
+// dueQuery selects up to limit due rows in claim order, except those of the full channels
+func dueQuery(tx *gorm.DB, limit int, full []string) *gorm.DB {
+	q := tx.Model(&models.Outbox{}).
+		Where("status = ? AND next_attempt_at <= ? AND scheduled_at <= ?", models.PENDING, time.Now(), time.Now())
+	if len(full) > 0 {
+		q = q.Where("channel_name NOT IN ?", full)
+	}
+	q = q.Order("priority DESC, scheduled_at ASC, next_attempt_at ASC").Limit(limit)
+	if skipLocked(tx) {
+		return q.Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsSkipLocked})
+	}
+	return q.Select("id", "channel_name")
+}
+
+// claimBatch claims up to limit due rows, leaving out the rows of channels
+// without a free slot
 func (w *Worker) claimBatch(ctx context.Context, limit int) ([]models.Outbox, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+	channelsFree := w.channelsFree()
+	var full []string
+	for name, free := range channelsFree {
+		if free <= 0 {
+			full = append(full, name)
+		}
+	}
+
 	tx := w.db.WithContext(ctx).Begin()
 	if tx.Error != nil {
 		return nil, tx.Error
 	}
 
-	var ids []int
-	if err := tx.
-		Model(&models.Outbox{}).
-		Where("status = ? AND next_attempt_at <= ? AND scheduled_at <= ?", models.PENDING, time.Now(), time.Now()).
-		Order("scheduled_at ASC, next_attempt_at ASC").
-		Limit(limit).
-		Pluck("id", &ids).Error; err != nil {
+	var due []models.Outbox
+	if err := dueQuery(tx, limit, full).Find(&due).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
+	var jobs []models.Outbox
+	var ids []uint
+	for _, o := range due {
+		if free, capped := channelsFree[o.ChannelName]; capped {
+			if free <= 0 {
+				continue
+			}
+			channelsFree[o.ChannelName]--
+		}
+		jobs = append(jobs, o)
+		ids = append(ids, o.ID)
+	}
 	if len(ids) == 0 {
 		tx.Rollback()
 		return nil, nil
 	}
 
+	now := time.Now()
+	expires := now.Add(w.lease)
 	res := tx.Model(&models.Outbox{}).
 		Where("id IN ? AND status = ?", ids, models.PENDING).
-		Updates(map[string]any{"status": models.PROCESSING, "updated_at": time.Now()})
+		Updates(map[string]any{
+			"status":           models.PROCESSING,
+			"lease_owner":      w.owner,
+			"lease_expires_at": expires,
+			"updated_at":       now,
+		})
 	if res.Error != nil {
 		tx.Rollback()
 		return nil, res.Error
@@ -74,8 +304,20 @@ func (w *Worker) claimBatch(ctx context.Context, limit int) ([]models.Outbox, er
 		return nil, nil
 	}
 
-	var jobs []models.Outbox
-	if err := tx.Where("id IN ?", ids).Find(&jobs).Error; err != nil {
+	if skipLocked(tx) {
+		// the rows were locked since the select, so they were all claimed as read
+		for i := range jobs {
+			jobs[i].Status = models.PROCESSING
+			jobs[i].LeaseOwner = w.owner
+			jobs[i].LeaseExpiresAt = &expires
+			jobs[i].UpdatedAt = now
+		}
+		return jobs, tx.Commit().Error
+	}
+
+	// another worker may have claimed some of the rows between the select and the update
+	jobs = nil
+	if err := tx.Where("id IN ? AND status = ? AND lease_owner = ?", ids, models.PROCESSING, w.owner).Find(&jobs).Error; err != nil {
 		tx.Rollback()
 		return nil, err
 	}
@@ -86,5 +328,80 @@ func (w *Worker) process(ctx context.Context, outbox models.Outbox) error {
 	if outbox.Status != models.PROCESSING {
 		return nil
 	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	go w.heartbeat(ctx, cancel, outbox)
 	return w.svc.DispatchOutbox(ctx, outbox)
 }
+
+// heartbeat extends the lease of a row being dispatched until ctx is done. The
+// dispatch is cancelled if the lease was taken back in the meantime
+func (w *Worker) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, outbox models.Outbox) {
+	ticker := time.NewTicker(w.lease / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			lost, err := w.extend(ctx, outbox)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				log.Printf("Error extending lease of outbox %d: %v", outbox.ID, err)
+			}
+			if lost {
+				log.Printf("Lease of outbox %d was taken back, cancelling the send", outbox.ID)
+				cancel(errLeaseLost)
+				return
+			}
+		}
+	}
+}
+
+// extend pushes back the expiry of a lease this worker holds and reports
+// whether the lease is gone
+func (w *Worker) extend(ctx context.Context, outbox models.Outbox) (lost bool, err error) {
+	res := claimed(w.db.WithContext(ctx), outbox).
+		UpdateColumn("lease_expires_at", time.Now().Add(w.lease))
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected == 0, nil
+}
+
+// reap takes back PROCESSING rows whose lease expired and retries them like a
+// failed send, so a crashed worker doesn't leave them stuck. Rows claimed
+// before leases existed have no expiry and are taken back once they haven't
+// been updated for a lease duration
+func (w *Worker) reap(ctx context.Context) (int, error) {
+	now := time.Now()
+	expired := w.db.WithContext(ctx).Model(&models.Outbox{}).
+		Where("status = ? AND (lease_expires_at < ? OR (lease_expires_at IS NULL AND updated_at < ?))",
+			models.PROCESSING, now, now.Add(-w.lease)).
+		Session(&gorm.Session{})
+	var rows []models.Outbox
+	if err := expired.Limit(100).Find(&rows).Error; err != nil {
+		return 0, err
+	}
+
+	reaped := 0
+	for _, row := range rows {
+		// take the lease over first so only one worker recovers the row
+		res := expired.Where("id = ? AND lease_owner = ?", row.ID, row.LeaseOwner).
+			Updates(map[string]any{"lease_owner": w.owner, "lease_expires_at": now.Add(w.lease)})
+		if res.Error != nil {
+			return reaped, res.Error
+		}
+		if res.RowsAffected == 0 {
+			continue
+		}
+		row.LeaseOwner = w.owner
+		if err := w.svc.retry(ctx, row, 0, ErrLeaseExpired); err != nil {
+			return reaped, err
+		}
+		reaped++
+	}
+	return reaped, nil
+}
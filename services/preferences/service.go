@@ -0,0 +1,134 @@
+package preferences
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"notification/models"
+	"notification/services/categories"
+	"slices"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrInvalidPreference = errors.New("invalid preference")
+
+// Service stores which channels and categories users accept notifications on
+type Service struct {
+	db *gorm.DB
+	// channels are the channel names preferences may be set for
+	channels   []string
+	categories *categories.Registry
+}
+
+func New(db *gorm.DB, channelNames []string, registry *categories.Registry) *Service {
+	return &Service{db: db, channels: channelNames, categories: registry}
+}
+
+// Update sets whether a user accepts notifications of Category on ChannelName.
+// Either may be "*" to match everything
+type Update struct {
+	ChannelName string
+	Category    string
+	Enabled     bool
+}
+
+// List returns the preferences a user has set
+func (s *Service) List(ctx context.Context, userID uint) ([]models.Preference, error) {
+	list := []models.Preference{}
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("channel_name, category").Find(&list).Error
+	return list, err
+}
+
+// Set saves the given preferences of a user, keeping the ones not mentioned
+func (s *Service) Set(ctx context.Context, userID uint, updates []Update) ([]models.Preference, error) {
+	if len(updates) == 0 {
+		return nil, fmt.Errorf("%w: no preferences given", ErrInvalidPreference)
+	}
+	rows := make([]models.Preference, 0, len(updates))
+	for _, u := range updates {
+		if u.ChannelName != models.PreferenceAny && !slices.Contains(s.channels, u.ChannelName) {
+			return nil, fmt.Errorf("%w: unknown channel %q", ErrInvalidPreference, u.ChannelName)
+		}
+		if u.Category != models.PreferenceAny {
+			category, err := s.categories.Get(u.Category)
+			if err != nil || u.Category == "" {
+				return nil, fmt.Errorf("%w: unknown category %q", ErrInvalidPreference, u.Category)
+			}
+			if category.Mandatory && !u.Enabled {
+				return nil, fmt.Errorf("%w: %s notifications can't be turned off", ErrInvalidPreference, category.Name)
+			}
+		}
+		rows = append(rows, models.Preference{UserID: userID, ChannelName: u.ChannelName, Category: u.Category, Enabled: u.Enabled})
+	}
+
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "channel_name"}, {Name: "category"}},
+		DoUpdates: clause.AssignmentColumns([]string{"enabled", "updated_at"}),
+	}).Create(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	return s.List(ctx, userID)
+}
+
+// Suppressed implements notifier.Preferences. Mandatory categories are never
+// suppressed. Otherwise the preference for the channel and category wins over
+// one for all categories of the channel, which wins over one for the category on
+// every channel, then one for everything. Without a preference naming the
+// category, it is only sent on its default channels
+func (s *Service) Suppressed(ctx context.Context, userID uint, channelName, categoryName string) (string, error) {
+	category, err := s.categories.Get(categoryName)
+	if err != nil {
+		// a category removed from the registry after the notification was created
+		category = models.Category{Name: categoryName}
+	}
+	if category.Mandatory {
+		return "", nil
+	}
+
+	var matches []models.Preference
+	err = s.db.WithContext(ctx).
+		Where("user_id = ? AND channel_name IN ? AND category IN ?", userID,
+			[]string{channelName, models.PreferenceAny}, []string{category.Name, models.PreferenceAny}).
+		Find(&matches).Error
+	if err != nil {
+		return "", err
+	}
+
+	var best *models.Preference
+	for i, p := range matches {
+		if best == nil || specificity(p) > specificity(*best) {
+			best = &matches[i]
+		}
+	}
+	switch {
+	case best == nil || best.Enabled && best.Category == models.PreferenceAny:
+		// enabling a whole channel doesn't opt in to categories that are off by default
+		if !category.OnChannel(channelName) {
+			return fmt.Sprintf("user %d didn't opt in to %s notifications on %s", userID, category.Name, channelName), nil
+		}
+		return "", nil
+	case best.Enabled:
+		return "", nil
+	case best.ChannelName == models.PreferenceAny && best.Category == models.PreferenceAny:
+		return fmt.Sprintf("user %d opted out of all notifications", userID), nil
+	case best.Category == models.PreferenceAny:
+		return fmt.Sprintf("user %d opted out of %s notifications", userID, channelName), nil
+	case best.ChannelName == models.PreferenceAny:
+		return fmt.Sprintf("user %d opted out of %s notifications", userID, category.Name), nil
+	}
+	return fmt.Sprintf("user %d opted out of %s notifications on %s", userID, category.Name, channelName), nil
+}
+
+func specificity(p models.Preference) int {
+	n := 0
+	if p.ChannelName != models.PreferenceAny {
+		n += 2
+	}
+	if p.Category != models.PreferenceAny {
+		n++
+	}
+	return n
+}
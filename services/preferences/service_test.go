@@ -0,0 +1,162 @@
+package preferences
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"notification/models"
+	"notification/services/categories"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	db, err := gorm.Open(dsn, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Preference{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func newTestService(t *testing.T) *Service {
+	t.Helper()
+	channels := []string{"email", "sms", "push", "inapp"}
+	registry, err := categories.New(categories.Builtin, channels)
+	if err != nil {
+		t.Fatalf("registry: %v", err)
+	}
+	return New(newTestDB(t), channels, registry)
+}
+
+func TestSet(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+
+	if _, err := svc.Set(ctx, 1, []Update{{ChannelName: "sms", Category: "marketing", Enabled: false}}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	list, err := svc.Set(ctx, 1, []Update{
+		{ChannelName: "sms", Category: "marketing", Enabled: true},
+		{ChannelName: "*", Category: "product", Enabled: false},
+	})
+	if err != nil {
+		t.Fatalf("Set again: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("expected 2 preferences, got %+v", list)
+	}
+	if list[1].ChannelName != "sms" || !list[1].Enabled {
+		t.Fatalf("expected the sms preference to be updated, got %+v", list[1])
+	}
+
+	other, err := svc.List(ctx, 2)
+	if err != nil || len(other) != 0 {
+		t.Fatalf("expected no preferences for another user, got %v, %v", other, err)
+	}
+}
+
+func TestSet_Invalid(t *testing.T) {
+	svc := newTestService(t)
+	tests := []Update{
+		{ChannelName: "fax", Category: "marketing"},
+		{ChannelName: "sms", Category: ""},
+		{ChannelName: "sms", Category: "digest"},
+		{ChannelName: "*", Category: "security", Enabled: false},
+	}
+	for _, u := range tests {
+		if _, err := svc.Set(context.Background(), 1, []Update{u}); !errors.Is(err, ErrInvalidPreference) {
+			t.Errorf("%+v: expected ErrInvalidPreference, got %v", u, err)
+		}
+	}
+	if _, err := svc.Set(context.Background(), 1, nil); !errors.Is(err, ErrInvalidPreference) {
+		t.Errorf("expected ErrInvalidPreference without preferences, got %v", err)
+	}
+}
+
+func TestSuppressed_MostSpecificWins(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	_, err := svc.Set(ctx, 1, []Update{
+		{ChannelName: "*", Category: "*", Enabled: false},
+		{ChannelName: "email", Category: "*", Enabled: true},
+		{ChannelName: "*", Category: "product", Enabled: true},
+		{ChannelName: "email", Category: "marketing", Enabled: false},
+	})
+	if err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	tests := []struct {
+		channel, category string
+		suppressed        bool
+	}{
+		{"sms", "marketing", true},
+		{"sms", "", true},
+		{"push", "product", false},
+		{"sms", "security", false},
+		{"email", "general", false},
+		{"email", "", false},
+		{"email", "marketing", true},
+	}
+	for _, tt := range tests {
+		reason, err := svc.Suppressed(ctx, 1, tt.channel, tt.category)
+		if err != nil {
+			t.Fatalf("Suppressed: %v", err)
+		}
+		if (reason != "") != tt.suppressed {
+			t.Errorf("%s/%s: expected suppressed=%v, got reason %q", tt.channel, tt.category, tt.suppressed, reason)
+		}
+	}
+
+	if reason, _ := svc.Suppressed(ctx, 2, "sms", "general"); reason != "" {
+		t.Fatalf("users without preferences should get general notifications, got %q", reason)
+	}
+}
+
+func TestSuppressed_DefaultChannels(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+
+	// marketing is sent by email and in-app unless users opt in to more
+	if reason, _ := svc.Suppressed(ctx, 1, "email", "marketing"); reason != "" {
+		t.Fatalf("expected marketing email by default, got %q", reason)
+	}
+	if reason, _ := svc.Suppressed(ctx, 1, "sms", "marketing"); reason == "" {
+		t.Fatal("expected marketing SMS to need an opt-in")
+	}
+
+	// enabling the whole channel isn't an opt-in to marketing
+	if _, err := svc.Set(ctx, 1, []Update{{ChannelName: "sms", Category: "*", Enabled: true}}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if reason, _ := svc.Suppressed(ctx, 1, "sms", "marketing"); reason == "" {
+		t.Fatal("expected marketing SMS to still need an opt-in")
+	}
+	if _, err := svc.Set(ctx, 1, []Update{{ChannelName: "sms", Category: "marketing", Enabled: true}}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if reason, _ := svc.Suppressed(ctx, 1, "sms", "marketing"); reason != "" {
+		t.Fatalf("expected the opt-in to allow marketing SMS, got %q", reason)
+	}
+}
+
+func TestSuppressed_Mandatory(t *testing.T) {
+	svc := newTestService(t)
+	ctx := context.Background()
+	if _, err := svc.Set(ctx, 1, []Update{{ChannelName: "*", Category: "*", Enabled: false}}); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	for _, category := range []string{"security", "transactional"} {
+		if reason, _ := svc.Suppressed(ctx, 1, "sms", category); reason != "" {
+			t.Errorf("%s notifications should always be sent, got %q", category, reason)
+		}
+	}
+}
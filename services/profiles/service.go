@@ -0,0 +1,298 @@
+package profiles
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/mail"
+	"notification/channels"
+	"notification/models"
+	"notification/models/channel"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUserNotFound   = errors.New("user not found")
+	ErrInvalidProfile = errors.New("invalid profile")
+	ErrInvalidCode    = errors.New("invalid or expired verification code")
+
+	// a BCP 47 language tag such as en, pt-BR or zh-Hant-TW
+	localePattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+)
+
+const (
+	// codeTTL is how long a verification code can be entered
+	codeTTL = 15 * time.Minute
+	// maxCodeAttempts wrong codes invalidate the pending codes
+	maxCodeAttempts = 5
+)
+
+// CodeSender delivers a verification code to an email address ("email") or a
+// phone number ("sms")
+type CodeSender func(ctx context.Context, userID uint, channelName, address, code string) error
+
+// Service manages the contact profiles notifications resolve recipients with
+type Service struct {
+	db       *gorm.DB
+	sendCode CodeSender
+}
+
+type Option func(*Service)
+
+// WithCodeSender sends the codes that verify new profile addresses. Without it
+// new addresses can't be verified and are never used
+func WithCodeSender(send CodeSender) Option {
+	return func(s *Service) { s.sendCode = send }
+}
+
+// ChannelCodeSender sends verification codes straight through the "email" and
+// "sms" channels. The codes aren't stored as notifications, which the user
+// could read back to verify an address they don't own
+func ChannelCodeSender(channelList map[string]channel.Channel) CodeSender {
+	return func(ctx context.Context, userID uint, channelName, address, code string) error {
+		ch, ok := channelList[channelName]
+		if !ok {
+			return fmt.Errorf("no %s channel", channelName)
+		}
+		msg := channel.Message{
+			Title:    "Verification code",
+			Content:  fmt.Sprintf("Your verification code is %s. It expires in %d minutes.", code, int(codeTTL.Minutes())),
+			Meta:     map[string]string{"to": address, "subject": "Verify your email address"},
+			Priority: string(models.PriorityHigh),
+			UserID:   userID,
+		}
+		if channelName == "sms" {
+			// the default SMS provider
+			msg.Meta = map[string]string{"phone": address, "carrier": "default"}
+		}
+		if err := ch.Validate(msg.Meta); err != nil {
+			return err
+		}
+		if err := ch.Prepare(ctx, &msg); err != nil {
+			return err
+		}
+		return ch.Send(ctx, msg)
+	}
+}
+
+func New(db *gorm.DB, opts ...Option) *Service {
+	s := &Service{db: db}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// ProfileUpdate replaces the contact details of a user. Region is the ISO 3166-1
+// code a phone number in national format is read in
+type ProfileUpdate struct {
+	Email    string
+	Phone    string
+	Region   string
+	Locale   string
+	Timezone string
+}
+
+// Get returns the profile of a user with their devices
+func (s *Service) Get(ctx context.Context, userID uint) (*models.ContactProfile, error) {
+	profile, _, err := s.load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	profile.Devices = []models.Device{}
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&profile.Devices).Error; err != nil {
+		return nil, err
+	}
+	return profile, nil
+}
+
+// load returns the stored profile of a user and the user. Users who never
+// saved a profile get one with their account email
+func (s *Service) load(ctx context.Context, userID uint) (*models.ContactProfile, *models.User, error) {
+	var user models.User
+	if err := s.db.WithContext(ctx).First(&user, userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrUserNotFound
+		}
+		return nil, nil, err
+	}
+
+	profile := models.ContactProfile{UserID: userID}
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&profile).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, err
+	}
+	if profile.Email == "" {
+		profile.Email = user.Email
+		profile.EmailVerified = true
+	}
+	return &profile, &user, nil
+}
+
+// newCode returns a six digit verification code and its hash
+func newCode() (string, string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1_000_000))
+	if err != nil {
+		return "", "", err
+	}
+	code := fmt.Sprintf("%06d", n.Int64())
+	return code, codeHash(code), nil
+}
+
+func codeHash(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+// Update validates and saves the profile of a user. The phone number is stored
+// in E.164 format. A changed email or phone is unverified until the user enters
+// the code sent to it
+func (s *Service) Update(ctx context.Context, userID uint, update ProfileUpdate) (*models.ContactProfile, error) {
+	profile := models.ContactProfile{UserID: userID, Email: update.Email, Locale: update.Locale, Timezone: update.Timezone}
+	if update.Email != "" {
+		addr, err := mail.ParseAddress(update.Email)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid email address", ErrInvalidProfile)
+		}
+		profile.Email = addr.Address
+	}
+	if update.Phone != "" {
+		phone, err := channels.ParsePhone(update.Phone, update.Region)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProfile, err)
+		}
+		profile.Phone = phone.E164
+	}
+	if update.Locale != "" && !localePattern.MatchString(update.Locale) {
+		return nil, fmt.Errorf("%w: locale must be a language tag such as en-US", ErrInvalidProfile)
+	}
+	if update.Timezone != "" {
+		if _, err := time.LoadLocation(update.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidProfile, update.Timezone)
+		}
+	}
+
+	current, user, err := s.load(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// the account email is used when the profile has none
+	if profile.Email == user.Email {
+		profile.Email = ""
+	}
+	var emailCode, phoneCode string
+	if profile.Email != "" && profile.Email == current.Email {
+		profile.EmailVerified, profile.EmailCode = current.EmailVerified, current.EmailCode
+	} else if profile.Email != "" {
+		if emailCode, profile.EmailCode, err = newCode(); err != nil {
+			return nil, err
+		}
+	}
+	if profile.Phone != "" && profile.Phone == current.Phone {
+		profile.PhoneVerified, profile.PhoneCode = current.PhoneVerified, current.PhoneCode
+	} else if profile.Phone != "" {
+		if phoneCode, profile.PhoneCode, err = newCode(); err != nil {
+			return nil, err
+		}
+	}
+	profile.CodeExpiresAt, profile.CodeAttempts = current.CodeExpiresAt, current.CodeAttempts
+	if emailCode != "" || phoneCode != "" {
+		expires := time.Now().Add(codeTTL)
+		profile.CodeExpiresAt, profile.CodeAttempts = &expires, 0
+	}
+
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"email", "email_verified", "phone", "phone_verified", "locale", "timezone",
+			"email_code", "phone_code", "code_expires_at", "code_attempts", "updated_at"}),
+	}).Create(&profile).Error; err != nil {
+		return nil, err
+	}
+
+	if s.sendCode != nil {
+		if emailCode != "" {
+			if err := s.sendCode(ctx, userID, "email", profile.Email, emailCode); err != nil {
+				return nil, fmt.Errorf("send email verification code: %w", err)
+			}
+		}
+		if phoneCode != "" {
+			if err := s.sendCode(ctx, userID, "sms", profile.Phone, phoneCode); err != nil {
+				return nil, fmt.Errorf("send phone verification code: %w", err)
+			}
+		}
+	}
+	return s.Get(ctx, userID)
+}
+
+// Verify marks the profile email ("email") or phone ("phone") as verified when
+// code is the one sent to it
+func (s *Service) Verify(ctx context.Context, userID uint, field, code string) (*models.ContactProfile, error) {
+	if field != "email" && field != "phone" {
+		return nil, fmt.Errorf("%w: field must be email or phone", ErrInvalidProfile)
+	}
+	var profile models.ContactProfile
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&profile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidCode
+		}
+		return nil, err
+	}
+	pending := profile.EmailCode
+	if field == "phone" {
+		pending = profile.PhoneCode
+	}
+	if pending == "" || profile.CodeExpiresAt == nil || time.Now().After(*profile.CodeExpiresAt) || profile.CodeAttempts >= maxCodeAttempts {
+		return nil, ErrInvalidCode
+	}
+
+	q := s.db.WithContext(ctx).Model(&models.ContactProfile{}).Where("id = ?", profile.ID)
+	if subtle.ConstantTimeCompare([]byte(pending), []byte(codeHash(code))) != 1 {
+		if err := q.UpdateColumn("code_attempts", gorm.Expr("code_attempts + ?", 1)).Error; err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCode
+	}
+	// the code is only good once
+	if err := q.Where(field+"_code = ?", pending).Updates(map[string]any{
+		field + "_verified": true,
+		field + "_code":     "",
+		"updated_at":        time.Now(),
+	}).Error; err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID)
+}
+
+// Contact implements channel.ContactSource. Unknown users fail permanently.
+// Unverified addresses are left out, so email falls back to the account email
+func (s *Service) Contact(ctx context.Context, userID uint) (channel.Contact, error) {
+	profile, user, err := s.load(ctx, userID)
+	if errors.Is(err, ErrUserNotFound) {
+		return channel.Contact{}, channel.Permanent(fmt.Errorf("recipient %d: %w", userID, err))
+	}
+	if err != nil {
+		return channel.Contact{}, err
+	}
+	contact := channel.Contact{
+		Email:    user.Email,
+		Locale:   profile.Locale,
+		Timezone: profile.Timezone,
+	}
+	if profile.EmailVerified {
+		contact.Email = profile.Email
+	}
+	if profile.PhoneVerified {
+		contact.Phone = profile.Phone
+	}
+	return contact, nil
+}
@@ -0,0 +1,243 @@
+package profiles
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"notification/models"
+	"notification/models/channel"
+	"notification/services/notifier"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	db, err := gorm.Open(dsn, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.User{}, &models.ContactProfile{}, &models.Device{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func seedUser(t *testing.T, db *gorm.DB, email string) models.User {
+	t.Helper()
+	user := models.User{Email: email, Password: "x"}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatalf("seed user: %v", err)
+	}
+	return user
+}
+
+func TestGet_DefaultsToAccountEmail(t *testing.T) {
+	db := newTestDB(t)
+	svc := New(db)
+	user := seedUser(t, db, "ana@example.com")
+	db.Create(&models.Device{UserID: user.ID, Token: "t", TokenHash: "h", Platform: "ios"})
+
+	profile, err := svc.Get(context.Background(), user.ID)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if profile.Email != "ana@example.com" || profile.UserID != user.ID {
+		t.Fatalf("unexpected profile %+v", profile)
+	}
+	if len(profile.Devices) != 1 {
+		t.Fatalf("expected the user's device, got %d", len(profile.Devices))
+	}
+
+	if _, err := svc.Get(context.Background(), 999); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+// codes records the verification codes a service sends, by channel
+type codes map[string]string
+
+func (c codes) send(ctx context.Context, userID uint, channelName, address, code string) error {
+	c[channelName] = code
+	return nil
+}
+
+func TestUpdate(t *testing.T) {
+	db := newTestDB(t)
+	sent := codes{}
+	svc := New(db, WithCodeSender(sent.send))
+	ctx := context.Background()
+	user := seedUser(t, db, "ana@example.com")
+
+	profile, err := svc.Update(ctx, user.ID, ProfileUpdate{
+		Email: "Ana <ana@work.example>", Phone: "(415) 555-2671", Region: "US", Locale: "pt-BR", Timezone: "America/Sao_Paulo",
+	})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if profile.Email != "ana@work.example" || profile.Phone != "+14155552671" || profile.Locale != "pt-BR" {
+		t.Fatalf("unexpected profile %+v", profile)
+	}
+	if profile.EmailVerified || profile.PhoneVerified || sent["email"] == "" || sent["sms"] == "" {
+		t.Fatalf("expected new addresses to be unverified with codes sent, got %+v and %v", profile, sent)
+	}
+
+	// updating again replaces the stored row
+	if _, err := svc.Update(ctx, user.ID, ProfileUpdate{Phone: "+447911123456"}); err != nil {
+		t.Fatalf("Update again: %v", err)
+	}
+	if _, err := svc.Verify(ctx, user.ID, "phone", sent["sms"]); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	var count int64
+	db.Model(&models.ContactProfile{}).Count(&count)
+	if count != 1 {
+		t.Fatalf("expected one profile row, got %d", count)
+	}
+	contact, err := svc.Contact(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Contact: %v", err)
+	}
+	if contact.Email != "ana@example.com" || contact.Phone != "+447911123456" || contact.Locale != "" {
+		t.Fatalf("unexpected contact %+v", contact)
+	}
+}
+
+// outbox is a channel that keeps the messages sent through it
+type outbox struct {
+	name string
+	sent []channel.Message
+}
+
+func (c *outbox) Name() string                                            { return c.name }
+func (c *outbox) Validate(meta map[string]string) error                   { return nil }
+func (c *outbox) Prepare(ctx context.Context, msg *channel.Message) error { return nil }
+func (c *outbox) Send(ctx context.Context, msg channel.Message) error {
+	c.sent = append(c.sent, msg)
+	return nil
+}
+
+func TestChannelCodeSender_NotReadableAsNotification(t *testing.T) {
+	db := newTestDB(t)
+	if err := db.AutoMigrate(&models.Notification{}, &models.Outbox{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	email, sms := &outbox{name: "email"}, &outbox{name: "sms"}
+	channelList := map[string]channel.Channel{"email": email, "sms": sms}
+	svc := New(db, WithCodeSender(ChannelCodeSender(channelList)))
+	notifierService := notifier.NewNotifierService(db, channelList)
+	ctx := context.Background()
+	user := seedUser(t, db, "ana@example.com")
+
+	if _, err := svc.Update(ctx, user.ID, ProfileUpdate{Email: "ana@work.example", Phone: "+14155552671"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if len(email.sent) != 1 || email.sent[0].Meta["to"] != "ana@work.example" {
+		t.Fatalf("expected the email code to be sent to the new address, got %+v", email.sent)
+	}
+	if len(sms.sent) != 1 || sms.sent[0].Meta["phone"] != "+14155552671" {
+		t.Fatalf("expected the SMS code to be sent to the new number, got %+v", sms.sent)
+	}
+
+	list, err := notifierService.ListNotifications(ctx, user.ID, notifier.ListFilter{}, 0, 0)
+	if err != nil {
+		t.Fatalf("ListNotifications: %v", err)
+	}
+	if len(list) != 0 {
+		t.Fatalf("expected the codes to be unreadable through the notifications API, got %+v", list)
+	}
+	var count int64
+	db.Model(&models.Notification{}).Count(&count)
+	if count != 0 {
+		t.Fatalf("expected no stored notifications, got %d", count)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	db := newTestDB(t)
+	sent := codes{}
+	svc := New(db, WithCodeSender(sent.send))
+	ctx := context.Background()
+	user := seedUser(t, db, "ana@example.com")
+
+	if _, err := svc.Update(ctx, user.ID, ProfileUpdate{Email: "ana@work.example", Phone: "+14155552671"}); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	contact, err := svc.Contact(ctx, user.ID)
+	if err != nil {
+		t.Fatalf("Contact: %v", err)
+	}
+	if contact.Email != "ana@example.com" || contact.Phone != "" {
+		t.Fatalf("unverified addresses should not be used, got %+v", contact)
+	}
+
+	if _, err := svc.Verify(ctx, user.ID, "email", sent["sms"]); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected ErrInvalidCode for the phone code, got %v", err)
+	}
+	profile, err := svc.Verify(ctx, user.ID, "email", sent["email"])
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !profile.EmailVerified || profile.PhoneVerified {
+		t.Fatalf("expected only the email to be verified, got %+v", profile)
+	}
+	if _, err := svc.Verify(ctx, user.ID, "email", sent["email"]); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("a code should only work once, got %v", err)
+	}
+	contact, _ = svc.Contact(ctx, user.ID)
+	if contact.Email != "ana@work.example" || contact.Phone != "" {
+		t.Fatalf("expected the verified email only, got %+v", contact)
+	}
+
+	// saving the same address keeps it verified
+	if profile, _ = svc.Update(ctx, user.ID, ProfileUpdate{Email: "ana@work.example", Phone: "+14155552671"}); !profile.EmailVerified {
+		t.Fatalf("expected the email to stay verified, got %+v", profile)
+	}
+
+	// too many wrong codes invalidate the pending ones
+	for i := 0; i < maxCodeAttempts; i++ {
+		svc.Verify(ctx, user.ID, "phone", "wrong")
+	}
+	if _, err := svc.Verify(ctx, user.ID, "phone", sent["sms"]); !errors.Is(err, ErrInvalidCode) {
+		t.Fatalf("expected ErrInvalidCode after too many attempts, got %v", err)
+	}
+}
+
+func TestUpdate_Invalid(t *testing.T) {
+	db := newTestDB(t)
+	svc := New(db)
+	user := seedUser(t, db, "ana@example.com")
+
+	tests := []struct {
+		name   string
+		update ProfileUpdate
+	}{
+		{"email", ProfileUpdate{Email: "bad@@example"}},
+		{"national phone without region", ProfileUpdate{Phone: "4155552671"}},
+		{"phone", ProfileUpdate{Phone: "+1415"}},
+		{"locale", ProfileUpdate{Locale: "english (us)"}},
+		{"timezone", ProfileUpdate{Timezone: "Mars/Olympus"}},
+	}
+	for _, tt := range tests {
+		if _, err := svc.Update(context.Background(), user.ID, tt.update); !errors.Is(err, ErrInvalidProfile) {
+			t.Errorf("%s: expected ErrInvalidProfile, got %v", tt.name, err)
+		}
+	}
+
+	if _, err := svc.Update(context.Background(), 999, ProfileUpdate{}); !errors.Is(err, ErrUserNotFound) {
+		t.Fatalf("expected ErrUserNotFound, got %v", err)
+	}
+}
+
+func TestContact_UnknownUserIsPermanent(t *testing.T) {
+	svc := New(newTestDB(t))
+	_, err := svc.Contact(context.Background(), 42)
+	var permanent *channel.PermanentError
+	if !errors.As(err, &permanent) {
+		t.Fatalf("expected a permanent error, got %v", err)
+	}
+}
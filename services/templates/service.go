@@ -0,0 +1,218 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"notification/models"
+	"notification/models/channel"
+	texttemplate "text/template"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("template not found")
+	ErrTemplateExists   = errors.New("template already exists")
+	ErrInvalidTemplate  = errors.New("invalid template")
+)
+
+type BodyRequest struct {
+	ChannelName string
+	Subject     string
+	Text        string
+	HTML        string
+}
+
+type Service struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Service { return &Service{db: db} }
+
+// Create stores version 1 of a new template
+func (s *Service) Create(ctx context.Context, name string, bodies []BodyRequest) (*models.Template, error) {
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidTemplate)
+	}
+	if err := validateBodies(bodies); err != nil {
+		return nil, err
+	}
+
+	var tmpl *models.Template
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Template{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrTemplateExists
+		}
+		var err error
+		tmpl, err = createVersion(tx, name, bodies)
+		return err
+	})
+	return tmpl, err
+}
+
+// Update stores a new version of an existing template; previous versions are kept
+func (s *Service) Update(ctx context.Context, name string, bodies []BodyRequest) (*models.Template, error) {
+	if err := validateBodies(bodies); err != nil {
+		return nil, err
+	}
+
+	var tmpl *models.Template
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.Template{}).Where("name = ?", name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count == 0 {
+			return ErrTemplateNotFound
+		}
+		var err error
+		tmpl, err = createVersion(tx, name, bodies)
+		return err
+	})
+	return tmpl, err
+}
+
+// Get returns the given version of a template, or the latest one when version is 0
+func (s *Service) Get(ctx context.Context, name string, version int) (*models.Template, error) {
+	q := s.db.WithContext(ctx).Preload("Bodies").Where("name = ?", name)
+	if version > 0 {
+		q = q.Where("version = ?", version)
+	} else {
+		q = q.Order("version DESC")
+	}
+
+	var tmpl models.Template
+	if err := q.First(&tmpl).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// List returns the latest version of every template
+func (s *Service) List(ctx context.Context) ([]models.Template, error) {
+	latest := s.db.Model(&models.Template{}).Select("name, MAX(version) AS version").Group("name")
+
+	var list []models.Template
+	err := s.db.WithContext(ctx).Preload("Bodies").
+		Joins("JOIN (?) AS latest ON latest.name = templates.name AND latest.version = templates.version", latest).
+		Order("templates.name ASC").
+		Find(&list).Error
+	if err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// Versions returns every version of a template, newest first
+func (s *Service) Versions(ctx context.Context, name string) ([]models.Template, error) {
+	var list []models.Template
+	if err := s.db.WithContext(ctx).Preload("Bodies").Where("name = ?", name).Order("version DESC").Find(&list).Error; err != nil {
+		return nil, err
+	}
+	if len(list) == 0 {
+		return nil, ErrTemplateNotFound
+	}
+	return list, nil
+}
+
+// Delete soft deletes all versions of a template
+func (s *Service) Delete(ctx context.Context, name string) error {
+	res := s.db.WithContext(ctx).Where("name = ?", name).Delete(&models.Template{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// Lookup implements channel.TemplateSource
+func (s *Service) Lookup(ctx context.Context, name string, version int, channelName string) (channel.Template, error) {
+	tmpl, err := s.Get(ctx, name, version)
+	if errors.Is(err, ErrTemplateNotFound) {
+		return channel.Template{}, channel.ErrTemplateNotFound
+	}
+	if err != nil {
+		return channel.Template{}, err
+	}
+	for _, body := range tmpl.Bodies {
+		if body.ChannelName == channelName {
+			return channel.Template{
+				Name:    tmpl.Name,
+				Version: tmpl.Version,
+				Subject: body.Subject,
+				Text:    body.Text,
+				HTML:    body.HTML,
+			}, nil
+		}
+	}
+	return channel.Template{}, channel.ErrTemplateNotFound
+}
+
+func createVersion(tx *gorm.DB, name string, bodies []BodyRequest) (*models.Template, error) {
+	// Deleted versions still count so a recreated template never reuses a version number
+	var last int
+	if err := tx.Unscoped().Model(&models.Template{}).Where("name = ?", name).
+		Select("COALESCE(MAX(version), 0)").Scan(&last).Error; err != nil {
+		return nil, err
+	}
+
+	tmpl := models.Template{Name: name, Version: last + 1}
+	for _, b := range bodies {
+		tmpl.Bodies = append(tmpl.Bodies, models.TemplateBody{
+			ChannelName: b.ChannelName,
+			Subject:     b.Subject,
+			Text:        b.Text,
+			HTML:        b.HTML,
+		})
+	}
+	if err := tx.Create(&tmpl).Error; err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// validateBodies checks that each channel appears once and that every body parses,
+// so broken templates are rejected here instead of failing at dispatch
+func validateBodies(bodies []BodyRequest) error {
+	if len(bodies) == 0 {
+		return fmt.Errorf("%w: at least one body is required", ErrInvalidTemplate)
+	}
+	seen := map[string]bool{}
+	for _, b := range bodies {
+		if b.ChannelName == "" {
+			return fmt.Errorf("%w: channel_name is required", ErrInvalidTemplate)
+		}
+		if seen[b.ChannelName] {
+			return fmt.Errorf("%w: duplicate body for channel %s", ErrInvalidTemplate, b.ChannelName)
+		}
+		seen[b.ChannelName] = true
+
+		if b.Text == "" {
+			return fmt.Errorf("%w: text is required for channel %s", ErrInvalidTemplate, b.ChannelName)
+		}
+		if b.ChannelName == "email" && b.HTML == "" {
+			return fmt.Errorf("%w: html is required for email", ErrInvalidTemplate)
+		}
+		if _, err := texttemplate.New("subject").Parse(b.Subject); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+		if _, err := texttemplate.New("text").Parse(b.Text); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+		if _, err := htmltemplate.New("html").Parse(b.HTML); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package templates
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"notification/models"
+	"notification/models/channel"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	db, err := gorm.Open(dsn, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.Template{}, &models.TemplateBody{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func emailBody(text string) BodyRequest {
+	return BodyRequest{ChannelName: "email", Subject: "Hi {{.Title}}", Text: text, HTML: "<p>" + text + "</p>"}
+}
+
+func TestCreate_OK(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	tmpl, err := svc.Create(ctx, "welcome", []BodyRequest{emailBody("v1"), {ChannelName: "sms", Text: "sms v1"}})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if tmpl.Version != 1 || len(tmpl.Bodies) != 2 {
+		t.Fatalf("unexpected template: %+v", tmpl)
+	}
+
+	if _, err := svc.Create(ctx, "welcome", []BodyRequest{emailBody("again")}); !errors.Is(err, ErrTemplateExists) {
+		t.Fatalf("expected ErrTemplateExists, got %v", err)
+	}
+}
+
+func TestCreate_Invalid(t *testing.T) {
+	svc := New(newTestDB(t))
+	tests := []struct {
+		name   string
+		bodies []BodyRequest
+	}{
+		{"no bodies", nil},
+		{"missing channel", []BodyRequest{{Text: "x"}}},
+		{"missing text", []BodyRequest{{ChannelName: "sms"}}},
+		{"email without html", []BodyRequest{{ChannelName: "email", Text: "x"}}},
+		{"duplicate channel", []BodyRequest{{ChannelName: "sms", Text: "a"}, {ChannelName: "sms", Text: "b"}}},
+		{"bad syntax", []BodyRequest{{ChannelName: "sms", Text: "{{.Title"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.Create(context.Background(), "t", tt.bodies); !errors.Is(err, ErrInvalidTemplate) {
+				t.Fatalf("expected ErrInvalidTemplate, got %v", err)
+			}
+		})
+	}
+}
+
+func TestUpdate_CreatesNewVersion(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	if _, err := svc.Update(ctx, "welcome", []BodyRequest{emailBody("v1")}); !errors.Is(err, ErrTemplateNotFound) {
+		t.Fatalf("expected ErrTemplateNotFound, got %v", err)
+	}
+	if _, err := svc.Create(ctx, "welcome", []BodyRequest{emailBody("v1")}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	tmpl, err := svc.Update(ctx, "welcome", []BodyRequest{emailBody("v2")})
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if tmpl.Version != 2 {
+		t.Fatalf("expected version 2, got %d", tmpl.Version)
+	}
+
+	latest, err := svc.Get(ctx, "welcome", 0)
+	if err != nil || latest.Version != 2 || latest.Bodies[0].Text != "v2" {
+		t.Fatalf("unexpected latest: %+v, %v", latest, err)
+	}
+	first, err := svc.Get(ctx, "welcome", 1)
+	if err != nil || first.Bodies[0].Text != "v1" {
+		t.Fatalf("unexpected v1: %+v, %v", first, err)
+	}
+	versions, err := svc.Versions(ctx, "welcome")
+	if err != nil || len(versions) != 2 || versions[0].Version != 2 {
+		t.Fatalf("unexpected versions: %+v, %v", versions, err)
+	}
+}
+
+func TestList_ReturnsLatestVersions(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	svc.Create(ctx, "b", []BodyRequest{emailBody("b1")})
+	svc.Update(ctx, "b", []BodyRequest{emailBody("b2")})
+	svc.Create(ctx, "a", []BodyRequest{emailBody("a1")})
+
+	list, err := svc.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "a" || list[1].Name != "b" || list[1].Version != 2 || len(list[1].Bodies) != 1 {
+		t.Fatalf("unexpected list: %+v", list)
+	}
+}
+
+func TestDelete_KeepsVersionNumbering(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	svc.Create(ctx, "welcome", []BodyRequest{emailBody("v1")})
+	if err := svc.Delete(ctx, "welcome"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := svc.Get(ctx, "welcome", 0); !errors.Is(err, ErrTemplateNotFound) {
+		t.Fatalf("expected ErrTemplateNotFound, got %v", err)
+	}
+	if err := svc.Delete(ctx, "welcome"); !errors.Is(err, ErrTemplateNotFound) {
+		t.Fatalf("expected ErrTemplateNotFound, got %v", err)
+	}
+
+	tmpl, err := svc.Create(ctx, "welcome", []BodyRequest{emailBody("again")})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if tmpl.Version != 2 {
+		t.Fatalf("expected version 2 after re-creation, got %d", tmpl.Version)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+	svc.Create(ctx, "welcome", []BodyRequest{emailBody("v1"), {ChannelName: "sms", Text: "sms v1"}})
+
+	got, err := svc.Lookup(ctx, "welcome", 0, "sms")
+	if err != nil {
+		t.Fatalf("Lookup: %v", err)
+	}
+	if got.Name != "welcome" || got.Version != 1 || got.Text != "sms v1" {
+		t.Fatalf("unexpected template: %+v", got)
+	}
+	if _, err := svc.Lookup(ctx, "welcome", 0, "push"); !errors.Is(err, channel.ErrTemplateNotFound) {
+		t.Fatalf("expected channel.ErrTemplateNotFound for missing body, got %v", err)
+	}
+
+	// a pinned version is kept after the template is updated
+	svc.Update(ctx, "welcome", []BodyRequest{emailBody("v2"), {ChannelName: "sms", Text: "sms v2"}})
+	if got, err = svc.Lookup(ctx, "welcome", 1, "sms"); err != nil || got.Text != "sms v1" {
+		t.Fatalf("expected version 1, got %+v (%v)", got, err)
+	}
+	if got, err = svc.Lookup(ctx, "welcome", 0, "sms"); err != nil || got.Version != 2 {
+		t.Fatalf("expected the latest version, got %+v (%v)", got, err)
+	}
+	if _, err := svc.Lookup(ctx, "welcome", 3, "sms"); !errors.Is(err, channel.ErrTemplateNotFound) {
+		t.Fatalf("expected channel.ErrTemplateNotFound for missing version, got %v", err)
+	}
+	if _, err := svc.Lookup(ctx, "missing", 0, "sms"); !errors.Is(err, channel.ErrTemplateNotFound) {
+		t.Fatalf("expected channel.ErrTemplateNotFound for missing template, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package webhooks
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"notification/models"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Service manages the per-user secrets webhook notifications are signed with
+type Service struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Service { return &Service{db: db} }
+
+func newSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Get returns the secret of a user, generating it on first use
+func (s *Service) Get(ctx context.Context, userID uint) (*models.WebhookSecret, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	// concurrent first uses must not overwrite each other's secret
+	row := models.WebhookSecret{UserID: userID, Secret: secret}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+		return nil, err
+	}
+
+	var stored models.WebhookSecret
+	if err := s.db.WithContext(ctx).Where("user_id = ?", userID).First(&stored).Error; err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// Rotate replaces the secret of a user. Webhooks sent afterwards are signed with the new one
+func (s *Service) Rotate(ctx context.Context, userID uint) (*models.WebhookSecret, error) {
+	secret, err := newSecret()
+	if err != nil {
+		return nil, err
+	}
+	row := models.WebhookSecret{UserID: userID, Secret: secret}
+	if err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "updated_at"}),
+	}).Create(&row).Error; err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, userID)
+}
+
+// Secret implements channels.WebhookSecrets
+func (s *Service) Secret(ctx context.Context, userID uint) (string, error) {
+	row, err := s.Get(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+	return row.Secret, nil
+}
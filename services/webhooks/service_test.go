@@ -0,0 +1,66 @@
+package webhooks
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"notification/models"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", t.Name()))
+	db, err := gorm.Open(dsn, &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	if err := db.AutoMigrate(&models.WebhookSecret{}); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+func TestGet_GeneratesOnce(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	first, err := svc.Get(ctx, 1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if len(first.Secret) != 64 {
+		t.Fatalf("unexpected secret: %q", first.Secret)
+	}
+	again, err := svc.Secret(ctx, 1)
+	if err != nil || again != first.Secret {
+		t.Fatalf("expected the same secret, got %q, %v", again, err)
+	}
+	other, err := svc.Secret(ctx, 2)
+	if err != nil || other == first.Secret {
+		t.Fatalf("expected a different secret per user, got %q, %v", other, err)
+	}
+}
+
+func TestRotate(t *testing.T) {
+	svc := New(newTestDB(t))
+	ctx := context.Background()
+
+	first, err := svc.Get(ctx, 1)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	rotated, err := svc.Rotate(ctx, 1)
+	if err != nil {
+		t.Fatalf("Rotate: %v", err)
+	}
+	if rotated.Secret == first.Secret {
+		t.Fatal("expected a new secret")
+	}
+	if current, _ := svc.Secret(ctx, 1); current != rotated.Secret {
+		t.Fatalf("expected rotated secret to be current, got %q", current)
+	}
+}